@@ -34,9 +34,11 @@ type Handler struct {
 	authStatus *service.AuthStatus
 	tunnel     *service.Tunnel
 	socks5     *service.SOCKS5
+	speedTest  *service.SpeedTest
 	dns        DNSService
 	logBuffer  *ringbuffer.RingBuffer
 	vpnGateway *service.VPNGateway
+	presence   *service.Presence
 
 	echo      *echo.Echo
 	echoAdmin *echo.Echo
@@ -46,7 +48,8 @@ type Handler struct {
 }
 
 func NewHandler(conf *config.Config, p2p *p2p.P2p, authStatus *service.AuthStatus, tunnel *service.Tunnel, socks5 *service.SOCKS5,
-	logBuffer *ringbuffer.RingBuffer, dns DNSService, vpnGateway *service.VPNGateway) *Handler {
+	speedTest *service.SpeedTest, logBuffer *ringbuffer.RingBuffer, dns DNSService, vpnGateway *service.VPNGateway,
+	presence *service.Presence) *Handler {
 	ctx, ctxCancel := context.WithCancel(context.Background())
 	return &Handler{
 		conf:       conf,
@@ -54,9 +57,11 @@ func NewHandler(conf *config.Config, p2p *p2p.P2p, authStatus *service.AuthStatu
 		authStatus: authStatus,
 		tunnel:     tunnel,
 		socks5:     socks5,
+		speedTest:  speedTest,
 		dns:        dns,
 		logBuffer:  logBuffer,
 		vpnGateway: vpnGateway,
+		presence:   presence,
 		logger:     log.Logger("awl/api"),
 		ctx:        ctx,
 		ctxCancel:  ctxCancel,
@@ -130,7 +135,21 @@ func (h *Handler) setupRouter(address string) (*echo.Echo, error) {
 	e.POST(UpdatePeerSettingsPath, h.UpdatePeerSettings)
 	e.POST(RemovePeerSettingsPath, h.RemovePeer)
 	e.GET(GetAuthRequestsPath, h.GetAuthRequests)
+	e.POST(PurgeAuthRequestsPath, h.PurgeAuthRequests)
 	e.GET(GetBlockedPeersPath, h.GetBlockedPeers)
+	e.POST(SpeedTestPath, h.SpeedTest)
+	e.GET(GetInvitesPath, h.GetInvites)
+	e.POST(CreateInvitePath, h.CreateInvite)
+	e.POST(RevokeInvitePath, h.RevokeInvite)
+	e.POST(JoinInvitePath, h.JoinInvite)
+	e.GET(GetPeerGroupsPath, h.GetPeerGroups)
+	e.POST(UpdatePeerGroupPath, h.UpdatePeerGroup)
+	e.POST(RemovePeerGroupPath, h.RemovePeerGroup)
+	e.POST(GetPeerPermissionsPath, h.GetPeerPermissions)
+	e.GET(GetAuthRulesPath, h.GetAuthRules)
+	e.POST(UpdateAuthRulesPath, h.UpdateAuthRules)
+	e.GET(GetAuthDecisionsPath, h.GetAuthDecisions)
+	e.POST(GetPeerPresencePath, h.GetPeerPresence)
 
 	// Settings
 	e.GET(GetMyPeerInfoPath, h.GetMyPeerInfo)
@@ -138,6 +157,13 @@ func (h *Handler) setupRouter(address string) (*echo.Echo, error) {
 	e.GET(ListAvailableProxiesPath, h.ListAvailableProxies)
 	e.POST(UpdateProxySettingsPath, h.UpdateProxySettings)
 	e.GET(ExportServerConfigPath, h.ExportServerConfiguration)
+	e.POST(RotateIdentityPath, h.RotateIdentity)
+	e.POST(UpdateSecretsPath, h.UpdateSecretsEncryption)
+	e.GET(GetPresenceWebhooksPath, h.GetPresenceWebhooks)
+	e.POST(UpdatePresenceWebhooksPath, h.UpdatePresenceWebhooks)
+	e.GET(GetPrivateNetworkPath, h.GetPrivateNetwork)
+	e.POST(UpdatePrivateNetworkPath, h.UpdatePrivateNetwork)
+	e.POST(GeneratePrivateNetworkKeyPath, h.GeneratePrivateNetworkKey)
 
 	// VPN Gateway. Status comes from /settings/peer_info (PeerInfo.VPNGateway).
 	e.POST(EnableVPNGatewayClientPath, h.EnableVPNGatewayClient)
@@ -148,6 +174,7 @@ func (h *Handler) setupRouter(address string) (*echo.Echo, error) {
 	// Debug
 	e.GET(GetP2pDebugInfoPath, h.GetP2pDebugInfo)
 	e.GET(GetDebugLogPath, h.GetLog)
+	e.GET(GetNetcheckPath, h.GetNetcheck)
 
 	e.Any(V0Prefix+"debug/pprof/", echo.WrapHandler(http.HandlerFunc(http_pprof.Index)))
 	e.Any(V0Prefix+"debug/pprof/profile", echo.WrapHandler(http.HandlerFunc(http_pprof.Profile)))
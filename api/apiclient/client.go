@@ -14,6 +14,7 @@ import (
 	"github.com/anywherelan/awl/api"
 	"github.com/anywherelan/awl/config"
 	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/p2p"
 )
 
 type Client struct {
@@ -127,6 +128,19 @@ func (c *Client) AuthRequests() ([]entity.AuthRequest, error) {
 	return authRequests, nil
 }
 
+func (c *Client) PurgeAuthRequests(peerIDs []string, decline bool) (*entity.PurgeAuthRequestsResponse, error) {
+	request := entity.PurgeAuthRequestsRequest{
+		PeerIDs: peerIDs,
+		Decline: decline,
+	}
+	response := new(entity.PurgeAuthRequestsResponse)
+	err := c.sendPostRequest(api.PurgeAuthRequestsPath, request, response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 func (c *Client) BlockedPeers() ([]config.BlockedPeer, error) {
 	blocked := make([]config.BlockedPeer, 0)
 	err := c.sendGetRequest(api.GetBlockedPeersPath, &blocked)
@@ -145,6 +159,121 @@ func (c *Client) RemovePeer(peerID string) error {
 	return c.sendPostRequest(api.RemovePeerSettingsPath, request, nil)
 }
 
+func (c *Client) Invites() ([]entity.Invite, error) {
+	invites := make([]entity.Invite, 0)
+	err := c.sendGetRequest(api.GetInvitesPath, &invites)
+	if err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (c *Client) CreateInvite(request entity.CreateInviteRequest) (*entity.Invite, error) {
+	invite := new(entity.Invite)
+	err := c.sendPostRequest(api.CreateInvitePath, request, invite)
+	if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (c *Client) RevokeInvite(id string) error {
+	request := entity.InviteIDRequest{ID: id}
+	return c.sendPostRequest(api.RevokeInvitePath, request, nil)
+}
+
+func (c *Client) JoinInvite(invite, alias, ipAddr string) (string, error) {
+	request := entity.JoinInviteRequest{
+		Invite: invite,
+		Alias:  alias,
+		IPAddr: ipAddr,
+	}
+	resp := entity.JoinInviteResponse{}
+	err := c.sendPostRequest(api.JoinInvitePath, request, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.PeerID, nil
+}
+
+func (c *Client) PeerGroups() ([]entity.PeerGroup, error) {
+	groups := make([]entity.PeerGroup, 0)
+	err := c.sendGetRequest(api.GetPeerGroupsPath, &groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (c *Client) UpdatePeerGroup(request entity.UpdatePeerGroupRequest) error {
+	return c.sendPostRequest(api.UpdatePeerGroupPath, request, nil)
+}
+
+func (c *Client) RemovePeerGroup(name string) error {
+	request := entity.PeerGroupNameRequest{Name: name}
+	return c.sendPostRequest(api.RemovePeerGroupPath, request, nil)
+}
+
+func (c *Client) PeerPermissions(peerID string) (*entity.PeerPermissions, error) {
+	perms := new(entity.PeerPermissions)
+	request := entity.PeerIDRequest{PeerID: peerID}
+	err := c.sendPostRequest(api.GetPeerPermissionsPath, request, perms)
+	if err != nil {
+		return nil, err
+	}
+	return perms, nil
+}
+
+func (c *Client) PeerPresence(peerID string) (*entity.PeerPresence, error) {
+	presence := new(entity.PeerPresence)
+	request := entity.PeerIDRequest{PeerID: peerID}
+	err := c.sendPostRequest(api.GetPeerPresencePath, request, presence)
+	if err != nil {
+		return nil, err
+	}
+	return presence, nil
+}
+
+func (c *Client) AuthRules() ([]config.AuthRule, error) {
+	rules := make([]config.AuthRule, 0)
+	err := c.sendGetRequest(api.GetAuthRulesPath, &rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (c *Client) UpdateAuthRules(rules []config.AuthRule) error {
+	request := entity.UpdateAuthRulesRequest{Rules: rules}
+	return c.sendPostRequest(api.UpdateAuthRulesPath, request, nil)
+}
+
+func (c *Client) AuthDecisions() ([]entity.AuthDecision, error) {
+	decisions := make([]entity.AuthDecision, 0)
+	err := c.sendGetRequest(api.GetAuthDecisionsPath, &decisions)
+	if err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// SpeedTest blocks until the test is finished, so request timeout is extended by the test duration.
+func (c *Client) SpeedTest(request entity.SpeedTestRequest) (*entity.SpeedTestResult, error) {
+	testClient := &Client{
+		address: c.address,
+		cli: &http.Client{
+			Transport: c.cli.Transport,
+			Timeout:   c.cli.Timeout + time.Duration(max(request.DurationSec, 10))*time.Second*2,
+		},
+	}
+	result := new(entity.SpeedTestResult)
+	err := testClient.sendPostRequest(api.SpeedTestPath, request, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (c *Client) UpdateMySettings(name string) error {
 	request := entity.UpdateMySettingsRequest{
 		Name: name,
@@ -152,6 +281,75 @@ func (c *Client) UpdateMySettings(name string) error {
 	return c.sendPostRequest(api.UpdateMyInfoPath, request, nil)
 }
 
+func (c *Client) RotateIdentity() (*entity.RotateIdentityResponse, error) {
+	response := new(entity.RotateIdentityResponse)
+	err := c.sendPostRequest(api.RotateIdentityPath, nil, response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) UpdateSecretsEncryption(request entity.UpdateSecretsEncryptionRequest) error {
+	return c.sendPostRequest(api.UpdateSecretsPath, request, nil)
+}
+
+func (c *Client) PresenceWebhooks() ([]string, error) {
+	webhookURLs := make([]string, 0)
+	err := c.sendGetRequest(api.GetPresenceWebhooksPath, &webhookURLs)
+	if err != nil {
+		return nil, err
+	}
+	return webhookURLs, nil
+}
+
+func (c *Client) UpdatePresenceWebhooks(webhookURLs []string) error {
+	request := entity.UpdatePresenceWebhooksRequest{WebhookURLs: webhookURLs}
+	return c.sendPostRequest(api.UpdatePresenceWebhooksPath, request, nil)
+}
+
+func (c *Client) UpdateMyDeviceInfo(name, deviceInfo string) error {
+	request := entity.UpdateMySettingsRequest{
+		Name:       name,
+		DeviceInfo: &deviceInfo,
+	}
+	return c.sendPostRequest(api.UpdateMyInfoPath, request, nil)
+}
+
+func (c *Client) UpdateMyDomainNames(name, domainName string, aliases []string) error {
+	if aliases == nil {
+		aliases = []string{}
+	}
+	request := entity.UpdateMySettingsRequest{
+		Name:          name,
+		DomainName:    &domainName,
+		DomainAliases: aliases,
+	}
+	return c.sendPostRequest(api.UpdateMyInfoPath, request, nil)
+}
+
+func (c *Client) PrivateNetwork() (*entity.PrivateNetworkInfo, error) {
+	info := new(entity.PrivateNetworkInfo)
+	err := c.sendGetRequest(api.GetPrivateNetworkPath, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *Client) UpdatePrivateNetwork(request entity.UpdatePrivateNetworkRequest) error {
+	return c.sendPostRequest(api.UpdatePrivateNetworkPath, request, nil)
+}
+
+func (c *Client) GeneratePrivateNetworkKey() (string, error) {
+	resp := entity.GeneratePrivateNetworkKeyResponse{}
+	err := c.sendPostRequest(api.GeneratePrivateNetworkKeyPath, nil, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.NetworkKey, nil
+}
+
 func (c *Client) EnableVPNGatewayClient(gatewayPeerID string) error {
 	request := entity.EnableVPNGatewayClientRequest{
 		GatewayPeerID: gatewayPeerID,
@@ -185,6 +383,15 @@ func (c *Client) P2pDebugInfo() (*entity.P2pDebugInfo, error) {
 	return debugInfo, nil
 }
 
+func (c *Client) Netcheck() (*p2p.NetcheckReport, error) {
+	report := new(p2p.NetcheckReport)
+	err := c.sendGetRequest(api.GetNetcheckPath, report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
 // ApplicationLog
 // send numberOfLogs = 0 to print all logs
 func (c *Client) ApplicationLog(numberOfLogs int, startFromHead bool) (string, error) {
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/anywherelan/awl/entity"
+)
+
+// @Tags		Peers
+// @Summary	Get auth rules for incoming auth requests
+// @Accept		json
+// @Produce	json
+// @Success	200	{array}	config.AuthRule
+// @Router		/peers/auth_rules [GET]
+func (h *Handler) GetAuthRules(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, h.conf.GetAuthRules())
+}
+
+// UpdateAuthRules replaces auth rules, they are applied in order to auth requests from unknown peers.
+//
+// @Tags		Peers
+// @Summary	Update auth rules
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.UpdateAuthRulesRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Router		/peers/auth_rules/update [POST]
+func (h *Handler) UpdateAuthRules(c echo.Context) (err error) {
+	req := entity.UpdateAuthRulesRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	err = h.conf.SetAuthRules(req.Rules)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Tags		Peers
+// @Summary	Get recent decisions on auth requests from unknown peers
+// @Accept		json
+// @Produce	json
+// @Success	200	{array}	entity.AuthDecision
+// @Router		/peers/auth_decisions [GET]
+func (h *Handler) GetAuthDecisions(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, h.authStatus.AuthDecisions())
+}
@@ -10,10 +10,28 @@ const (
 	RemovePeerSettingsPath   = V0Prefix + "peers/remove"
 
 	GetBlockedPeersPath = V0Prefix + "peers/get_blocked"
+	SpeedTestPath       = V0Prefix + "peers/speedtest"
 
 	SendFriendRequestPath    = V0Prefix + "peers/invite_peer"
 	AcceptPeerInvitationPath = V0Prefix + "peers/accept_peer"
 	GetAuthRequestsPath      = V0Prefix + "peers/auth_requests"
+	PurgeAuthRequestsPath    = V0Prefix + "peers/auth_requests/purge"
+
+	GetInvitesPath   = V0Prefix + "peers/invites"
+	CreateInvitePath = V0Prefix + "peers/invites/create"
+	RevokeInvitePath = V0Prefix + "peers/invites/revoke"
+	JoinInvitePath   = V0Prefix + "peers/invites/join"
+
+	GetPeerGroupsPath      = V0Prefix + "peers/groups"
+	UpdatePeerGroupPath    = V0Prefix + "peers/groups/update"
+	RemovePeerGroupPath    = V0Prefix + "peers/groups/remove"
+	GetPeerPermissionsPath = V0Prefix + "peers/get_permissions"
+
+	GetAuthRulesPath     = V0Prefix + "peers/auth_rules"
+	UpdateAuthRulesPath  = V0Prefix + "peers/auth_rules/update"
+	GetAuthDecisionsPath = V0Prefix + "peers/auth_decisions"
+
+	GetPeerPresencePath = V0Prefix + "peers/presence"
 
 	// Settings
 	GetMyPeerInfoPath        = V0Prefix + "settings/peer_info"
@@ -21,6 +39,15 @@ const (
 	ListAvailableProxiesPath = V0Prefix + "settings/list_proxies"
 	UpdateProxySettingsPath  = V0Prefix + "settings/set_proxy"
 	ExportServerConfigPath   = V0Prefix + "settings/export_server_config"
+	RotateIdentityPath       = V0Prefix + "settings/rotate_identity"
+	UpdateSecretsPath        = V0Prefix + "settings/secrets/update"
+
+	GetPresenceWebhooksPath    = V0Prefix + "settings/presence_webhooks"
+	UpdatePresenceWebhooksPath = V0Prefix + "settings/presence_webhooks/update"
+
+	GetPrivateNetworkPath         = V0Prefix + "settings/private_network"
+	UpdatePrivateNetworkPath      = V0Prefix + "settings/private_network/update"
+	GeneratePrivateNetworkKeyPath = V0Prefix + "settings/private_network/generate_key"
 
 	// VPN Gateway
 	EnableVPNGatewayClientPath     = V0Prefix + "vpn_gateway/client/enable"
@@ -31,4 +58,5 @@ const (
 	// Debug
 	GetP2pDebugInfoPath = V0Prefix + "debug/p2p_info"
 	GetDebugLogPath     = V0Prefix + "debug/log"
+	GetNetcheckPath     = V0Prefix + "debug/netcheck"
 )
@@ -56,12 +56,24 @@ func (h *Handler) GetP2pDebugInfo(c echo.Context) (err error) {
 			Total:      makeBandwidthInfo(h.p2p.NetworkStats()),
 			ByProtocol: bandwidthByProtocol,
 		},
-		KnownPeers: h.getKnownPeers(),
+		ResourceManager: h.p2p.ResourceManagerDebugInfo(),
+		KnownPeers:      h.getKnownPeers(),
 	}
 
 	return c.JSONPretty(http.StatusOK, debugInfo, "    ")
 }
 
+// @Tags		Debug
+// @Summary	Get connectivity diagnostics
+// @Produce	json
+// @Success	200	{object}	p2p.NetcheckReport
+// @Router		/debug/netcheck [GET]
+func (h *Handler) GetNetcheck(c echo.Context) (err error) {
+	report := h.p2p.Netcheck(c.Request().Context())
+
+	return c.JSONPretty(http.StatusOK, report, "    ")
+}
+
 // @Tags		Debug
 // @Summary	Get logs
 // @Param		logs		query	int		false	"Define number of rows of logs to output. On default and 0 prints all."
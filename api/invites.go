@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/protocol"
+	"github.com/anywherelan/awl/service"
+)
+
+// @Tags		Peers
+// @Summary	Get active invites
+// @Accept		json
+// @Produce	json
+// @Success	200	{array}	entity.Invite
+// @Router		/peers/invites [GET]
+func (h *Handler) GetInvites(c echo.Context) (err error) {
+	invites := h.conf.GetInvites()
+	result := make([]entity.Invite, 0, len(invites))
+	for _, invite := range invites {
+		result = append(result, newInviteResponse(invite))
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// CreateInvite issues signed invite, peers which join with it are accepted without confirmation.
+//
+// @Tags		Peers
+// @Summary	Create invite
+// @Accept		json
+// @Produce	json
+// @Param		body	body		entity.CreateInviteRequest	true	"Params"
+// @Success	200		{object}	entity.Invite
+// @Failure	400		{object}	api.Error
+// @Failure	500		{object}	api.Error
+// @Router		/peers/invites/create [POST]
+func (h *Handler) CreateInvite(c echo.Context) (err error) {
+	req := entity.CreateInviteRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	opts := service.InviteOptions{
+		TTL:                  time.Duration(req.TTLSec) * time.Second,
+		MaxUses:              req.MaxUses,
+		AllowUsingAsExitNode: req.AllowUsingAsExitNode,
+		AllowUsingAsRelay:    req.AllowUsingAsRelay,
+	}
+	if opts.TTL == 0 {
+		opts.TTL = service.DefaultInviteTTL
+	}
+	if opts.MaxUses == 0 {
+		opts.MaxUses = 1
+	}
+	if !req.WithoutAddrs {
+		opts.Addrs = h.p2p.ShareableAddrs()
+	}
+
+	invite, err := h.authStatus.CreateInvite(opts)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorMessage(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, newInviteResponse(invite))
+}
+
+// @Tags		Peers
+// @Summary	Revoke invite
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.InviteIDRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Failure	404		{object}	api.Error
+// @Router		/peers/invites/revoke [POST]
+func (h *Handler) RevokeInvite(c echo.Context) (err error) {
+	req := entity.InviteIDRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	if !h.conf.RemoveInvite(req.ID) {
+		return c.JSON(http.StatusNotFound, ErrorMessage("invite not found"))
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// JoinInvite adds the inviting peer and sends it auth request with the invite, the peer accepts it automatically.
+//
+// @Tags		Peers
+// @Summary	Join with invite
+// @Accept		json
+// @Produce	json
+// @Param		body	body		entity.JoinInviteRequest	true	"Params"
+// @Success	200		{object}	entity.JoinInviteResponse
+// @Failure	400		{object}	api.Error
+// @Router		/peers/invites/join [POST]
+func (h *Handler) JoinInvite(c echo.Context) (err error) {
+	req := entity.JoinInviteRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	peerID, err := h.authStatus.JoinInvite(h.ctx, req.Invite, req.Alias, req.IPAddr)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, entity.JoinInviteResponse{PeerID: peerID.String()})
+}
+
+func newInviteResponse(invite config.Invite) entity.Invite {
+	return entity.Invite{
+		ID:                   invite.ID,
+		URI:                  protocol.InviteURI(invite.Token),
+		CreatedAt:            invite.CreatedAt,
+		ExpiresAt:            invite.ExpiresAt,
+		MaxUses:              invite.MaxUses,
+		Uses:                 invite.Uses,
+		AllowUsingAsExitNode: invite.AllowUsingAsExitNode,
+		AllowUsingAsRelay:    invite.AllowUsingAsRelay,
+	}
+}
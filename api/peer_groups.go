@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+)
+
+// @Tags		Peers
+// @Summary	Get peer groups
+// @Accept		json
+// @Produce	json
+// @Success	200	{array}	entity.PeerGroup
+// @Router		/peers/groups [GET]
+func (h *Handler) GetPeerGroups(c echo.Context) (err error) {
+	groups := h.conf.GetPeerGroups()
+	result := make([]entity.PeerGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, entity.PeerGroup{
+			PeerGroup: group,
+			Members:   h.conf.GroupMembers(group.Name),
+		})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// UpdatePeerGroup creates peer group or updates its permissions, members inherit them.
+//
+// @Tags		Peers
+// @Summary	Create or update peer group
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.UpdatePeerGroupRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Router		/peers/groups/update [POST]
+func (h *Handler) UpdatePeerGroup(c echo.Context) (err error) {
+	req := entity.UpdatePeerGroupRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	err = h.conf.UpsertPeerGroup(config.PeerGroup{
+		Name:                 req.Name,
+		AllowUsingAsExitNode: req.AllowUsingAsExitNode,
+		AllowUsingAsRelay:    req.AllowUsingAsRelay,
+		HideFromDNS:          req.HideFromDNS,
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	h.exchangeStatusInfoWithPeers(h.conf.GroupMembers(req.Name))
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Tags		Peers
+// @Summary	Remove peer group
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.PeerGroupNameRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Failure	404		{object}	api.Error
+// @Router		/peers/groups/remove [POST]
+func (h *Handler) RemovePeerGroup(c echo.Context) (err error) {
+	req := entity.PeerGroupNameRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	members := h.conf.GroupMembers(req.Name)
+	if !h.conf.RemovePeerGroup(req.Name) {
+		return c.JSON(http.StatusNotFound, ErrorMessage("group not found"))
+	}
+	h.exchangeStatusInfoWithPeers(members)
+
+	return c.NoContent(http.StatusOK)
+}
+
+// @Tags		Peers
+// @Summary	Get effective permissions of known peer
+// @Accept		json
+// @Produce	json
+// @Param		body	body		entity.PeerIDRequest	true	"Params"
+// @Success	200		{object}	entity.PeerPermissions
+// @Failure	400		{object}	api.Error
+// @Failure	404		{object}	api.Error
+// @Router		/peers/get_permissions [POST]
+func (h *Handler) GetPeerPermissions(c echo.Context) (err error) {
+	req := entity.PeerIDRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	h.conf.RLock()
+	defer h.conf.RUnlock()
+	knownPeer, exists := h.conf.GetPeerUnlocked(req.PeerID)
+	if !exists {
+		return c.JSON(http.StatusNotFound, ErrorMessage("peer not found"))
+	}
+	result := entity.PeerPermissions{
+		PeerID: knownPeer.PeerID,
+		Groups: knownPeer.Groups,
+		Own: config.PeerPermissions{
+			AllowUsingAsExitNode: knownPeer.WeAllowUsingAsExitNode,
+			AllowUsingAsRelay:    knownPeer.WeAllowUsingAsRelay,
+			VisibleInDNS:         true,
+		},
+		Effective: h.conf.PeerPermissionsUnlocked(knownPeer),
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// exchangeStatusInfoWithPeers notifies peers about changed permissions.
+func (h *Handler) exchangeStatusInfoWithPeers(peerIDs []string) {
+	for _, peerIDStr := range peerIDs {
+		knownPeer, exists := h.conf.GetPeer(peerIDStr)
+		if !exists {
+			continue
+		}
+		go func() {
+			_ = h.authStatus.ExchangeNewStatusInfo(h.ctx, knownPeer.PeerId(), knownPeer)
+		}()
+	}
+}
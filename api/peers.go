@@ -2,8 +2,10 @@ package api
 
 import (
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -11,9 +13,15 @@ import (
 	"github.com/anywherelan/awl/awldns"
 	"github.com/anywherelan/awl/config"
 	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/p2p"
+	"github.com/anywherelan/awl/protocol"
 )
 
-const ErrorPeerAliasIsNotUniq = "peer name is not unique"
+const (
+	ErrorPeerAliasIsNotUniq = "peer name is not unique"
+
+	defaultSpeedTestDuration = 10 * time.Second
+)
 
 // @Tags		Peers
 // @Summary	Get known peers info
@@ -35,9 +43,11 @@ func (h *Handler) getKnownPeers() []entity.KnownPeersResponse {
 	}
 	h.conf.RUnlock()
 	sort.Strings(peers)
+	domainNames := h.conf.PeersDomainNames()
 
 	for _, peerID := range peers {
 		knownPeer, _ := h.conf.GetPeer(peerID)
+		perms, _ := h.conf.PeerPermissions(peerID)
 
 		id := knownPeer.PeerId()
 		netStats := h.p2p.NetworkStatsForPeer(id)
@@ -46,20 +56,34 @@ func (h *Handler) getKnownPeers() []entity.KnownPeersResponse {
 			Name:                          knownPeer.DisplayName(),
 			DisplayName:                   knownPeer.DisplayName(),
 			Alias:                         knownPeer.Alias,
-			Version:                       config.VersionFromUserAgent(h.p2p.PeerUserAgent(id)),
+			Version:                       peerVersion(knownPeer, h.p2p.PeerUserAgent(id)),
 			IpAddr:                        knownPeer.IPAddr,
-			DomainName:                    knownPeer.DomainName,
+			DomainName:                    knownPeer.PreferredDomainName(),
+			DomainNames:                   domainNames[peerID],
+			AnnouncedDomainName:           knownPeer.AnnouncedDomainName,
+			DomainNameOverride:            knownPeer.DomainNameOverride,
 			Connected:                     h.p2p.IsConnected(id),
 			Confirmed:                     knownPeer.Confirmed,
 			Declined:                      knownPeer.Declined,
 			WeAllowUsingAsExitNode:        knownPeer.WeAllowUsingAsExitNode,
 			AllowedUsingAsExitNode:        knownPeer.AllowedUsingAsExitNode,
 			RemoteVPNGatewayServerEnabled: knownPeer.RemoteVPNGatewayServerEnabled,
+			RemoteRelayServiceEnabled:     knownPeer.RemoteRelayServiceEnabled,
+			WeAllowUsingAsRelay:           knownPeer.WeAllowUsingAsRelay,
+			AllowedUsingAsRelay:           knownPeer.AllowedUsingAsRelay,
+			Groups:                        knownPeer.Groups,
+			AccessSuspended:               perms.Suspended,
+			Capabilities:                  knownPeer.Capabilities,
+			Compatibility:                 peerCompatibility(knownPeer),
 			LastSeen:                      knownPeer.LastSeen,
-			Connections:                   h.p2p.PeerConnectionsInfo(id),
+			PresenceNotify:                knownPeer.PresenceNotify,
+			Uptime:                        h.presence.PeerUptime(peerID),
+			Connections:                   h.peerConnectionsInfo(id),
 			NetworkStats:                  netStats,
 			NetworkStatsInIECUnits:        getStatsInIECUnits(netStats),
+			RelayedNetworkStats:           h.p2p.RelayedNetworkStatsForPeer(id),
 			Ping:                          h.p2p.GetPeerLatency(id),
+			ConnectionQuality:             h.p2p.PeerConnectionQuality(id),
 		}
 		result = append(result, kpr)
 	}
@@ -72,6 +96,20 @@ func (h *Handler) getKnownPeers() []entity.KnownPeersResponse {
 	return result
 }
 
+// peerConnectionsInfo returns peer connections with names of known peers which relay them.
+func (h *Handler) peerConnectionsInfo(peerID peer.ID) []p2p.ConnectionInfo {
+	conns := h.p2p.PeerConnectionsInfo(peerID)
+	for i, conn := range conns {
+		if !conn.ThroughRelay {
+			continue
+		}
+		if relayPeer, ok := h.conf.GetPeer(conn.RelayPeerID); ok {
+			conns[i].RelayPeerName = relayPeer.DisplayName()
+		}
+	}
+	return conns
+}
+
 // @Tags		Peers
 // @Summary	Get known peer settings
 // @Accept		json
@@ -136,10 +174,52 @@ func (h *Handler) UpdatePeerSettings(c echo.Context) (err error) {
 	if checkIPErr := h.conf.CheckIPUnique(req.IPAddr, knownPeer.PeerID); checkIPErr != nil {
 		return c.JSON(http.StatusBadRequest, ErrorMessage(checkIPErr.Error()))
 	}
+	if req.StaticAddrs != nil {
+		staticAddrs := make([]string, 0, len(req.StaticAddrs))
+		for _, addr := range req.StaticAddrs {
+			maddr, parseErr := config.ParseStaticAddr(addr, knownPeer.PeerId())
+			if parseErr != nil {
+				return c.JSON(http.StatusBadRequest, ErrorMessage(parseErr.Error()))
+			}
+			if !slices.Contains(staticAddrs, maddr.String()) {
+				staticAddrs = append(staticAddrs, maddr.String())
+			}
+		}
+		knownPeer.StaticAddrs = staticAddrs
+	}
+	if req.Groups != nil {
+		groups, groupsErr := h.conf.ValidPeerGroupsUnlocked(req.Groups)
+		if groupsErr != nil {
+			return c.JSON(http.StatusBadRequest, ErrorMessage(groupsErr.Error()))
+		}
+		knownPeer.Groups = groups
+	}
+	if req.Access != nil {
+		if accessErr := config.ValidatePeerAccess(*req.Access); accessErr != nil {
+			return c.JSON(http.StatusBadRequest, ErrorMessage(accessErr.Error()))
+		}
+		knownPeer.Access = *req.Access
+	}
+	if req.ExitNodeAccess != nil {
+		if accessErr := config.ValidateExitNodeAccess(*req.ExitNodeAccess); accessErr != nil {
+			return c.JSON(http.StatusBadRequest, ErrorMessage(accessErr.Error()))
+		}
+		knownPeer.ExitNodeAccess = *req.ExitNodeAccess
+	}
+	if req.PresenceNotify != nil {
+		knownPeer.PresenceNotify = *req.PresenceNotify
+	}
 
 	knownPeer.Alias = req.Alias
-	knownPeer.DomainName = req.DomainName
+	if req.DomainName != knownPeer.DomainName {
+		knownPeer.DomainName = req.DomainName
+		// setting the announced name back removes the override
+		knownPeer.DomainNameOverride = req.DomainName != knownPeer.AnnouncedDomainName
+	}
 	knownPeer.WeAllowUsingAsExitNode = req.AllowUsingAsExitNode
+	if req.AllowUsingAsRelay != nil {
+		knownPeer.WeAllowUsingAsRelay = *req.AllowUsingAsRelay
+	}
 	knownPeer.IPAddr = req.IPAddr
 
 	h.conf.UpsertPeerUnlocked(knownPeer)
@@ -272,6 +352,36 @@ func (h *Handler) GetAuthRequests(c echo.Context) (err error) {
 	return c.JSON(http.StatusOK, authRequests)
 }
 
+// PurgeAuthRequests removes pending auth requests without reply or declines them, e.g. after flood from unknown peers.
+//
+// @Tags		Peers
+// @Summary	Purge or decline pending auth requests
+// @Accept		json
+// @Produce	json
+// @Param		body	body		entity.PurgeAuthRequestsRequest	true	"Params"
+// @Success	200		{object}	entity.PurgeAuthRequestsResponse
+// @Failure	400		{object}	api.Error
+// @Router		/peers/auth_requests/purge [POST]
+func (h *Handler) PurgeAuthRequests(c echo.Context) (err error) {
+	req := entity.PurgeAuthRequestsRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	purged := h.authStatus.PurgeIngoingAuthRequests(req.PeerIDs)
+	if req.Decline {
+		for peerID, auth := range purged {
+			h.authStatus.BlockPeer(peerID, auth.Name)
+		}
+	}
+
+	return c.JSON(http.StatusOK, entity.PurgeAuthRequestsResponse{Purged: len(purged)})
+}
+
 // @Tags		Peers
 // @Summary	Remove known peer
 // @Accept		json
@@ -307,6 +417,47 @@ func (h *Handler) RemovePeer(c echo.Context) (err error) {
 	return c.NoContent(http.StatusOK)
 }
 
+// @Tags		Peers
+// @Summary	Run throughput and latency test with known peer
+// @Description	Test runs over the same stream setup as VPN traffic. Request blocks until the test is finished.
+// @Accept		json
+// @Produce	json
+// @Param		body	body		entity.SpeedTestRequest	true	"Params"
+// @Success	200		{object}	entity.SpeedTestResult
+// @Failure	400		{object}	api.Error
+// @Failure	404		{object}	api.Error
+// @Failure	500		{object}	api.Error
+// @Router		/peers/speedtest [POST]
+func (h *Handler) SpeedTest(c echo.Context) (err error) {
+	req := entity.SpeedTestRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	peerId, err := peer.Decode(req.PeerID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorMessage("Invalid hex-encoded multihash representing of a peer ID"))
+	}
+	if _, exists := h.conf.GetPeer(req.PeerID); !exists {
+		return c.JSON(http.StatusNotFound, ErrorMessage("peer not found"))
+	}
+	duration := time.Duration(req.DurationSec) * time.Second
+	if duration == 0 {
+		duration = defaultSpeedTestDuration
+	}
+
+	result, err := h.speedTest.Run(c.Request().Context(), peerId, req.Direction, duration)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorMessage(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
 // @Tags		Peers
 // @Summary	Get blocked peers info
 // @Accept		json
@@ -329,3 +480,19 @@ func (h *Handler) GetBlockedPeers(c echo.Context) (err error) {
 
 	return c.JSON(http.StatusOK, result)
 }
+
+// peerCompatibility is empty for peers which haven't exchanged status info with us yet.
+func peerCompatibility(knownPeer config.KnownPeer) string {
+	if !knownPeer.Confirmed && knownPeer.Capabilities == nil {
+		return ""
+	}
+	return protocol.CheckCompatibility(knownPeer.Capabilities)
+}
+
+// peerVersion prefers version from capabilities, user agent is used for peers which don't send them.
+func peerVersion(knownPeer config.KnownPeer, userAgent string) string {
+	if knownPeer.Capabilities != nil && knownPeer.Capabilities.Version != "" {
+		return knownPeer.Capabilities.Version
+	}
+	return config.VersionFromUserAgent(userAgent)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/anywherelan/awl/entity"
+)
+
+// @Tags		Peers
+// @Summary	Get presence history and uptime of known peer
+// @Accept		json
+// @Produce	json
+// @Param		body	body		entity.PeerIDRequest	true	"Params"
+// @Success	200		{object}	entity.PeerPresence
+// @Failure	400		{object}	api.Error
+// @Failure	404		{object}	api.Error
+// @Router		/peers/presence [POST]
+func (h *Handler) GetPeerPresence(c echo.Context) (err error) {
+	req := entity.PeerIDRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	knownPeer, exists := h.conf.GetPeer(req.PeerID)
+	if !exists {
+		return c.JSON(http.StatusNotFound, ErrorMessage("peer not found"))
+	}
+	presence := h.presence.PeerPresence(req.PeerID)
+	presence.DisplayName = knownPeer.DisplayName()
+
+	return c.JSON(http.StatusOK, presence)
+}
+
+// @Tags		Settings
+// @Summary	Get webhook URLs which receive presence notifications
+// @Accept		json
+// @Produce	json
+// @Success	200	{array}	string
+// @Router		/settings/presence_webhooks [GET]
+func (h *Handler) GetPresenceWebhooks(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, h.conf.GetPresenceConfig().WebhookURLs)
+}
+
+// UpdatePresenceWebhooks replaces webhook URLs. They receive POST requests with JSON body when known peers
+// with enabled presence notifications go online or offline.
+//
+// @Tags		Settings
+// @Summary	Update webhook URLs which receive presence notifications
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.UpdatePresenceWebhooksRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Router		/settings/presence_webhooks/update [POST]
+func (h *Handler) UpdatePresenceWebhooks(c echo.Context) (err error) {
+	req := entity.UpdatePresenceWebhooksRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	err = h.conf.SetPresenceWebhooks(req.WebhookURLs)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/labstack/echo/v4"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
 
 	"github.com/anywherelan/awl/config"
 	"github.com/anywherelan/awl/entity"
@@ -25,11 +31,14 @@ func (h *Handler) GetMyPeerInfo(c echo.Context) (err error) {
 	h.conf.RLock()
 	p2pNode := h.conf.P2pNode
 	vpnConfig := h.conf.VPNConfig
+	offlineMode := h.conf.OfflineMode
 	h.conf.RUnlock()
 
 	peerInfo := entity.PeerInfo{
 		PeerID:                  p2pNode.PeerID,
 		Name:                    p2pNode.Name,
+		DomainName:              p2pNode.DomainName,
+		DomainAliases:           slices.Clone(p2pNode.DomainAliases),
 		Uptime:                  h.p2p.Uptime(),
 		ServerVersion:           config.Version,
 		NetworkStats:            netStats,
@@ -37,8 +46,13 @@ func (h *Handler) GetMyPeerInfo(c echo.Context) (err error) {
 		TotalBootstrapPeers:     totalBootstraps,
 		ConnectedBootstrapPeers: connectedBootstraps,
 		Reachability:            h.p2p.Reachability().String(),
+		OfflineMode:             offlineMode,
+		PrivateNetwork:          h.p2p.IsPrivateNetwork(),
+		RelayServiceEnabled:     h.p2p.IsRelayServiceEnabled(),
+		SecretsEncryption:       h.conf.SecretsProvider(),
 		AwlDNSAddress:           h.dns.AwlDNSAddress(),
 		IsAwlDNSSetAsSystem:     h.dns.IsAwlDNSSetAsSystem(),
+		Capabilities:            h.authStatus.Capabilities(),
 		VPN: entity.VPNInfo{
 			VPNInterfaceEnabled: h.tunnel != nil,
 			InterfaceName:       vpnConfig.InterfaceName,
@@ -105,6 +119,7 @@ func (h *Handler) GetMyPeerInfo(c echo.Context) (err error) {
 // @Produce	json
 // @Param		body	body	entity.UpdateMySettingsRequest	true	"Params"
 // @Success	200		"OK"
+// @Failure	400		{object}	api.Error
 // @Router		/settings/update [POST]
 func (h *Handler) UpdateMySettings(c echo.Context) (err error) {
 	req := entity.UpdateMySettingsRequest{}
@@ -117,7 +132,26 @@ func (h *Handler) UpdateMySettings(c echo.Context) (err error) {
 	}
 
 	h.conf.Lock()
+	domainName, domainAliases := h.conf.P2pNode.DomainName, h.conf.P2pNode.DomainAliases
+	if req.DomainName != nil {
+		domainName = *req.DomainName
+		if domainName == "" {
+			domainAliases = nil
+		}
+	}
+	if req.DomainAliases != nil {
+		domainAliases = req.DomainAliases
+	}
+	if domainErr := config.ValidateDomainNames(domainName, domainAliases); domainErr != nil {
+		h.conf.Unlock()
+		return c.JSON(http.StatusBadRequest, ErrorMessage(domainErr.Error()))
+	}
 	h.conf.P2pNode.Name = req.Name
+	if req.DeviceInfo != nil {
+		h.conf.P2pNode.DeviceInfo = *req.DeviceInfo
+	}
+	h.conf.P2pNode.DomainName = domainName
+	h.conf.P2pNode.DomainAliases = domainAliases
 	h.conf.Unlock()
 	h.conf.Save()
 
@@ -186,3 +220,161 @@ func (h *Handler) UpdateProxySettings(c echo.Context) (err error) {
 
 	return c.NoContent(http.StatusOK)
 }
+
+// @Tags		Settings
+// @Summary	Get private network settings
+// @Accept		json
+// @Produce	json
+// @Success	200	{object}	entity.PrivateNetworkInfo
+// @Router		/settings/private_network [GET]
+func (h *Handler) GetPrivateNetwork(c echo.Context) (err error) {
+	h.conf.RLock()
+	info := entity.PrivateNetworkInfo{
+		Enabled:           h.conf.P2pNode.PrivateNetworkKey != "",
+		Active:            h.p2p.IsPrivateNetwork(),
+		NetworkKey:        h.conf.P2pNode.PrivateNetworkKey,
+		DHTProtocolPrefix: h.conf.P2pNode.DHTProtocolPrefix,
+		BootstrapPeers:    slices.Clone(h.conf.P2pNode.BootstrapPeers),
+	}
+	h.conf.RUnlock()
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// UpdatePrivateNetwork sets network key, DHT prefix and bootstrap peers of private swarm.
+// Changes are applied after restart.
+//
+// @Tags		Settings
+// @Summary	Update private network settings
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.UpdatePrivateNetworkRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Router		/settings/private_network/update [POST]
+func (h *Handler) UpdatePrivateNetwork(c echo.Context) (err error) {
+	req := entity.UpdatePrivateNetworkRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+
+	networkKey := ""
+	if req.NetworkKey != "" {
+		psk, err := config.ParsePrivateNetworkKey(req.NetworkKey)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+		}
+		networkKey = hex.EncodeToString(psk)
+	}
+	err = config.ValidateDHTProtocolPrefix(req.DHTProtocolPrefix)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	for _, addr := range req.BootstrapPeers {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorMessage(fmt.Sprintf("invalid bootstrap peer %q: %v", addr, err)))
+		}
+		if config.IsBootstrapDNSAddr(maddr) {
+			continue
+		}
+		_, err = peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorMessage(fmt.Sprintf("invalid bootstrap peer %q: %v", addr, err)))
+		}
+	}
+
+	h.conf.Lock()
+	h.conf.P2pNode.PrivateNetworkKey = networkKey
+	h.conf.P2pNode.DHTProtocolPrefix = req.DHTProtocolPrefix
+	if req.BootstrapPeers != nil {
+		h.conf.P2pNode.BootstrapPeers = req.BootstrapPeers
+	}
+	h.conf.Unlock()
+	h.conf.Save()
+
+	return c.NoContent(http.StatusOK)
+}
+
+// GeneratePrivateNetworkKey returns new random network key, it isn't saved to config.
+//
+// @Tags		Settings
+// @Summary	Generate private network key
+// @Accept		json
+// @Produce	json
+// @Success	200	{object}	entity.GeneratePrivateNetworkKeyResponse
+// @Router		/settings/private_network/generate_key [POST]
+func (h *Handler) GeneratePrivateNetworkKey(c echo.Context) (err error) {
+	key, err := config.GeneratePrivateNetworkKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorMessage(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, entity.GeneratePrivateNetworkKeyResponse{NetworkKey: key})
+}
+
+// RotateIdentity generates new identity key, it's applied after restart. Known peers are notified with
+// the rotation signed by the current key, when we connect with the new peer ID they keep alias, IP address
+// and permissions for it.
+//
+// @Tags		Settings
+// @Summary	Rotate identity key
+// @Accept		json
+// @Produce	json
+// @Success	200	{object}	entity.RotateIdentityResponse
+// @Failure	500	{object}	api.Error
+// @Router		/settings/rotate_identity [POST]
+func (h *Handler) RotateIdentity(c echo.Context) (err error) {
+	newPeerID, err := h.authStatus.RotateIdentity()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorMessage(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, entity.RotateIdentityResponse{NewPeerID: newPeerID.String()})
+}
+
+// UpdateSecretsEncryption encrypts identity keys and passwords in config file with the key from provider,
+// changes the passphrase or disables encryption. Config encrypted by passphrase asks for it on startup
+// or reads it from AWL_PASSPHRASE environment variable.
+//
+// @Tags		Settings
+// @Summary	Update encryption of secrets in config file
+// @Accept		json
+// @Produce	json
+// @Param		body	body	entity.UpdateSecretsEncryptionRequest	true	"Params"
+// @Success	200		"OK"
+// @Failure	400		{object}	api.Error
+// @Failure	500		{object}	api.Error
+// @Router		/settings/secrets/update [POST]
+func (h *Handler) UpdateSecretsEncryption(c echo.Context) (err error) {
+	req := entity.UpdateSecretsEncryptionRequest{}
+	err = c.Bind(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if err = c.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	}
+	if h.conf.SecretsProvider() == config.SecretsProviderPassphrase && !h.conf.CheckPassphrase(req.CurrentPassphrase) {
+		return c.JSON(http.StatusBadRequest, ErrorMessage("invalid current passphrase"))
+	}
+	if req.Provider == config.SecretsProviderPassphrase && req.Passphrase == "" {
+		return c.JSON(http.StatusBadRequest, ErrorMessage("passphrase is required"))
+	}
+	if req.Provider == config.SecretsProviderKeyFile && req.KeyFile == "" {
+		return c.JSON(http.StatusBadRequest, ErrorMessage("key file is required"))
+	}
+
+	err = h.conf.SetSecretsEncryption(req.Provider, req.Passphrase, req.KeyFile)
+	if errors.Is(err, config.ErrSecretsProviderUnsupported) {
+		return c.JSON(http.StatusBadRequest, ErrorMessage(err.Error()))
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorMessage(err.Error()))
+	}
+
+	return c.NoContent(http.StatusOK)
+}
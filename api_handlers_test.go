@@ -58,6 +58,23 @@ func TestGetP2pDebugInfo(t *testing.T) {
 	ts.NotEmpty(debugInfo.DHT.Reachability)
 }
 
+func TestGetNetcheck(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+
+	report, err := peer1.api.Netcheck()
+	ts.NoError(err)
+	ts.NotEmpty(report.NATType)
+	ts.NotEmpty(report.Reachability)
+	ts.NotEmpty(report.UDP.Status)
+	ts.NotEmpty(report.TCP.Status)
+	ts.True(report.PortMapping.Enabled)
+	ts.NotNil(report.BootstrapPeers)
+	ts.NotNil(report.HolePunching)
+	ts.Nil(report.UpstreamProxy)
+}
+
 func TestGetDebugLog(t *testing.T) {
 	ts := NewTestSuite(t)
 
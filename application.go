@@ -2,13 +2,18 @@ package awl
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
+	"math"
 	"net"
 	"net/netip"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -17,13 +22,19 @@ import (
 	"github.com/anywherelan/ts-dns/net/dns"
 	"github.com/anywherelan/ts-dns/util/dnsname"
 	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	libp2pProtocol "github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoreds"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -44,6 +55,9 @@ import (
 
 const (
 	logBufSize = 1 << 20
+
+	peerstoreSnapshotFile      = "datastore.bin"
+	peerstoreSnapshotFlushTime = 5 * time.Minute
 )
 
 //go:embed static
@@ -85,11 +99,14 @@ type Application struct {
 	ctx        context.Context
 	ctxCancel  context.CancelFunc
 	vpnDevice  *vpn.Device
+	datastore  *p2p.SnapshotDatastore
 	P2p        *p2p.P2p
 	Api        *api.Handler
 	AuthStatus *service.AuthStatus
+	Presence   *service.Presence
 	Tunnel     *service.Tunnel
 	SOCKS5     *service.SOCKS5
+	SpeedTest  *service.SpeedTest
 	VPNGateway *service.VPNGateway
 	Dns        *DNSService
 
@@ -113,8 +130,15 @@ func (a *Application) Init(ctx context.Context, tunDevice tun.Device) error {
 	if a.SockMarker == nil {
 		a.SockMarker = sockmark.New()
 	}
+	if a.Conf.ApplyNextIdentity() {
+		a.logger.Info("Switched to the rotated identity key")
+	}
+	hostConfig, err := a.makeP2pHostConfig()
+	if err != nil {
+		return err
+	}
 	a.P2p = p2p.NewP2p(a.ctx)
-	p2pHost, err := a.P2p.InitHost(a.makeP2pHostConfig())
+	p2pHost, err := a.P2p.InitHost(hostConfig)
 	if err != nil {
 		return err
 	}
@@ -143,10 +167,12 @@ func (a *Application) Init(ctx context.Context, tunDevice tun.Device) error {
 
 	a.Dns = NewDNSService(a.Conf, a.Eventbus, a.ctx, a.logger)
 	a.AuthStatus = service.NewAuthStatus(a.P2p, a.Conf, a.Eventbus)
+	a.Presence = service.NewPresence(a.P2p, a.Conf, a.Eventbus)
 	a.SOCKS5, err = service.NewSOCKS5(a.P2p, a.Conf, a.SockMarker)
 	if err != nil {
 		return fmt.Errorf("failed to init socks5: %v", err)
 	}
+	a.SpeedTest = service.NewSpeedTest(a.P2p, a.Conf)
 
 	p2pHost.SetStreamHandler(protocol.GetStatusMethod, a.AuthStatus.StatusStreamHandler)
 	p2pHost.SetStreamHandler(protocol.AuthMethod, a.AuthStatus.AuthStreamHandler)
@@ -155,25 +181,41 @@ func (a *Application) Init(ctx context.Context, tunDevice tun.Device) error {
 	}
 	p2pHost.SetStreamHandler(protocol.Socks5PacketMethod, a.SOCKS5.ProxyStreamHandler)
 	p2pHost.SetStreamHandler(protocol.Socks5NoAuthMethod, a.SOCKS5.ProxyStreamHandler)
+	p2pHost.SetStreamHandler(protocol.SpeedTestMethod, a.SpeedTest.StreamHandler)
 
 	if a.Tunnel != nil {
 		awlevent.WrapSubscriptionToCallback(a.ctx, func(_ interface{}) {
 			a.Tunnel.RefreshPeersList()
-		}, a.Eventbus, new(awlevent.KnownPeerChanged))
+		}, a.Eventbus, []interface{}{new(awlevent.KnownPeerChanged), new(awlevent.PeerAccessChanged)})
 	}
 
+	awlevent.WrapSubscriptionToCallback(a.ctx, func(_ interface{}) {
+		a.P2p.UpdateRelayService()
+	}, a.Eventbus, []interface{}{new(awlevent.KnownPeerChanged), new(awlevent.PeerAccessChanged)})
+	awlevent.WrapSubscriptionToCallback(a.ctx, func(_ interface{}) {
+		a.Presence.RefreshKnownPeers()
+		a.P2p.RetainConnectionQuality(a.Conf.KnownPeersIds())
+	}, a.Eventbus, new(awlevent.KnownPeerChanged))
+	awlevent.WrapSubscriptionToCallback(a.ctx, func(evt interface{}) {
+		a.Presence.SendWebhooks(a.ctx, evt.(awlevent.PeerPresenceChanged))
+	}, a.Eventbus, new(awlevent.PeerPresenceChanged))
+
 	a.VPNGateway = service.NewVPNGateway(a.Conf, a.Tunnel, a.vpnDevice, a.P2p, a.SockMarker, a.Dns, a.DisableGatewayOSSetup)
 
-	handler := api.NewHandler(a.Conf, a.P2p, a.AuthStatus, a.Tunnel, a.SOCKS5, a.LogBuffer, a.Dns, a.VPNGateway)
+	handler := api.NewHandler(a.Conf, a.P2p, a.AuthStatus, a.Tunnel, a.SOCKS5, a.SpeedTest, a.LogBuffer, a.Dns, a.VPNGateway, a.Presence)
 	a.Api = handler
 	err = handler.SetupAPI()
 	if err != nil {
 		return fmt.Errorf("failed to setup api: %v", err)
 	}
 
-	go a.P2p.MaintainBackgroundConnections(a.ctx, a.Conf.P2pNode.ReconnectionIntervalSec*time.Second, a.Conf.KnownPeersIds)
+	go a.P2p.MaintainBackgroundConnections(a.ctx, a.Conf.P2pNode.ReconnectionIntervalSec*time.Second, a.Conf.KnownPeersAddrInfos)
+	go a.P2p.MaintainBootstrapPeers(a.ctx)
+	go a.P2p.MonitorConnectionQuality(a.ctx, a.Conf.P2pNode.QualityProbeIntervalSec*time.Second, a.Conf.KnownPeersIds)
 	go a.AuthStatus.BackgroundRetryAuthRequests(a.ctx)
 	go a.AuthStatus.BackgroundExchangeStatusInfo(a.ctx)
+	go a.AuthStatus.BackgroundEnforcePeerAccess(a.ctx)
+	go a.Presence.BackgroundSaveHistory(a.ctx)
 	go a.SOCKS5.ServeConns(a.ctx)
 
 	if !a.Conf.DNS.DisableDNS && !a.Conf.VPNConfig.DisableVPNInterface {
@@ -201,10 +243,13 @@ func (a *Application) Init(ctx context.Context, tunDevice tun.Device) error {
 	return nil
 }
 
-func (a *Application) SetupLoggerAndConfig(appType config.AppType) *log.ZapEventLogger {
+// SetupLoggerAndConfig returns an error when config secrets are locked, Conf isn't set then,
+// so the encrypted config isn't overwritten by a new one.
+func (a *Application) SetupLoggerAndConfig(appType config.AppType) (*log.ZapEventLogger, error) {
 	a.Eventbus = eventbus.NewBus()
 	// Config
 	conf, loadConfigErr := config.LoadConfig(appType, a.Eventbus)
+	secretsLocked := errors.Is(loadConfigErr, config.ErrSecretsLocked)
 	if loadConfigErr != nil {
 		conf = config.NewConfig(appType, a.Eventbus)
 	}
@@ -244,6 +289,9 @@ func (a *Application) SetupLoggerAndConfig(appType config.AppType) *log.ZapEvent
 	)
 
 	a.logger = log.Logger("awl")
+	if secretsLocked {
+		return a.logger, fmt.Errorf("failed to read config file: %w", loadConfigErr)
+	}
 	a.Conf = conf
 
 	if loadConfigErr != nil {
@@ -252,7 +300,7 @@ func (a *Application) SetupLoggerAndConfig(appType config.AppType) *log.ZapEvent
 	a.logger.Infof("Anywherelan %s (%s %s-%s)", config.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 	a.logger.Infof("Initializing app in %s directory", conf.DataDir())
 
-	return a.logger
+	return a.logger, nil
 }
 
 func (a *Application) Ctx() context.Context {
@@ -283,6 +331,9 @@ func (a *Application) Close() {
 	if a.SOCKS5 != nil {
 		a.SOCKS5.Close()
 	}
+	if a.Presence != nil {
+		a.Presence.Close()
+	}
 
 	if a.P2p != nil {
 		err := a.P2p.Close()
@@ -290,6 +341,12 @@ func (a *Application) Close() {
 			a.logger.Errorf("closing p2p server: %v", err)
 		}
 	}
+	if a.datastore != nil {
+		err := a.datastore.Close()
+		if err != nil {
+			a.logger.Errorf("closing peerstore datastore: %v", err)
+		}
+	}
 	if a.Dns != nil {
 		a.Dns.Close()
 	}
@@ -302,50 +359,235 @@ func (a *Application) Close() {
 	a.Conf.Save()
 }
 
-func (a *Application) makeP2pHostConfig() p2p.HostConfig {
-	// TODO: use persistent datastore. Check out badger2. Old badger datastore constantly use disk io
-	peerstore, err := pstoremem.NewPeerstore()
+func (a *Application) makeP2pHostConfig() (p2p.HostConfig, error) {
+	privateNetworkKey, err := a.Conf.PrivateNetworkKey()
 	if err != nil {
-		panic(err)
+		return p2p.HostConfig{}, fmt.Errorf("invalid private network key: %v", err)
+	}
+	a.Conf.RLock()
+	dhtProtocolPrefix := a.Conf.P2pNode.DHTProtocolPrefix
+	upstreamProxyConf := a.Conf.P2pNode.UpstreamProxy
+	webSocketTLSConf := a.Conf.P2pNode.WebSocketTLS
+	resourceLimitsConf := a.Conf.P2pNode.ResourceLimits
+	connManagerConf := a.Conf.P2pNode.ConnManager
+	bootstrapListConf := a.Conf.P2pNode.BootstrapList
+	a.Conf.RUnlock()
+	err = config.ValidateDHTProtocolPrefix(dhtProtocolPrefix)
+	if err != nil {
+		return p2p.HostConfig{}, fmt.Errorf("invalid dht protocol prefix: %v", err)
 	}
-
-	resourceLimitsConfig := rcmgr.InfiniteLimits
-	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(resourceLimitsConfig))
+	var upstreamProxy *p2p.UpstreamProxy
+	if upstreamProxyConf.URL != "" {
+		upstreamProxy, err = p2p.NewUpstreamProxy(upstreamProxyConf.URL, upstreamProxyConf.Exceptions)
+		if err != nil {
+			return p2p.HostConfig{}, fmt.Errorf("invalid upstream proxy: %v", err)
+		}
+	}
+	var bootstrapList *p2p.BootstrapListSource
+	if bootstrapListConf.URL != "" {
+		bootstrapList, err = p2p.NewBootstrapListSource(bootstrapListConf.URL, bootstrapListConf.SignerID)
+		if err != nil {
+			return p2p.HostConfig{}, fmt.Errorf("invalid bootstrap list config: %v", err)
+		}
+	}
+	listenAddrs := a.Conf.GetListenAddresses()
+	webSocketTLSConfig, err := makeWebSocketTLSConfig(webSocketTLSConf, listenAddrs)
 	if err != nil {
-		panic(err)
+		return p2p.HostConfig{}, fmt.Errorf("invalid websocket tls config: %v", err)
+	}
+
+	peerstore, dhtDatastore := a.openPeerstore()
+
+	online := !a.Conf.OfflineMode
+	bootstrapPeers := a.Conf.GetBootstrapPeers()
+	bootstrapDNSAddrs := a.Conf.GetBootstrapDNSAddrs()
+	libp2pOpts := []libp2p.Option{
+		libp2p.PrometheusRegisterer(prometheus.DefaultRegisterer),
+	}
+	if online {
+		libp2pOpts = append(libp2pOpts, libp2p.EnableRelay(), libp2p.EnableAutoNATv2())
+	} else {
+		libp2pOpts = append(libp2pOpts, libp2p.DisableRelay())
+		a.logger.Info("Offline mode: public DHT, relays and update checks are disabled")
+		if a.Conf.P2pNode.DisableMDNS {
+			a.logger.Warn("Offline mode: mdns is disabled, known peers are reachable only by static addresses")
+		}
+		bootstrapPeers = nil
+		bootstrapDNSAddrs = nil
+		bootstrapList = nil
+	}
+	a.Conf.RLock()
+	relayServiceConf := a.Conf.RelayService
+	a.Conf.RUnlock()
+	// relay service is useless without public reachability
+	enableRelayService := relayServiceConf.Enabled && online
+	if enableRelayService {
+		a.logger.Info("Relay service for known peers is enabled")
+	}
+	// relay service also runs for known peers which are allowed to use us as relay, permissions are changed at runtime
+	var isRelayAllowedForPeer func(peer.ID) bool
+	var isRelayServiceUsed func() bool
+	if online {
+		isRelayAllowedForPeer = func(peerID peer.ID) bool {
+			return a.Conf.IsRelayAllowedForPeer(peerID.String())
+		}
+		isRelayServiceUsed = a.Conf.IsRelayServiceUsed
+	}
+	if upstreamProxy != nil {
+		if online {
+			a.logger.Infof("Outbound TCP connections are dialed via upstream proxy %s, QUIC is dialed directly", upstreamProxy)
+		} else {
+			upstreamProxy = nil
+			a.logger.Info("Offline mode: upstream proxy is disabled")
+		}
+	}
+	privateNetwork := len(privateNetworkKey) > 0
+	if privateNetwork {
+		a.logger.Info("Private network mode: only peers with the same network key can connect, QUIC transport is disabled")
+		if online && len(bootstrapPeers) == 0 && len(bootstrapDNSAddrs) == 0 && bootstrapList == nil {
+			a.logger.Warn("Private network mode: bootstrap peers from the private network are not set")
+		}
 	}
 
 	return p2p.HostConfig{
 		PrivKeyBytes:             a.Conf.PrivKey(),
-		ListenAddrs:              a.Conf.GetListenAddresses(),
+		ListenAddrs:              listenAddrs,
 		UserAgent:                config.UserAgent,
-		BootstrapPeers:           a.Conf.GetBootstrapPeers(),
-		AllowEmptyBootstrapPeers: a.AllowEmptyBootstrapPeers,
-		EnableAutoRelay:          true,
+		BootstrapPeers:           bootstrapPeers,
+		AllowEmptyBootstrapPeers: a.AllowEmptyBootstrapPeers || a.Conf.OfflineMode || privateNetwork,
+		BootstrapDNSAddrs:        bootstrapDNSAddrs,
+		BootstrapList:            bootstrapList,
+		EnableAutoRelay:          online,
+		EnableNATPortMap:         online,
+		EnableHolePunching:       online,
+		EnableMDNS:               !a.Conf.P2pNode.DisableMDNS,
+		IsKnownPeer: func(peerID peer.ID) bool {
+			_, known := a.Conf.GetPeer(peerID.String())
+			return known
+		},
 		// SocketControlFunc is always used: marking happens at dial
 		// time on every socket, so libp2p connections opened *before* gateway
 		// mode is toggled on at runtime are already exempt from the VPN route.
-		SocketControlFunc: a.SockMarker.ControlFunc(),
-		Libp2pOpts: append([]libp2p.Option{
-			libp2p.EnableRelay(),
-			libp2p.EnableAutoNATv2(),
-			libp2p.ResourceManager(mgr),
-			libp2p.EnableHolePunching(),
-			libp2p.NATPortMap(),
-			libp2p.PrometheusRegisterer(prometheus.DefaultRegisterer),
-		}, a.ExtraLibp2pOpts...),
+		SocketControlFunc:     a.SockMarker.ControlFunc(),
+		UpstreamProxy:         upstreamProxy,
+		WebSocketTLSConfig:    webSocketTLSConfig,
+		PrivateNetworkKey:     privateNetworkKey,
+		DHTProtocolPrefix:     libp2pProtocol.ID(dhtProtocolPrefix),
+		EnableRelayService:    enableRelayService,
+		IsRelayAllowedForPeer: isRelayAllowedForPeer,
+		IsRelayServiceUsed:    isRelayServiceUsed,
+		RelayServiceResources: makeRelayServiceResources(relayServiceConf),
+		TrustedRelays:         a.Conf.RelayServicePeers,
+		Libp2pOpts:            append(libp2pOpts, a.ExtraLibp2pOpts...),
+		ResourceLimits:        a.makeResourceLimits(resourceLimitsConf),
 		ConnManager: struct {
 			LowWater    int
 			HighWater   int
 			GracePeriod time.Duration
 		}{
-			LowWater:    50,
-			HighWater:   100,
-			GracePeriod: time.Minute,
+			LowWater:    connManagerConf.LowWater,
+			HighWater:   connManagerConf.HighWater,
+			GracePeriod: time.Duration(connManagerConf.GracePeriodSec) * time.Second,
 		},
 		Peerstore:    peerstore,
-		DHTDatastore: dssync.MutexWrap(ds.NewMapDatastore()),
+		DHTDatastore: dhtDatastore,
+	}, nil
+}
+
+func makeWebSocketTLSConfig(conf config.WebSocketTLSConfig, listenAddrs []multiaddr.Multiaddr) (*tls.Config, error) {
+	if conf.CertFile == "" && conf.KeyFile == "" {
+		if slices.ContainsFunc(listenAddrs, p2p.IsSecureWebSocketAddr) {
+			return nil, errors.New("certificate is required for secure websocket listen address")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+var protocolsByLimitsName = map[string][]libp2pProtocol.ID{
+	config.ProtocolLimitsTunnel:    {protocol.TunnelPacketMethod},
+	config.ProtocolLimitsSOCKS5:    {protocol.Socks5PacketMethod, protocol.Socks5NoAuthMethod},
+	config.ProtocolLimitsStatus:    {protocol.GetStatusMethod},
+	config.ProtocolLimitsAuth:      {protocol.AuthMethod},
+	config.ProtocolLimitsSpeedTest: {protocol.SpeedTestMethod},
+}
+
+func (a *Application) makeResourceLimits(conf config.ResourceLimitsConfig) *rcmgr.ConcreteLimitConfig {
+	if conf.Disabled {
+		a.logger.Warn("Resource limits are disabled")
+		return nil
+	}
+
+	protocolLimits := make(map[libp2pProtocol.ID]p2p.ProtocolLimits)
+	for name, limits := range conf.Protocols {
+		protocols, ok := protocolsByLimitsName[name]
+		if !ok {
+			a.logger.Warnf("Unknown protocol %q in resource limits", name)
+			continue
+		}
+		for _, proto := range protocols {
+			protocolLimits[proto] = p2p.ProtocolLimits{Streams: limits.Streams, StreamsPerPeer: limits.StreamsPerPeer}
+		}
+	}
+	limits := p2p.ScaledResourceLimits(conf.MaxMemoryMiB<<20, conf.MaxFileDescriptors, protocolLimits)
+	return &limits
+}
+
+func makeRelayServiceResources(conf config.RelayServiceConfig) relayv2.Resources {
+	resources := relayv2.DefaultResources()
+	resources.MaxReservations = conf.MaxReservations
+	resources.MaxCircuits = conf.MaxCircuits
+	// known peers may share public IP, e.g. devices in the same home network
+	resources.MaxReservationsPerIP = conf.MaxReservations
+	resources.MaxReservationsPerASN = conf.MaxReservations
+	// without limits relayed connections are not marked as limited, so they can be used for all protocols
+	resources.Limit = nil
+	if conf.MaxCircuitDurationSec > 0 || conf.MaxCircuitMiB > 0 {
+		// libp2p applies both limits, zero one is replaced with a value which is never reached in practice
+		resources.Limit = &relayv2.RelayLimit{
+			Duration: 365 * 24 * time.Hour,
+			Data:     math.MaxInt64,
+		}
+		if conf.MaxCircuitDurationSec > 0 {
+			resources.Limit.Duration = time.Duration(conf.MaxCircuitDurationSec) * time.Second
+		}
+		if conf.MaxCircuitMiB > 0 {
+			resources.Limit.Data = int64(conf.MaxCircuitMiB) << 20
+		}
+	}
+	return resources
+}
+
+// openPeerstore returns peerstore and DHT datastore persisted to a single snapshot file in peerstore directory.
+// It falls back to in-memory stores if the snapshot can't be opened.
+func (a *Application) openPeerstore() (peerstore.Peerstore, ds.Batching) {
+	datastore, err := p2p.OpenSnapshotDatastore(filepath.Join(a.Conf.PeerstoreDir(), peerstoreSnapshotFile), p2p.SnapshotDatastoreOptions{
+		// addresses are the most valuable for reconnecting after restart, DHT records are the least
+		PriorityPrefixes: []string{"/peerstore/peers/addrs", "/dht/awl", "/peerstore"},
+		AfterSave:        config.ChownFileIfNeeded,
+	})
+	if err == nil {
+		var ps peerstore.Peerstore
+		ps, err = pstoreds.NewPeerstore(a.ctx, namespace.Wrap(datastore, ds.NewKey("/peerstore")), pstoreds.DefaultOpts())
+		if err == nil {
+			a.datastore = datastore
+			go datastore.RunFlusher(a.ctx, peerstoreSnapshotFlushTime)
+			return ps, namespace.Wrap(datastore, ds.NewKey("/dht"))
+		}
+		_ = datastore.Close()
+	}
+	a.logger.Errorf("failed to open persistent peerstore, using in-memory one: %v", err)
+
+	ps, err := pstoremem.NewPeerstore()
+	if err != nil {
+		panic(err)
 	}
+	return ps, dssync.MutexWrap(ds.NewMapDatastore())
 }
 
 type DNSService struct {
@@ -407,7 +649,7 @@ func (a *DNSService) initDNS(interfaceName string) {
 		a.mu.Lock()
 		defer a.mu.Unlock()
 		a.refreshDNSConfigLocked()
-	}, a.eventbus, new(awlevent.KnownPeerChanged))
+	}, a.eventbus, []interface{}{new(awlevent.KnownPeerChanged), new(awlevent.PeerAccessChanged)})
 
 	tsLogger := log.Logger("ts/dnsconf")
 	a.dnsOsConfigurator, err = dns.NewOSConfigurator(func(format string, args ...interface{}) {
@@ -2,19 +2,40 @@ package awl
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"maps"
+	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	relayclient "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
+	relayproto "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/quic-go/quic-go/integrationtests/tools/israce"
 	"golang.org/x/net/proxy"
 
@@ -22,7 +43,9 @@ import (
 	"github.com/anywherelan/awl/awldns"
 	"github.com/anywherelan/awl/config"
 	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/p2p"
 	"github.com/anywherelan/awl/protocol"
+	"github.com/anywherelan/awl/service"
 )
 
 func TestMakeFriends(t *testing.T) {
@@ -160,6 +183,204 @@ func TestAutoAcceptFriendRequest(t *testing.T) {
 	ts.False(knownPeer.Declined)
 }
 
+func TestJoinWithInvite(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+	peer3 := ts.NewTestPeer(false)
+	ts.ensurePeersAvailableInDHT(peer1, peer2)
+	ts.ensurePeersAvailableInDHT(peer1, peer3)
+
+	invite, err := peer1.api.CreateInvite(entity.CreateInviteRequest{AllowUsingAsRelay: true})
+	ts.NoError(err)
+	ts.Equal(1, invite.MaxUses)
+	ts.True(strings.HasPrefix(invite.URI, protocol.InviteURIPrefix))
+
+	invites, err := peer1.api.Invites()
+	ts.NoError(err)
+	ts.Len(invites, 1)
+
+	peerID, err := peer2.api.JoinInvite(invite.URI, "", "")
+	ts.NoError(err)
+	ts.Equal(peer1.PeerID(), peerID)
+
+	ts.Eventually(func() bool {
+		knownPeer, exists := peer2.app.Conf.GetPeer(peer1.PeerID())
+		return exists && knownPeer.Confirmed
+	}, 15*time.Second, 50*time.Millisecond)
+
+	knownPeer, exists := peer1.app.Conf.GetPeer(peer2.PeerID())
+	ts.True(exists)
+	ts.True(knownPeer.Confirmed)
+	ts.True(knownPeer.WeAllowUsingAsRelay)
+	ts.False(knownPeer.WeAllowUsingAsExitNode)
+	authRequests, err := peer1.api.AuthRequests()
+	ts.NoError(err)
+	ts.Empty(authRequests)
+
+	knownPeer, _ = peer2.app.Conf.GetPeer(peer1.PeerID())
+	ts.NotEmpty(knownPeer.Alias)
+	ts.Empty(knownPeer.Invite)
+
+	// one-shot invite is exhausted, so the request falls back to manual confirmation
+	invites, err = peer1.api.Invites()
+	ts.NoError(err)
+	ts.Empty(invites)
+
+	_, err = peer3.api.JoinInvite(invite.URI, "peer_1", "")
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		authRequests, err = peer1.api.AuthRequests()
+		ts.NoError(err)
+		return len(authRequests) == 1
+	}, 15*time.Second, 50*time.Millisecond)
+	_, exists = peer1.app.Conf.GetPeer(peer3.PeerID())
+	ts.False(exists)
+
+	// revoked invite
+	invite, err = peer1.api.CreateInvite(entity.CreateInviteRequest{})
+	ts.NoError(err)
+	ts.NoError(peer1.api.RevokeInvite(invite.ID))
+	ts.Error(peer1.api.RevokeInvite(invite.ID))
+	_, err = peer1.api.JoinInvite(invite.URI, "", "")
+	ts.ErrorContains(err, "own invite")
+}
+
+func TestAuthRules(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+	peer3 := ts.NewTestPeer(false)
+	peer4 := ts.NewTestPeer(false)
+	ts.makeFriends(peer1, peer2)
+	ts.makeFriendsWithAliases(peer2, peer3, "peer_2", "peer_3")
+	ts.ensurePeersAvailableInDHT(peer1, peer3)
+	ts.ensurePeersAvailableInDHT(peer1, peer4)
+
+	// peer3 receives vouch from peer2 in status exchange
+	ts.Eventually(func() bool {
+		knownPeer, _ := peer3.app.Conf.GetPeer(peer2.PeerID())
+		return knownPeer.Vouch != ""
+	}, 15*time.Second, 50*time.Millisecond)
+
+	err := peer1.api.UpdateAuthRules([]config.AuthRule{{Name: "bad", Action: "allow"}})
+	ts.ErrorContains(err, "unknown action")
+	rules := []config.AuthRule{
+		{Name: "friends of friends", Vouched: true, Action: config.AuthRuleActionAccept, AllowUsingAsRelay: true},
+		{Name: "strangers", Action: config.AuthRuleActionReject},
+	}
+	err = peer1.api.UpdateAuthRules(rules)
+	ts.NoError(err)
+	gotRules, err := peer1.api.AuthRules()
+	ts.NoError(err)
+	ts.Equal(rules, gotRules)
+
+	err = peer3.api.SendFriendRequest(peer1.PeerID(), "peer_1", "")
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		knownPeer, exists := peer3.app.Conf.GetPeer(peer1.PeerID())
+		return exists && knownPeer.Confirmed
+	}, 15*time.Second, 50*time.Millisecond)
+	knownPeer, exists := peer1.app.Conf.GetPeer(peer3.PeerID())
+	ts.True(exists)
+	ts.True(knownPeer.WeAllowUsingAsRelay)
+
+	err = peer4.api.SendFriendRequest(peer1.PeerID(), "peer_1", "")
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		knownPeer, _ := peer4.app.Conf.GetPeer(peer1.PeerID())
+		return knownPeer.Declined
+	}, 15*time.Second, 50*time.Millisecond)
+	_, exists = peer1.app.Conf.GetPeer(peer4.PeerID())
+	ts.False(exists)
+	authRequests, err := peer1.api.AuthRequests()
+	ts.NoError(err)
+	ts.Empty(authRequests)
+
+	decisions, err := peer1.api.AuthDecisions()
+	ts.NoError(err)
+	ts.GreaterOrEqual(len(decisions), 2)
+	ts.Equal(peer4.PeerID(), decisions[0].PeerID)
+	ts.Equal("strangers", decisions[0].Rule)
+	ts.Equal(config.AuthRuleActionReject, decisions[0].Action)
+	accepted := decisions[len(decisions)-1]
+	ts.Equal(peer3.PeerID(), accepted.PeerID)
+	ts.Equal("friends of friends", accepted.Rule)
+	ts.Equal(config.AuthRuleActionAccept, accepted.Action)
+	ts.Equal([]string{peer2.PeerID()}, accepted.VouchedBy)
+
+	// peer2 stops vouching for peer3 when its access is suspended
+	peer3Config, err := peer2.api.KnownPeerConfig(peer3.PeerID())
+	ts.NoError(err)
+	err = peer2.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:     peer3.PeerID(),
+		Alias:      peer3Config.Alias,
+		DomainName: peer3Config.DomainName,
+		IPAddr:     peer3Config.IPAddr,
+		Access:     &config.PeerAccess{ExpiresAt: time.Now().Add(-time.Minute)},
+	})
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		knownPeer, _ := peer3.app.Conf.GetPeer(peer2.PeerID())
+		return knownPeer.Vouch == ""
+	}, 15*time.Second, 50*time.Millisecond)
+}
+
+func TestAuthRequestLimits(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.AuthRequestLimits.PeerRequestsPerHour = 4
+		c.P2pNode.AuthRequestLimits.MaxPending = 1
+		c.P2pNode.AuthRequestLimits.ProofOfWorkBits = 8
+		c.P2pNode.AuthRequestLimits.SilentDrop = true
+	})
+	peer2 := ts.NewTestPeer(false)
+	peer3 := ts.NewTestPeer(false)
+	peer4 := ts.NewTestPeer(false)
+	ts.ensurePeersAvailableInDHT(peer1, peer2)
+	ts.ensurePeersAvailableInDHT(peer1, peer3)
+	ts.ensurePeersAvailableInDHT(peer1, peer4)
+
+	sendAuth := func(from TestPeer) error {
+		req := protocol.AuthPeer{Name: from.app.Conf.P2pNode.Name}
+		return from.app.AuthStatus.SendAuthRequest(context.Background(), peer1.app.P2p.PeerID(), req)
+	}
+
+	// request is queued only after proof of work is solved, next ones are dropped by rate limit
+	ts.NoError(sendAuth(peer2))
+	ts.Contains(peer1.app.AuthStatus.GetIngoingAuthRequests(), peer2.PeerID())
+	ts.NotEmpty(peer1.app.AuthStatus.GetIngoingAuthRequests()[peer2.PeerID()].ProofOfWork)
+	var err error
+	for range 5 {
+		err = sendAuth(peer2)
+		if err != nil {
+			break
+		}
+	}
+	ts.Error(err)
+
+	// the oldest request is evicted
+	ts.NoError(sendAuth(peer3))
+	requests := peer1.app.AuthStatus.GetIngoingAuthRequests()
+	ts.Len(requests, 1)
+	ts.Contains(requests, peer3.PeerID())
+
+	peer1.app.Conf.Lock()
+	peer1.app.Conf.P2pNode.AuthRequestLimits.RequireInvite = true
+	peer1.app.Conf.Unlock()
+	ts.Error(sendAuth(peer4))
+	ts.NotContains(peer1.app.AuthStatus.GetIngoingAuthRequests(), peer4.PeerID())
+
+	resp, err := peer1.api.PurgeAuthRequests(nil, true)
+	ts.NoError(err)
+	ts.Equal(1, resp.Purged)
+	_, blocked := peer1.app.Conf.GetBlockedPeer(peer3.PeerID())
+	ts.True(blocked)
+}
+
 func TestFriendRequestWithCustomIP(t *testing.T) {
 	ts := NewTestSuite(t)
 
@@ -276,6 +497,364 @@ func TestUniquePeerAlias(t *testing.T) {
 	ts.EqualError(err, "status code: 400, error: "+api.ErrorPeerAliasIsNotUniq)
 }
 
+func TestPeerGroupPermissions(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+
+	ts.makeFriends(peer2, peer1)
+
+	err := peer2.api.UpdatePeerGroup(entity.UpdatePeerGroupRequest{Name: "exit_nodes", AllowUsingAsExitNode: true})
+	ts.NoError(err)
+
+	peer1Config, err := peer2.api.KnownPeerConfig(peer1.PeerID())
+	ts.NoError(err)
+	err = peer2.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:     peer1.PeerID(),
+		Alias:      peer1Config.Alias,
+		DomainName: peer1Config.DomainName,
+		IPAddr:     peer1Config.IPAddr,
+		Groups:     []string{"exit_nodes"},
+	})
+	ts.NoError(err)
+
+	perms, err := peer2.api.PeerPermissions(peer1.PeerID())
+	ts.NoError(err)
+	ts.Equal([]string{"exit_nodes"}, perms.Groups)
+	ts.False(perms.Own.AllowUsingAsExitNode)
+	ts.True(perms.Effective.AllowUsingAsExitNode)
+
+	ts.Eventually(func() bool {
+		peer2Config, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		ts.NoError(err)
+
+		return peer2Config.AllowedUsingAsExitNode
+	}, 15*time.Second, 100*time.Millisecond)
+
+	// removing the group revokes inherited permissions
+	err = peer2.api.RemovePeerGroup("exit_nodes")
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		peer2Config, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		ts.NoError(err)
+
+		return !peer2Config.AllowedUsingAsExitNode
+	}, 15*time.Second, 100*time.Millisecond)
+}
+
+func TestPeerAccess(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+
+	ts.makeFriends(peer2, peer1)
+
+	peer1Config, err := peer2.api.KnownPeerConfig(peer1.PeerID())
+	ts.NoError(err)
+	settings := entity.UpdatePeerSettingsRequest{
+		PeerID:               peer1.PeerID(),
+		Alias:                peer1Config.Alias,
+		DomainName:           peer1Config.DomainName,
+		IPAddr:               peer1Config.IPAddr,
+		AllowUsingAsExitNode: true,
+		ExitNodeAccess:       &config.PeerAccess{ExpiresAt: time.Now().Add(-time.Minute)},
+	}
+	err = peer2.api.UpdatePeerSettings(settings)
+	ts.NoError(err)
+
+	perms, err := peer2.api.PeerPermissions(peer1.PeerID())
+	ts.NoError(err)
+	ts.True(perms.Own.AllowUsingAsExitNode)
+	ts.False(perms.Effective.AllowUsingAsExitNode)
+	ts.False(perms.Effective.Suspended)
+
+	settings.ExitNodeAccess = nil
+	settings.Access = &config.PeerAccess{OnExpire: "delete"}
+	err = peer2.api.UpdatePeerSettings(settings)
+	ts.Error(err)
+
+	// expired access with remove action
+	settings.Access = &config.PeerAccess{ExpiresAt: time.Now().Add(-time.Minute), OnExpire: config.AccessOnExpireRemove}
+	err = peer2.api.UpdatePeerSettings(settings)
+	ts.NoError(err)
+
+	knownPeers, err := peer2.api.KnownPeers()
+	ts.NoError(err)
+	ts.Len(knownPeers, 1)
+	ts.True(knownPeers[0].AccessSuspended)
+
+	ts.Eventually(func() bool {
+		_, err := peer2.api.KnownPeerConfig(peer1.PeerID())
+		return err != nil
+	}, 15*time.Second, 100*time.Millisecond)
+	blockedPeers, err := peer2.api.BlockedPeers()
+	ts.NoError(err)
+	ts.Len(blockedPeers, 0)
+}
+
+func TestPeerCapabilities(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+
+	ts.makeFriends(peer2, peer1)
+
+	knownPeers, err := peer1.api.KnownPeers()
+	ts.NoError(err)
+	ts.Len(knownPeers, 1)
+	ts.Equal(protocol.CompatibilityCompatible, knownPeers[0].Compatibility)
+	ts.NotNil(knownPeers[0].Capabilities)
+	ts.Equal(config.Version, knownPeers[0].Version)
+	ts.Equal(protocol.ProtocolVersion, knownPeers[0].Capabilities.ProtocolVersion)
+	ts.Equal(protocol.SupportedFeatures, knownPeers[0].Capabilities.Features)
+	ts.Contains(knownPeers[0].Capabilities.Services, protocol.ServiceSOCKS5)
+
+	err = peer2.api.UpdateMyDeviceInfo("peer_2", "laptop")
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		peer2Config, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		ts.NoError(err)
+
+		return peer2Config.Capabilities != nil && peer2Config.Capabilities.DeviceInfo == "laptop"
+	}, 15*time.Second, 100*time.Millisecond)
+}
+
+func TestAnnouncedDomainNames(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+	peer3 := ts.NewTestPeer(false)
+
+	ts.makeFriends(peer2, peer1)
+	ts.makeFriendsWithAliases(peer3, peer1, "peer_3", "peer_1")
+
+	err := peer2.api.UpdateMyDomainNames("peer_2", "bad name", nil)
+	ts.ErrorContains(err, "invalid domain name")
+	err = peer2.api.UpdateMyDomainNames("peer_2", "nas", []string{"files"})
+	ts.NoError(err)
+	err = peer3.api.UpdateMyDomainNames("peer_3", "nas", nil)
+	ts.NoError(err)
+
+	// both peers claim nas, it resolves to the peer with the lowest peer ID
+	nasOwner, otherPeer := peer2, peer3
+	if peer3.PeerID() < peer2.PeerID() {
+		nasOwner, otherPeer = peer3, peer2
+	}
+	ts.Eventually(func() bool {
+		peer2Config, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		ts.NoError(err)
+		peer3Config, err := peer1.api.KnownPeerConfig(peer3.PeerID())
+		ts.NoError(err)
+
+		return peer2Config.AnnouncedDomainName == "nas" && peer3Config.AnnouncedDomainName == "nas"
+	}, 15*time.Second, 100*time.Millisecond)
+
+	mapping := peer1.app.Conf.DNSNamesMapping()
+	nasOwnerConfig, _ := peer1.app.Conf.GetPeer(nasOwner.PeerID())
+	otherPeerConfig, _ := peer1.app.Conf.GetPeer(otherPeer.PeerID())
+	ts.Equal(nasOwnerConfig.IPAddr, mapping["nas"])
+	ts.Equal(otherPeerConfig.IPAddr, mapping[otherPeerConfig.DomainName])
+	peer2Config, _ := peer1.app.Conf.GetPeer(peer2.PeerID())
+	ts.Equal(peer2Config.IPAddr, mapping["files"])
+
+	getKnownPeer := func() entity.KnownPeersResponse {
+		knownPeers, err := peer1.api.KnownPeers()
+		ts.NoError(err)
+		i := slices.IndexFunc(knownPeers, func(knownPeer entity.KnownPeersResponse) bool {
+			return knownPeer.PeerID == peer2.PeerID()
+		})
+		ts.GreaterOrEqual(i, 0)
+		return knownPeers[i]
+	}
+	knownPeer := getKnownPeer()
+	ts.Equal("nas", knownPeer.DomainName)
+	ts.Equal("nas", knownPeer.AnnouncedDomainName)
+	ts.Contains(knownPeer.DomainNames, "files")
+
+	// local name overrides announced one
+	err = peer1.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peer2.PeerID(),
+		Alias:                peer2Config.Alias,
+		DomainName:           "storage",
+		IPAddr:               peer2Config.IPAddr,
+		AllowUsingAsExitNode: peer2Config.WeAllowUsingAsExitNode,
+	})
+	ts.NoError(err)
+	knownPeer = getKnownPeer()
+	ts.True(knownPeer.DomainNameOverride)
+	ts.Equal("storage", knownPeer.DomainName)
+	ts.Equal([]string{"storage"}, knownPeer.DomainNames)
+	mapping = peer1.app.Conf.DNSNamesMapping()
+	peer3Config, _ := peer1.app.Conf.GetPeer(peer3.PeerID())
+	ts.Equal(peer3Config.IPAddr, mapping["nas"])
+	ts.NotContains(mapping, "files")
+
+	// setting announced name back removes the override
+	err = peer1.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peer2.PeerID(),
+		Alias:                peer2Config.Alias,
+		DomainName:           "nas",
+		IPAddr:               peer2Config.IPAddr,
+		AllowUsingAsExitNode: peer2Config.WeAllowUsingAsExitNode,
+	})
+	ts.NoError(err)
+	knownPeer = getKnownPeer()
+	ts.False(knownPeer.DomainNameOverride)
+	ts.Contains(knownPeer.DomainNames, "files")
+}
+
+func TestPeerPresence(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	webhooks := make(chan map[string]any, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhook := make(map[string]any)
+		_ = json.NewDecoder(r.Body).Decode(&webhook)
+		webhooks <- webhook
+	}))
+	t.Cleanup(server.Close)
+
+	peer1 := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.Presence.NotifyDelaySec = 2
+	})
+	peer2 := ts.NewTestPeer(false)
+	ts.makeFriends(peer2, peer1)
+
+	ts.Error(peer1.api.UpdatePresenceWebhooks([]string{"example.com"}))
+	ts.NoError(peer1.api.UpdatePresenceWebhooks([]string{server.URL}))
+	peer2Config, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+	ts.NoError(err)
+	err = peer1.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:         peer2.PeerID(),
+		Alias:          peer2Config.Alias,
+		DomainName:     peer2Config.DomainName,
+		IPAddr:         peer2Config.IPAddr,
+		PresenceNotify: &config.PresenceNotify{Online: true, Offline: true},
+	})
+	ts.NoError(err)
+
+	presence, err := peer1.api.PeerPresence(peer2.PeerID())
+	ts.NoError(err)
+	ts.True(presence.Online)
+	ts.Len(presence.Events, 1)
+	ts.Equal(service.PresenceConnectionDirect, presence.Events[0].Connection)
+	ts.Equal("ip4", presence.Events[0].AddressFamily)
+	ts.Greater(presence.Uptime.Day, 0.0)
+
+	receiveWebhook := func(event string) map[string]any {
+		var webhook map[string]any
+		ts.Eventually(func() bool {
+			select {
+			case webhook = <-webhooks:
+				return webhook["event"] == event
+			default:
+				return false
+			}
+		}, 15*time.Second, 50*time.Millisecond)
+		return webhook
+	}
+	webhook := receiveWebhook("online")
+	ts.Equal(peer2.PeerID(), webhook["peerId"])
+	ts.Equal(presence.DisplayName, webhook["name"])
+	ts.Equal(service.PresenceConnectionDirect, webhook["connection"])
+
+	peer2.Close()
+	webhook = receiveWebhook("offline")
+	ts.Equal(peer2.PeerID(), webhook["peerId"])
+
+	presence, err = peer1.api.PeerPresence(peer2.PeerID())
+	ts.NoError(err)
+	ts.False(presence.Online)
+	ts.Len(presence.Events, 2)
+	ts.False(presence.Events[1].Online)
+
+	// history is saved on close
+	peer1.Close()
+	data, err := os.ReadFile(filepath.Join(peer1.app.Conf.DataDir(), config.PresenceHistoryFilename))
+	ts.NoError(err)
+	ts.Contains(string(data), peer2.PeerID())
+}
+
+func TestRotateIdentity(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+	peer3 := ts.NewTestPeer(false)
+
+	ts.makeFriends(peer2, peer1)
+	ts.makeFriendsWithAliases(peer3, peer1, "peer_3", "peer_1")
+	oldPeerID := peer1.PeerID()
+
+	// peer3 is offline during rotation and migrates peer1 after restart
+	peer3.app.Conf.RLock()
+	peer3Identity := peer3.app.Conf.P2pNode.Identity
+	peer3KnownPeers := maps.Clone(peer3.app.Conf.KnownPeers)
+	peer3.app.Conf.RUnlock()
+	peer3.Close()
+
+	peer1Config, err := peer2.api.KnownPeerConfig(oldPeerID)
+	ts.NoError(err)
+	resp, err := peer1.api.RotateIdentity()
+	ts.NoError(err)
+	ts.NotEqual(oldPeerID, resp.NewPeerID)
+
+	// peer2 keeps the old peer ID until peer1 restarts and stays connected with it
+	knownPeer, exists := peer2.app.Conf.GetPeer(oldPeerID)
+	ts.True(exists)
+	ts.NoError(peer2.app.AuthStatus.ExchangeNewStatusInfo(context.Background(), knownPeer.PeerId(), knownPeer))
+	peer1.app.AuthStatus.ExchangeStatusInfoWithAllKnownPeers(context.Background())
+	_, err = peer2.api.KnownPeerConfig(resp.NewPeerID)
+	ts.Error(err)
+	knownPeers, err := peer2.api.KnownPeers()
+	ts.NoError(err)
+	ts.Len(knownPeers, 1)
+	ts.Equal(oldPeerID, knownPeers[0].PeerID)
+	ts.True(knownPeers[0].Connected)
+
+	peer1.app.Conf.RLock()
+	peer1Node := peer1.app.Conf.P2pNode
+	peer1KnownPeers := maps.Clone(peer1.app.Conf.KnownPeers)
+	peer1.app.Conf.RUnlock()
+	peer1.Close()
+
+	peer1 = ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.Identity = peer1Node.Identity
+		c.P2pNode.NextIdentity = peer1Node.NextIdentity
+		c.P2pNode.KeyRotations = peer1Node.KeyRotations
+		c.KnownPeers = maps.Clone(peer1KnownPeers)
+	})
+	ts.Equal(resp.NewPeerID, peer1.PeerID())
+	ts.Empty(peer1.app.Conf.P2pNode.NextIdentity)
+
+	// peer2 migrates peer1 when it connects with the new peer ID
+	ts.ensurePeersAvailableInDHT(peer1, peer2)
+	peer1.app.AuthStatus.ExchangeStatusInfoWithAllKnownPeers(context.Background())
+	ts.Eventually(func() bool {
+		newConfig, err := peer2.api.KnownPeerConfig(resp.NewPeerID)
+		return err == nil && newConfig.Alias == peer1Config.Alias && newConfig.IPAddr == peer1Config.IPAddr
+	}, 15*time.Second, 100*time.Millisecond)
+	_, err = peer2.api.KnownPeerConfig(oldPeerID)
+	ts.Error(err)
+
+	peer3 = ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.Identity = peer3Identity
+		c.KnownPeers = maps.Clone(peer3KnownPeers)
+	})
+	ts.ensurePeersAvailableInDHT(peer1, peer3)
+	peer1.app.AuthStatus.ExchangeStatusInfoWithAllKnownPeers(context.Background())
+	ts.Eventually(func() bool {
+		newConfig, err := peer3.api.KnownPeerConfig(resp.NewPeerID)
+		return err == nil && newConfig.Alias == peer3KnownPeers[oldPeerID].Alias && newConfig.Confirmed
+	}, 15*time.Second, 100*time.Millisecond)
+	_, err = peer3.api.KnownPeerConfig(oldPeerID)
+	ts.Error(err)
+}
+
 func TestUpdateUseAsExitNodeConfig(t *testing.T) {
 	ts := NewTestSuite(t)
 
@@ -786,6 +1365,564 @@ func TestUpdatePeerSettingsIPAddr(t *testing.T) {
 	})
 }
 
+func TestSpeedTest(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.SpeedTest.MaxDurationSec = 1
+	})
+	ts.makeFriends(peer2, peer1)
+
+	for _, direction := range []string{"upload", "download", "bidirectional"} {
+		t.Run(direction, func(t *testing.T) {
+			result, err := peer1.api.SpeedTest(entity.SpeedTestRequest{
+				PeerID:      peer2.PeerID(),
+				Direction:   direction,
+				DurationSec: 5,
+			})
+			ts.NoError(err)
+			ts.Equal(peer2.PeerID(), result.PeerID)
+			ts.Equal(direction, result.Direction)
+			ts.Equal(time.Second, result.Duration, "duration should be limited by receiver")
+			ts.False(result.ThroughRelay)
+			ts.Equal(direction != "download", result.Upload != nil)
+			ts.Equal(direction != "upload", result.Download != nil)
+			if result.Upload != nil {
+				ts.Positive(result.Upload.Bytes)
+				ts.Positive(result.Upload.BitsPerSecond)
+			}
+			if result.Download != nil {
+				ts.Positive(result.Download.Bytes)
+				ts.Positive(result.Download.BitsPerSecond)
+			}
+			ts.Positive(result.Latency.ProbesSent)
+			ts.Zero(result.Latency.ProbesLost)
+			ts.Positive(result.Latency.RTTAvg)
+			ts.LessOrEqual(result.Latency.RTTMin, result.Latency.RTTMax)
+		})
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		peer1.app.Conf.Lock()
+		peer1.app.Conf.SpeedTest.DisableIncoming = true
+		peer1.app.Conf.Unlock()
+
+		_, err := peer2.api.SpeedTest(entity.SpeedTestRequest{PeerID: peer1.PeerID(), Direction: "download", DurationSec: 1})
+		ts.ErrorContains(err, "speed tests are disabled")
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		_, err := peer1.api.SpeedTest(entity.SpeedTestRequest{PeerID: peer2.PeerID(), Direction: "sideways"})
+		ts.ErrorContains(err, "status code: 400")
+
+		_, err = peer1.api.SpeedTest(entity.SpeedTestRequest{PeerID: peer1.PeerID(), Direction: "upload"})
+		ts.EqualError(err, "status code: 404, error: peer not found")
+	})
+}
+
+func TestConnectionQualityMonitoring(t *testing.T) {
+	ts := NewTestSuite(t)
+
+	peer1 := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.QualityProbeIntervalSec = 1
+	})
+	peer2 := ts.NewTestPeer(false)
+	ts.makeFriends(peer2, peer1)
+
+	var peer2Info entity.KnownPeersResponse
+	ts.Eventually(func() bool {
+		knownPeers, err := peer1.api.KnownPeers()
+		ts.NoError(err)
+		ts.Len(knownPeers, 1)
+		peer2Info = knownPeers[0]
+		return len(peer2Info.ConnectionQuality.History) >= 2
+	}, 15*time.Second, 200*time.Millisecond)
+
+	for _, sample := range peer2Info.ConnectionQuality.History {
+		ts.Equal(p2p.ConnectionTypeDirect, sample.ConnectionType)
+		ts.Zero(sample.LossPercent)
+		ts.Positive(sample.RTT)
+		ts.LessOrEqual(sample.RTTMin, sample.RTT)
+		ts.GreaterOrEqual(sample.RTTMax, sample.RTT)
+	}
+	ts.Empty(peer2Info.ConnectionQuality.ConnectionTypeChanges)
+	ts.Positive(peer2Info.Ping)
+}
+
+func TestOfflineMode(t *testing.T) {
+	ts := NewTestSuite(t)
+	offline := func(c *config.Config) {
+		c.OfflineMode = true
+		c.P2pNode.ReconnectionIntervalSec = 1
+	}
+	peer1 := ts.NewTestPeerWithConfig(offline)
+	peer2 := ts.NewTestPeerWithConfig(offline)
+
+	info, err := peer1.api.PeerInfo()
+	ts.NoError(err)
+	ts.True(info.OfflineMode)
+	ts.Zero(info.TotalBootstrapPeers)
+
+	// no DHT, peers find each other only by addresses
+	ts.makeFriendsSimnet(peer1, peer2)
+
+	peer2ID := peer2.app.P2p.PeerID()
+	pcfg, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+	ts.NoError(err)
+	err = peer1.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peer2.PeerID(),
+		Alias:                pcfg.Alias,
+		DomainName:           pcfg.DomainName,
+		IPAddr:               pcfg.IPAddr,
+		AllowUsingAsExitNode: pcfg.WeAllowUsingAsExitNode,
+		StaticAddrs:          []string{peer2.app.P2p.Host().Addrs()[0].String()},
+	})
+	ts.NoError(err)
+
+	// forget learned addresses on both sides, reconnection is possible only by static address
+	peer2.app.P2p.Host().Peerstore().ClearAddrs(peer1.app.P2p.PeerID())
+	peer1.app.P2p.Host().Peerstore().ClearAddrs(peer2ID)
+	ts.NoError(peer1.app.P2p.Host().Network().ClosePeer(peer2ID))
+	ts.Eventually(func() bool {
+		return peer1.app.P2p.IsConnected(peer2ID)
+	}, 15*time.Second, 100*time.Millisecond)
+}
+
+func TestPrivateNetwork(t *testing.T) {
+	ts := NewTestSuite(t)
+	key, err := config.GeneratePrivateNetworkKey()
+	ts.NoError(err)
+	private := func(c *config.Config) {
+		c.P2pNode.PrivateNetworkKey = key
+		c.P2pNode.DHTProtocolPrefix = "/awl-test"
+		c.P2pNode.BootstrapPeers = nil
+	}
+	peer1 := ts.NewTestPeerWithConfig(private)
+	peer2 := ts.NewTestPeerWithConfig(private)
+	stranger := ts.NewTestPeer(false)
+
+	info, err := peer1.api.PeerInfo()
+	ts.NoError(err)
+	ts.True(info.PrivateNetwork)
+	ts.Zero(info.TotalBootstrapPeers)
+	for _, addr := range peer1.app.P2p.Host().Addrs() {
+		_, err := addr.ValueForProtocol(multiaddr.P_TCP)
+		ts.NoError(err, "only tcp transport is supported in private network")
+	}
+
+	ts.makeFriendsSimnet(peer1, peer2)
+
+	// peer without network key can't connect in both directions
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = stranger.app.P2p.Host().Connect(ctx, peer.AddrInfo{
+		ID:    peer1.app.P2p.PeerID(),
+		Addrs: peer1.app.P2p.Host().Addrs(),
+	})
+	ts.Error(err)
+	err = peer1.app.P2p.Host().Connect(ctx, peer.AddrInfo{
+		ID:    stranger.app.P2p.PeerID(),
+		Addrs: stranger.app.P2p.Host().Addrs(),
+	})
+	ts.Error(err)
+	ts.False(peer1.app.P2p.IsConnected(stranger.app.P2p.PeerID()))
+
+	_, err = ts.NewTestPeerExpectingInitError(func(c *config.Config) {
+		c.P2pNode.PrivateNetworkKey = "invalid"
+	}, nil)
+	ts.ErrorContains(err, "invalid private network key")
+}
+
+func TestRelayServiceForKnownPeers(t *testing.T) {
+	ts := NewTestSuite(t)
+	relay := ts.NewTestPeerWithAppConfig(func(c *config.Config) {
+		c.RelayService.Enabled = true
+	}, func(a *Application) {
+		// relay service starts only when node is publicly reachable
+		a.ExtraLibp2pOpts = append(a.ExtraLibp2pOpts, libp2p.ForceReachabilityPublic())
+	})
+	friend := ts.NewTestPeer(false)
+	stranger := ts.NewTestPeer(false)
+
+	info, err := relay.api.PeerInfo()
+	ts.NoError(err)
+	ts.True(info.RelayServiceEnabled)
+
+	ts.makeFriendsSimnet(friend, relay)
+	ts.Eventually(func() bool {
+		knownPeer, ok := friend.app.Conf.GetPeer(relay.PeerID())
+		return ok && knownPeer.RemoteRelayServiceEnabled
+	}, 15*time.Second, 100*time.Millisecond)
+	ts.Equal([]peer.ID{relay.app.P2p.PeerID()}, friend.app.Conf.RelayServicePeers())
+
+	relayInfo := peer.AddrInfo{
+		ID:    relay.app.P2p.PeerID(),
+		Addrs: relay.app.P2p.Host().Addrs(),
+	}
+	var reservation *relayclient.Reservation
+	ts.Eventually(func() bool {
+		reservation, err = relayclient.Reserve(context.Background(), friend.app.P2p.Host(), relayInfo)
+		return err == nil
+	}, 15*time.Second, 100*time.Millisecond)
+	// no limits by default, relayed connections aren't limited
+	ts.Zero(reservation.LimitData)
+	ts.Zero(reservation.LimitDuration)
+
+	_, err = relayclient.Reserve(context.Background(), stranger.app.P2p.Host(), relayInfo)
+	var reservationErr relayclient.ReservationError
+	ts.ErrorAs(err, &reservationErr)
+	ts.Equal(pbv2.Status_PERMISSION_DENIED, reservationErr.Status)
+}
+
+func TestTrustedPeerAsRelay(t *testing.T) {
+	ts := NewTestSuite(t)
+	relay := ts.NewTestPeerWithAppConfig(nil, func(a *Application) {
+		// relay service starts only when node is publicly reachable
+		a.ExtraLibp2pOpts = append(a.ExtraLibp2pOpts, libp2p.ForceReachabilityPublic())
+	})
+	device1 := ts.NewTestPeer(false)
+	device2 := ts.NewTestPeer(false)
+	friend := ts.NewTestPeer(false)
+	relayInfo := peer.AddrInfo{
+		ID:    relay.app.P2p.PeerID(),
+		Addrs: relay.app.P2p.Host().Addrs(),
+	}
+	for i, tp := range []TestPeer{device1, device2, friend} {
+		ts.NoError(tp.app.P2p.Host().Connect(context.Background(), relayInfo))
+		ts.sendAndAcceptFriendRequest(tp, relay, fmt.Sprintf("peer_%d", i+1), "relay")
+	}
+
+	info, err := relay.api.PeerInfo()
+	ts.NoError(err)
+	ts.False(info.RelayServiceEnabled)
+	// relay service runs only when some known peers are allowed to use it
+	isRelayServiceRunning := func() bool {
+		return slices.Contains(relay.app.P2p.Host().Mux().Protocols(), relayproto.ProtoIDv2Hop)
+	}
+	ts.False(isRelayServiceRunning())
+
+	setAllowUsingAsRelay := func(tp TestPeer, allow bool) {
+		pcfg, err := relay.api.KnownPeerConfig(tp.PeerID())
+		ts.NoError(err)
+		err = relay.api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+			PeerID:            tp.PeerID(),
+			Alias:             pcfg.Alias,
+			DomainName:        pcfg.DomainName,
+			IPAddr:            pcfg.IPAddr,
+			AllowUsingAsRelay: &allow,
+		})
+		ts.NoError(err)
+	}
+	for _, tp := range []TestPeer{device1, device2} {
+		setAllowUsingAsRelay(tp, true)
+		ts.Eventually(func() bool {
+			knownPeer, ok := tp.app.Conf.GetPeer(relay.PeerID())
+			return ok && knownPeer.AllowedUsingAsRelay
+		}, 15*time.Second, 100*time.Millisecond)
+		ts.Equal([]peer.ID{relay.app.P2p.PeerID()}, tp.app.Conf.RelayServicePeers())
+	}
+	ts.Empty(friend.app.Conf.RelayServicePeers())
+
+	ts.Eventually(func() bool {
+		_, err = relayclient.Reserve(context.Background(), device1.app.P2p.Host(), relayInfo)
+		return err == nil
+	}, 15*time.Second, 100*time.Millisecond)
+	_, err = relayclient.Reserve(context.Background(), friend.app.P2p.Host(), relayInfo)
+	var reservationErr relayclient.ReservationError
+	ts.ErrorAs(err, &reservationErr)
+	ts.Equal(pbv2.Status_PERMISSION_DENIED, reservationErr.Status)
+
+	circuitAddr, err := multiaddr.NewMultiaddr("/p2p/" + relay.PeerID() + "/p2p-circuit")
+	ts.NoError(err)
+	device1CircuitInfo := peer.AddrInfo{ID: device1.app.P2p.PeerID()}
+	for _, addr := range relayInfo.Addrs {
+		device1CircuitInfo.Addrs = append(device1CircuitInfo.Addrs, addr.Encapsulate(circuitAddr))
+	}
+	// dial circuit transport directly, host would prefer direct addresses of the peer
+	dialThroughRelay := func(tp TestPeer) error {
+		addr := device1CircuitInfo.Addrs[0]
+		transport := tp.app.P2p.Host().Network().(*swarm.Swarm).TransportForDialing(addr)
+		conn, err := transport.Dial(context.Background(), addr, device1CircuitInfo.ID)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	ts.True(isRelayServiceRunning())
+	// only peers which are allowed by relay can connect through it
+	ts.Error(dialThroughRelay(friend))
+	ts.NoError(dialThroughRelay(device2))
+
+	// relayed traffic is accounted by relay for each peer and by peers for relay
+	ts.Eventually(func() bool {
+		return relay.app.P2p.RelayedNetworkStatsForPeer(device1.app.P2p.PeerID()).TotalOut > 0 &&
+			relay.app.P2p.RelayedNetworkStatsForPeer(device2.app.P2p.PeerID()).TotalIn > 0
+	}, 15*time.Second, 100*time.Millisecond)
+	ts.Eventually(func() bool {
+		knownPeers, err := device2.api.KnownPeers()
+		ts.NoError(err)
+		return len(knownPeers) == 1 && knownPeers[0].AllowedUsingAsRelay && knownPeers[0].RelayedNetworkStats.TotalOut > 0
+	}, 15*time.Second, 100*time.Millisecond)
+
+	setAllowUsingAsRelay(device1, false)
+	ts.True(isRelayServiceRunning())
+	setAllowUsingAsRelay(device2, false)
+	ts.Eventually(func() bool {
+		return !isRelayServiceRunning()
+	}, 15*time.Second, 100*time.Millisecond)
+}
+
+func TestAddrsGossip(t *testing.T) {
+	ts := NewTestSuite(t)
+	phone := ts.NewTestPeer(false)
+	server := ts.NewTestPeer(false)
+	laptop := ts.NewTestPeer(false)
+	// phone dials server, so it knows working address of server
+	ts.makeFriendsSimnet(phone, server)
+	ts.makeFriendsSimnet(laptop, phone)
+	serverAddrs := phone.app.P2p.WorkingAddrs(server.app.P2p.PeerID())
+	ts.NotEmpty(serverAddrs)
+
+	unknownPeer := ts.NewTestPeer(true)
+	blindPeerID := func(tp TestPeer) string {
+		return protocol.BlindPeerID(laptop.PeerID(), phone.PeerID(), tp.PeerID())
+	}
+	requestAddrs := func() map[string][]string {
+		stream, err := laptop.app.P2p.NewStream(context.Background(), phone.app.P2p.PeerID(), protocol.GetStatusMethod)
+		ts.NoError(err)
+		defer stream.Close()
+		err = protocol.SendStatus(stream, protocol.PeerStatusInfo{
+			Name:              "laptop",
+			PeersAddrsRequest: []string{blindPeerID(server), blindPeerID(unknownPeer), blindPeerID(laptop), server.PeerID()},
+		})
+		ts.NoError(err)
+		statusInfo, err := protocol.ReceiveStatus(stream)
+		ts.NoError(err)
+		return statusInfo.PeersAddrs
+	}
+
+	// gossip is disabled by default
+	ts.Empty(requestAddrs())
+
+	// only addresses of peers known to phone and requested by blinded IDs are shared
+	phone.app.Conf.Lock()
+	phone.app.Conf.P2pNode.EnableAddrsGossip = true
+	phone.app.Conf.Unlock()
+	expectedAddrs := make([]string, 0, len(serverAddrs))
+	for _, addr := range serverAddrs {
+		expectedAddrs = append(expectedAddrs, addr.String())
+	}
+	ts.Equal(map[string][]string{blindPeerID(server): expectedAddrs}, requestAddrs())
+}
+
+func TestUpstreamProxy(t *testing.T) {
+	ts := NewTestSuite(t)
+	proxyAddr, connects := startTestHTTPProxy(t)
+
+	proxied := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.UpstreamProxy.URL = "http://user:secret@" + proxyAddr
+	})
+	// QUIC is dialed directly, so remote peer accepts only TCP
+	remote := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.SetListenAddresses([]multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/0")})
+	})
+
+	ts.makeFriendsSimnet(proxied, remote)
+	ts.Positive(connects.Load())
+
+	report, err := proxied.api.Netcheck()
+	ts.NoError(err)
+	ts.NotNil(report.UpstreamProxy)
+	ts.NotContains(report.UpstreamProxy.URL, "secret")
+	ts.True(report.UpstreamProxy.Reachable)
+	ts.Positive(report.UpstreamProxy.Dials)
+	ts.Positive(report.UpstreamProxy.ActiveConns)
+
+	_, err = ts.NewTestPeerExpectingInitError(func(c *config.Config) {
+		c.P2pNode.UpstreamProxy.URL = "ftp://127.0.0.1:21"
+	}, nil)
+	ts.ErrorContains(err, "invalid upstream proxy")
+}
+
+// startTestHTTPProxy runs HTTP CONNECT proxy without authorization.
+func startTestHTTPProxy(t *testing.T) (string, *atomic.Int64) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connects := new(atomic.Int64)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetConn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		connects.Add(1)
+		w.WriteHeader(http.StatusOK)
+		clientConn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			_ = targetConn.Close()
+			return
+		}
+		go func() {
+			_, _ = io.Copy(targetConn, clientConn)
+			_ = targetConn.Close()
+		}()
+		_, _ = io.Copy(clientConn, targetConn)
+		_ = clientConn.Close()
+	})}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return listener.Addr().String(), connects
+}
+
+func TestWebTransports(t *testing.T) {
+	ts := NewTestSuite(t)
+	certFile, keyFile := writeTestCertificate(t)
+
+	web := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.SetListenAddresses([]multiaddr.Multiaddr{
+			multiaddr.StringCast("/ip4/127.0.0.1/tcp/0/ws"),
+			multiaddr.StringCast("/ip4/127.0.0.1/tcp/0/tls/ws"),
+			multiaddr.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"),
+		})
+		c.P2pNode.WebSocketTLS = config.WebSocketTLSConfig{CertFile: certFile, KeyFile: keyFile}
+	})
+	client := ts.NewTestPeer(false)
+
+	webAddrs := web.app.P2p.Host().Addrs()
+	ts.True(slices.ContainsFunc(webAddrs, p2p.IsSecureWebSocketAddr))
+
+	plainWebSocket := func(addr multiaddr.Multiaddr) bool {
+		_, err := addr.ValueForProtocol(multiaddr.P_WS)
+		return err == nil && !p2p.IsSecureWebSocketAddr(addr)
+	}
+	webTransport := func(addr multiaddr.Multiaddr) bool {
+		_, err := addr.ValueForProtocol(multiaddr.P_WEBTRANSPORT)
+		return err == nil
+	}
+	for name, filter := range map[string]func(multiaddr.Multiaddr) bool{"websocket": plainWebSocket, "webtransport": webTransport} {
+		var addrs []multiaddr.Multiaddr
+		for _, addr := range webAddrs {
+			if filter(addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+		ts.NotEmpty(addrs, name)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := client.app.P2p.Host().Connect(ctx, peer.AddrInfo{ID: web.app.P2p.PeerID(), Addrs: addrs})
+		cancel()
+		ts.NoError(err, name)
+		conns := client.app.P2p.Host().Network().ConnsToPeer(web.app.P2p.PeerID())
+		ts.NotEmpty(conns, name)
+		ts.True(filter(conns[0].RemoteMultiaddr()), name)
+
+		ts.NoError(client.app.P2p.Host().Network().ClosePeer(web.app.P2p.PeerID()))
+		client.app.P2p.Host().Peerstore().ClearAddrs(web.app.P2p.PeerID())
+	}
+
+	_, err := ts.NewTestPeerExpectingInitError(func(c *config.Config) {
+		c.SetListenAddresses([]multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/0/tls/ws")})
+	}, nil)
+	ts.ErrorContains(err, "invalid websocket tls config")
+}
+
+func writeTestCertificate(t *testing.T) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestResourceLimits(t *testing.T) {
+	ts := NewTestSuite(t)
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.ResourceLimits.Protocols[config.ProtocolLimitsAuth] = config.ProtocolLimitsConfig{Streams: 8, StreamsPerPeer: 1}
+		c.P2pNode.ConnManager = config.ConnManagerConfig{LowWater: 10, HighWater: 20, GracePeriodSec: 5}
+	})
+
+	debugInfo, err := peer2.api.P2pDebugInfo()
+	ts.NoError(err)
+	ts.True(debugInfo.ResourceManager.Enabled)
+	authLimits := debugInfo.ResourceManager.Protocols[string(protocol.AuthMethod)]
+	ts.Equal(8, authLimits.Limits.Streams)
+	ts.NotNil(authLimits.PeerLimits)
+	ts.Equal(1, authLimits.PeerLimits.Streams)
+	ts.Positive(debugInfo.ResourceManager.System.Limits.Conns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = peer1.app.P2p.Host().Connect(ctx, peer.AddrInfo{ID: peer2.app.P2p.PeerID(), Addrs: peer2.app.P2p.Host().Addrs()})
+	ts.NoError(err)
+
+	// incomplete auth request holds the only allowed stream
+	stream1, err := peer1.app.P2p.NewStream(ctx, peer2.app.P2p.PeerID(), protocol.AuthMethod)
+	ts.NoError(err)
+	defer stream1.Reset()
+	_, err = stream1.Write([]byte("{"))
+	ts.NoError(err)
+	ts.Eventually(func() bool {
+		debugInfo, err := peer2.api.P2pDebugInfo()
+		return err == nil && debugInfo.ResourceManager.Protocols[string(protocol.AuthMethod)].Usage.NumStreamsInbound == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	stream2, err := peer1.app.P2p.NewStream(ctx, peer2.app.P2p.PeerID(), protocol.AuthMethod)
+	if err == nil {
+		_, _ = stream2.Write([]byte("{"))
+		_, err = stream2.Read(make([]byte, 1))
+		_ = stream2.Reset()
+	}
+	ts.Error(err)
+
+	debugInfo, err = peer2.api.P2pDebugInfo()
+	ts.NoError(err)
+	ts.Contains(debugInfo.ResourceManager.Blocked, p2p.BlockedResourcesInfo{
+		Scope:    "protocol:" + string(protocol.AuthMethod) + ".peer",
+		Resource: p2p.BlockedResourceStream,
+		Count:    1,
+	})
+
+	unlimited := ts.NewTestPeerWithConfig(func(c *config.Config) {
+		c.P2pNode.ResourceLimits.Disabled = true
+	})
+	debugInfo, err = unlimited.api.P2pDebugInfo()
+	ts.NoError(err)
+	ts.False(debugInfo.ResourceManager.Enabled)
+}
+
 func TestDisableVPNInterface(t *testing.T) {
 	ts := NewTestSuite(t)
 
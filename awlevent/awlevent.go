@@ -2,6 +2,7 @@ package awlevent
 
 import (
 	"context"
+	"time"
 
 	"github.com/anywherelan/awl/protocol"
 	"github.com/libp2p/go-libp2p/core/event"
@@ -18,6 +19,26 @@ type ReceivedAuthRequest struct {
 	PeerID string
 }
 
+// PeerAccessChanged is emitted when access of known peer or its exit node access is suspended or resumed,
+// and when the peer is removed because its access expired.
+type PeerAccessChanged struct {
+	PeerID            string
+	Suspended         bool
+	ExitNodeSuspended bool
+	Removed           bool
+}
+
+// PeerPresenceChanged is emitted when known peer with enabled config.PresenceNotify goes online or offline.
+type PeerPresenceChanged struct {
+	PeerID      string
+	DisplayName string
+	Online      bool
+	// Connection and AddressFamily are set for online peers, see entity.PresenceEvent
+	Connection    string
+	AddressFamily string
+	Time          time.Time
+}
+
 func WrapSubscriptionToCallback(ctx context.Context, callback func(interface{}), bus Bus,
 	eventType interface{}, opts ...event.SubscriptionOpt) {
 	sub, err := bus.Subscribe(eventType, opts...)
@@ -11,14 +11,17 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/GrigoryKrasnochub/updaterini"
 	"github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 
 	"github.com/anywherelan/awl/api/apiclient"
 	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
 	"github.com/anywherelan/awl/update"
 )
 
@@ -36,6 +39,8 @@ type Application struct {
 	api     *apiclient.Client
 	cliapp  *cli.App
 	appType config.AppType
+
+	inputReader *bufio.Reader
 }
 
 func New(appType config.AppType) *Application {
@@ -163,6 +168,103 @@ func (a *Application) init() {
 							return renameMe(a.api, c.String("name"), c.App.Writer)
 						},
 					},
+					{
+						Name:   "rotate_identity",
+						Usage:  "Generate new identity key, e.g. when the current one leaked or after moving config to a new device. It's used after restart",
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return rotateIdentity(a.api, c.App.Writer)
+						},
+					},
+					{
+						Name: "set_secrets_encryption",
+						Usage: fmt.Sprintf("Encrypt identity key and passwords in config file. Passphrase is asked on startup or read from %s env variable",
+							config.PassphraseEnvKey),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "provider",
+								Usage:    "key provider: passphrase, keyring (linux secret service), keyfile or none to store secrets in plain text",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "key_file",
+								Usage: "path to key file for keyfile provider, the key is generated if the file doesn't exist",
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return setSecretsEncryption(a.api, c.String("provider"), c.String("key_file"), a.readPassphrase, c.App.Writer)
+						},
+					},
+					{
+						Name:  "set_device_info",
+						Usage: "Set free-form description of your device shown to known peers, empty value removes it",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "info",
+								Usage:    "device info",
+								Required: true,
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return setMyDeviceInfo(a.api, c.String("info"), c.App.Writer)
+						},
+					},
+					{
+						Name:  "set_domain",
+						Usage: "Set domain names announced to known peers, they resolve your device by them instead of local names",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "domain",
+								Usage:    "domain name without .awl suffix, empty value disables announcing",
+								Required: true,
+							},
+							&cli.StringSliceFlag{
+								Name:  "alias",
+								Usage: "additional domain name, can be repeated",
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return setMyDomainNames(a.api, c.String("domain"), c.StringSlice("alias"), c.App.Writer)
+						},
+					},
+					{
+						Name:   "presence_webhooks",
+						Usage:  "Print webhook URLs which receive presence notifications",
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return printPresenceWebhooks(a.api, c.App.Writer)
+						},
+					},
+					{
+						Name:  "set_presence_webhooks",
+						Usage: "Set webhook URLs which receive POST requests when known peers with notifications go online or offline",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:     "url",
+								Usage:    "webhook url, can be repeated",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "clear",
+								Usage:    "remove all webhooks",
+								Required: false,
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							webhookURLs := c.StringSlice("url")
+							if len(webhookURLs) == 0 && !c.Bool("clear") {
+								return errors.New("provide at least one --url or --clear")
+							}
+							if c.Bool("clear") {
+								webhookURLs = nil
+							}
+							return setPresenceWebhooks(a.api, webhookURLs, c.App.Writer)
+						},
+					},
 					{
 						Name:   "list_proxies",
 						Usage:  "Prints list of available SOCKS5 proxies",
@@ -210,7 +312,8 @@ func (a *Application) init() {
 								Value:    "npslucev",
 								Usage: "control table columns list and order.Each char add column, write column chars together without gap. Use these chars to add specific columns:\n   " +
 									"n - peers number\n   p - peers name, domain and ip address\n   i - peers id\n   s - peers status\n   l - peers last seen datetime\n   v - peers awl version" +
-									"\n   u - network usage by peer (in/out)\n   c - list of peers connections (IP address + protocol)\n   e - exit node status\n  ",
+									"\n   u - network usage by peer (in/out)\n   c - list of peers connections (IP address + protocol)\n   e - exit node status" +
+									"\n   d - peers OS, architecture and device info\n  ",
 							},
 						},
 						Before: a.initApiConnection,
@@ -226,6 +329,24 @@ func (a *Application) init() {
 							return printFriendRequests(a.api, c.App.Writer)
 						},
 					},
+					{
+						Name:  "purge_requests",
+						Usage: "Remove incoming friend requests, e.g. after flood from unknown peers. Senders can send requests again unless they are declined",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "pid",
+								Usage: "peer id of request, all requests are removed if it's not set",
+							},
+							&cli.BoolFlag{
+								Name:  "decline",
+								Usage: "decline requests and block their senders",
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return purgeFriendRequests(a.api, c.StringSlice("pid"), c.Bool("decline"), c.App.Writer)
+						},
+					},
 					{
 						Name:  "add",
 						Usage: "Invite peer or accept existing invitation from this peer",
@@ -298,7 +419,7 @@ func (a *Application) init() {
 					},
 					{
 						Name:  "update_domain",
-						Usage: "Change known peer domain name",
+						Usage: "Change known peer domain name, it overrides domain name announced by the peer",
 						Flags: []cli.Flag{
 							&cli.StringFlag{
 								Name:     "pid",
@@ -346,6 +467,43 @@ func (a *Application) init() {
 							return changePeerIP(a.api, c.String("pid"), c.String("ip"), c.App.Writer)
 						},
 					},
+					{
+						Name:  "update_addrs",
+						Usage: "Set static multiaddrs of known peer, they are dialed before DHT lookup",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "addr",
+								Usage:    "peer multiaddr, e.g. /ip4/192.168.1.10/udp/4363/quic-v1, can be repeated",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "clear",
+								Usage:    "remove all static addresses",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							addrs := c.StringSlice("addr")
+							if len(addrs) == 0 && !c.Bool("clear") {
+								return errors.New("provide at least one --addr or --clear")
+							}
+							if c.Bool("clear") {
+								addrs = nil
+							}
+							return updatePeerStaticAddrs(a.api, c.String("pid"), addrs, c.App.Writer)
+						},
+					},
 					{
 						Name:  "allow_exit_node",
 						Usage: "Allow known peer to use this device as exit node (as socks5 proxy)",
@@ -371,6 +529,397 @@ func (a *Application) init() {
 							return setAllowUsingAsExitNode(a.api, c.String("pid"), c.Bool("allow"), c.App.Writer)
 						},
 					},
+					{
+						Name:  "allow_relay",
+						Usage: "Allow known peer to relay connections through this device to other allowed known peers",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "allow",
+								Usage:    "allow",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							return setAllowUsingAsRelay(a.api, c.String("pid"), c.Bool("allow"), c.App.Writer)
+						},
+					},
+					{
+						Name:  "speedtest",
+						Usage: "Measure throughput, latency, jitter and loss to known peer",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "direction",
+								Usage:    "upload, download or bidirectional",
+								Required: false,
+								Value:    "download",
+							},
+							&cli.DurationFlag{
+								Name:     "duration",
+								Usage:    "test duration, remote peer may shorten it",
+								Required: false,
+								Value:    10 * time.Second,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							return runSpeedTest(a.api, c.String("pid"), c.String("direction"), c.Duration("duration"), c.App.Writer)
+						},
+					},
+					{
+						Name:  "set_groups",
+						Usage: "Set peer groups of known peer, the peer inherits their permissions",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "group",
+								Usage:    "group name, can be repeated",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "clear",
+								Usage:    "remove peer from all groups",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							groups := c.StringSlice("group")
+							if len(groups) == 0 && !c.Bool("clear") {
+								return errors.New("provide at least one --group or --clear")
+							}
+							if c.Bool("clear") {
+								groups = nil
+							}
+							return setPeerGroups(a.api, c.String("pid"), groups, c.App.Writer)
+						},
+					},
+					{
+						Name:  "set_access",
+						Usage: "Limit the time when known peer has access to us, outside of it tunnel, SOCKS5 and VPN gateway are suspended",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+							&cli.DurationFlag{
+								Name:     "expires_in",
+								Usage:    "access lifetime from now, zero means no expiry",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "schedule",
+								Usage:    "weekly window in local time in format '[days ]HH:MM-HH:MM', e.g. 'sat sun 22:00-02:00', can be repeated",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "on_expire",
+								Usage:    "action on expiry: suspend, remove or block",
+								Required: false,
+								Value:    config.AccessOnExpireSuspend,
+							},
+							&cli.BoolFlag{
+								Name:     "exit_node",
+								Usage:    "limit only using us as exit node and VPN gateway",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "clear",
+								Usage:    "remove all limits",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							access := config.PeerAccess{}
+							if !c.Bool("clear") {
+								if c.Duration("expires_in") == 0 && len(c.StringSlice("schedule")) == 0 {
+									return errors.New("provide --expires_in, --schedule or --clear")
+								}
+								if c.Duration("expires_in") != 0 {
+									access.ExpiresAt = time.Now().Add(c.Duration("expires_in")).Truncate(time.Second)
+								}
+								for _, value := range c.StringSlice("schedule") {
+									window, err := parseAccessWindow(value)
+									if err != nil {
+										return err
+									}
+									access.Schedule = append(access.Schedule, window)
+								}
+								access.OnExpire = c.String("on_expire")
+							}
+							return setPeerAccess(a.api, c.String("pid"), c.Bool("exit_node"), access, c.App.Writer)
+						},
+					},
+					{
+						Name:  "permissions",
+						Usage: "Print own and effective permissions of known peer",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							return printPeerPermissions(a.api, c.String("pid"), c.App.Writer)
+						},
+					},
+					{
+						Name:  "presence",
+						Usage: "Print history of known peer connections and its uptime",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							return printPeerPresence(a.api, c.String("pid"), c.App.Writer)
+						},
+					},
+					{
+						Name:  "notify",
+						Usage: "Notify via tray and presence webhooks when known peer goes online or offline, without flags disables notifications",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "pid",
+								Usage:    "peer id",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "online",
+								Usage:    "notify when peer goes online",
+								Required: false,
+							},
+							&cli.BoolFlag{
+								Name:     "offline",
+								Usage:    "notify when peer goes offline",
+								Required: false,
+							},
+						},
+						Before: a.initApiAndPeerIdRequired,
+						Action: func(c *cli.Context) error {
+							notify := config.PresenceNotify{Online: c.Bool("online"), Offline: c.Bool("offline")}
+							return setPeerPresenceNotify(a.api, c.String("pid"), notify, c.App.Writer)
+						},
+					},
+					{
+						Name:  "groups",
+						Usage: "Manage peer groups, members inherit group permissions",
+						Subcommands: []*cli.Command{
+							{
+								Name:   "list",
+								Usage:  "Print peer groups",
+								Before: a.initApiConnection,
+								Action: func(c *cli.Context) error {
+									return printPeerGroups(a.api, c.App.Writer)
+								},
+							},
+							{
+								Name:  "update",
+								Usage: "Create peer group or replace its permissions",
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:     "name",
+										Usage:    "group name",
+										Required: true,
+									},
+									&cli.BoolFlag{
+										Name:     "allow_exit_node",
+										Usage:    "allow members to use this node as exit node and VPN gateway",
+										Required: false,
+									},
+									&cli.BoolFlag{
+										Name:     "allow_relay",
+										Usage:    "allow members to use this node as relay",
+										Required: false,
+									},
+									&cli.BoolFlag{
+										Name:     "hide_from_dns",
+										Usage:    "don't resolve domain names of members",
+										Required: false,
+									},
+								},
+								Before: a.initApiConnection,
+								Action: func(c *cli.Context) error {
+									request := entity.UpdatePeerGroupRequest{
+										Name:                 c.String("name"),
+										AllowUsingAsExitNode: c.Bool("allow_exit_node"),
+										AllowUsingAsRelay:    c.Bool("allow_relay"),
+										HideFromDNS:          c.Bool("hide_from_dns"),
+									}
+									return updatePeerGroup(a.api, request, c.App.Writer)
+								},
+							},
+							{
+								Name:  "remove",
+								Usage: "Remove peer group, its members keep their own permissions",
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:     "name",
+										Usage:    "group name",
+										Required: true,
+									},
+								},
+								Before: a.initApiConnection,
+								Action: func(c *cli.Context) error {
+									return removePeerGroup(a.api, c.String("name"), c.App.Writer)
+								},
+							},
+						},
+					},
+					{
+						Name:  "invite",
+						Usage: "Manage invite links which are accepted without confirmation",
+						Subcommands: []*cli.Command{
+							{
+								Name:  "create",
+								Usage: "Create invite link and print it with QR code",
+								Flags: []cli.Flag{
+									&cli.DurationFlag{
+										Name:     "ttl",
+										Usage:    "invite lifetime",
+										Required: false,
+										Value:    24 * time.Hour,
+									},
+									&cli.IntFlag{
+										Name:     "uses",
+										Usage:    "how many peers can join with the invite",
+										Required: false,
+										Value:    1,
+									},
+									&cli.BoolFlag{
+										Name:     "allow_exit_node",
+										Usage:    "allow joined peers to use this node as exit node",
+										Required: false,
+									},
+									&cli.BoolFlag{
+										Name:     "allow_relay",
+										Usage:    "allow joined peers to use this node as relay",
+										Required: false,
+									},
+									&cli.BoolFlag{
+										Name:     "without_addrs",
+										Usage:    "don't include addresses of this node in the invite",
+										Required: false,
+									},
+								},
+								Before: a.initApiConnection,
+								Action: func(c *cli.Context) error {
+									request := entity.CreateInviteRequest{
+										TTLSec:               int(c.Duration("ttl").Seconds()),
+										MaxUses:              c.Int("uses"),
+										AllowUsingAsExitNode: c.Bool("allow_exit_node"),
+										AllowUsingAsRelay:    c.Bool("allow_relay"),
+										WithoutAddrs:         c.Bool("without_addrs"),
+									}
+									return createInvite(a.api, request, c.App.Writer)
+								},
+							},
+							{
+								Name:   "list",
+								Usage:  "Print active invites",
+								Before: a.initApiConnection,
+								Action: func(c *cli.Context) error {
+									return printInvites(a.api, c.App.Writer)
+								},
+							},
+							{
+								Name:  "revoke",
+								Usage: "Revoke invite, peers which already joined are kept",
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:     "id",
+										Usage:    "invite id",
+										Required: true,
+									},
+								},
+								Before: a.initApiConnection,
+								Action: func(c *cli.Context) error {
+									return revokeInvite(a.api, c.String("id"), c.App.Writer)
+								},
+							},
+						},
+					},
+					{
+						Name:  "join",
+						Usage: "Add peer using its invite link",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "invite",
+								Usage:    "awl://invite/ link or token",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "peer name, by default name from the invite is used",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "ip",
+								Usage:    "override peer IP address",
+								Required: false,
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return joinInvite(a.api, c.String("invite"), c.String("name"), c.String("ip"), c.App.Writer)
+						},
+					},
 				},
 			},
 			{
@@ -451,6 +1000,61 @@ func (a *Application) init() {
 					},
 				},
 			},
+			{
+				Name:  "private_network",
+				Usage: "Group of commands to manage private swarm, only peers with the same network key can connect",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "status",
+						Usage:  "Print private network settings",
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return privateNetworkStatus(a.api, c.App.Writer)
+						},
+					},
+					{
+						Name:   "generate_key",
+						Usage:  "Generate new network key without applying it",
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return privateNetworkGenerateKey(a.api, c.App.Writer)
+						},
+					},
+					{
+						Name:  "enable",
+						Usage: "Enable private network mode, changes are applied after restart",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "key",
+								Usage:    "hex-encoded network key, new key is generated if empty",
+								Required: false,
+							},
+							&cli.StringFlag{
+								Name:     "dht_prefix",
+								Usage:    "custom DHT protocol prefix, e.g. /mynet",
+								Required: false,
+							},
+							&cli.StringSliceFlag{
+								Name:     "bootstrap",
+								Usage:    "multiaddr of bootstrap peer in private network, can be repeated",
+								Required: false,
+							},
+						},
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return privateNetworkEnable(a.api, c.String("key"), c.String("dht_prefix"), c.StringSlice("bootstrap"), c.App.Writer)
+						},
+					},
+					{
+						Name:   "disable",
+						Usage:  "Disable private network mode, changes are applied after restart",
+						Before: a.initApiConnection,
+						Action: func(c *cli.Context) error {
+							return privateNetworkDisable(a.api, c.App.Writer)
+						},
+					},
+				},
+			},
 			{
 				Name:    "logs",
 				Aliases: []string{"log"},
@@ -498,6 +1102,14 @@ func (a *Application) init() {
 					return nil
 				},
 			},
+			{
+				Name:   "netcheck",
+				Usage:  "Prints connectivity diagnostics: NAT type, reachability, bootstrap peers, hole punching results",
+				Before: a.initApiConnection,
+				Action: func(c *cli.Context) error {
+					return printNetcheck(a.api, c.App.Writer)
+				},
+			},
 			{
 				Name:  "update",
 				Usage: "Updates awl to the latest version",
@@ -629,6 +1241,28 @@ func (a *Application) initApiAndPeerId(c *cli.Context, isRequired bool) error {
 	return c.Set("pid", pid)
 }
 
+// readPassphrase reads passphrase without echo from terminal or a line from input.
+func (a *Application) readPassphrase(message string) (string, error) {
+	_, err := fmt.Fprint(a.cliapp.Writer, message)
+	if err != nil {
+		return "", err
+	}
+	if a.cliapp.Reader == os.Stdin && term.IsTerminal(int(os.Stdin.Fd())) { //nolint:gosec
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd())) //nolint:gosec
+		_, _ = fmt.Fprintln(a.cliapp.Writer)
+		return string(passphrase), err
+	}
+
+	if a.inputReader == nil {
+		a.inputReader = bufio.NewReader(a.cliapp.Reader)
+	}
+	s, err := a.inputReader.ReadString('\n')
+	if err != nil && s == "" {
+		return "", err
+	}
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
 func (a *Application) yesNoPrompt(message string, def bool) (bool, error) {
 	choices := "Yes/no, default yes"
 	if !def {
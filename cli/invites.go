@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/entity"
+)
+
+func createInvite(api *apiclient.Client, request entity.CreateInviteRequest, w io.Writer) error {
+	invite, err := api.CreateInvite(request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "invite %s expires at %s, max uses: %d\n", invite.ID, invite.ExpiresAt.Local().Format(time.DateTime), invite.MaxUses)
+	fmt.Fprintln(w, invite.URI)
+	qrterminal.GenerateHalfBlock(invite.URI, qrterminal.L, w)
+
+	return nil
+}
+
+func printInvites(api *apiclient.Client, w io.Writer) error {
+	invites, err := api.Invites()
+	if err != nil {
+		return err
+	}
+	if len(invites) == 0 {
+		fmt.Fprintln(w, "you have no active invites")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"id", "expires at", "uses", "exit node", "relay"})
+	for _, invite := range invites {
+		table.Append([]string{
+			invite.ID,
+			invite.ExpiresAt.Local().Format(time.DateTime),
+			fmt.Sprintf("%d/%d", invite.Uses, invite.MaxUses),
+			strconv.FormatBool(invite.AllowUsingAsExitNode),
+			strconv.FormatBool(invite.AllowUsingAsRelay),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+func revokeInvite(api *apiclient.Client, id string, w io.Writer) error {
+	err := api.RevokeInvite(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "invite revoked successfully")
+	return nil
+}
+
+func joinInvite(api *apiclient.Client, invite, alias, ipAddr string, w io.Writer) error {
+	peerID, err := api.JoinInvite(invite, alias, ipAddr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "joined peer %s, it will accept the request automatically\n", peerID)
+	return nil
+}
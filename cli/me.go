@@ -10,6 +10,7 @@ import (
 	"github.com/olekukonko/tablewriter"
 
 	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/awldns"
 	"github.com/anywherelan/awl/entity"
 )
 
@@ -19,11 +20,20 @@ func printStatus(api *apiclient.Client, w io.Writer) error {
 		return err
 	}
 
+	networkMode := "online"
+	if stats.OfflineMode {
+		networkMode = "offline (LAN only, isolated from internet)"
+	}
+	if stats.PrivateNetwork {
+		networkMode += ", private network"
+	}
 	rows := [][]string{
+		{"Network mode", networkMode},
 		{"Download rate", fmt.Sprintf("%s (%s)", stats.NetworkStatsInIECUnits.RateIn, stats.NetworkStatsInIECUnits.TotalIn)},
 		{"Upload rate", fmt.Sprintf("%s (%s)", stats.NetworkStatsInIECUnits.RateOut, stats.NetworkStatsInIECUnits.TotalOut)},
 		{"Bootstrap peers", fmt.Sprintf("%d/%d", stats.ConnectedBootstrapPeers, stats.TotalBootstrapPeers)},
 		{"DNS", formatWorkingStatus(stats.IsAwlDNSSetAsSystem)},
+		{"Announced domain names", formatDomainNames(append([]string{stats.DomainName}, stats.DomainAliases...))},
 		{"SOCKS5 Proxy", formatWorkingStatus(stats.SOCKS5.ListenerEnabled)},
 		{"SOCKS5 Proxy address", stats.SOCKS5.ListenAddress},
 		{"SOCKS5 Proxy exit node", stats.SOCKS5.UsingPeerName},
@@ -41,6 +51,8 @@ func printStatus(api *apiclient.Client, w io.Writer) error {
 	}
 	rows = append(rows,
 		[]string{"VPN gateway server", formatWorkingStatus(stats.VPNGateway.ServerEnabled)},
+		[]string{"Relay service", formatWorkingStatus(stats.RelayServiceEnabled)},
+		[]string{"Config secrets", formatSecretsEncryption(stats.SecretsEncryption)},
 		[]string{"Reachability", strings.ToLower(stats.Reachability)},
 		[]string{"Uptime", stats.Uptime.Round(time.Second).String()},
 		[]string{"Server version", stats.ServerVersion},
@@ -54,6 +66,19 @@ func printStatus(api *apiclient.Client, w io.Writer) error {
 	return nil
 }
 
+func formatDomainNames(names []string) string {
+	formatted := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != "" {
+			formatted = append(formatted, fmt.Sprintf("%s.%s", name, awldns.LocalDomain))
+		}
+	}
+	if len(formatted) == 0 {
+		return "-"
+	}
+	return strings.Join(formatted, ", ")
+}
+
 func formatWorkingStatus(working bool) string {
 	if working {
 		return "working"
@@ -103,6 +128,48 @@ func renameMe(api *apiclient.Client, newName string, w io.Writer) error {
 	return nil
 }
 
+func rotateIdentity(api *apiclient.Client, w io.Writer) error {
+	response, err := api.RotateIdentity()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "identity key rotated, new peer id: %s\n", response.NewPeerID)
+	fmt.Fprintln(w, "restart awl to use it, known peers keep your settings for the new peer id")
+
+	return nil
+}
+
+func setMyDeviceInfo(api *apiclient.Client, deviceInfo string, w io.Writer) error {
+	peerInfo, err := api.PeerInfo()
+	if err != nil {
+		return err
+	}
+	err = api.UpdateMyDeviceInfo(peerInfo.Name, deviceInfo)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "my device info updated successfully")
+
+	return nil
+}
+
+func setMyDomainNames(api *apiclient.Client, domainName string, aliases []string, w io.Writer) error {
+	peerInfo, err := api.PeerInfo()
+	if err != nil {
+		return err
+	}
+	err = api.UpdateMyDomainNames(peerInfo.Name, domainName, aliases)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "my domain names updated successfully")
+
+	return nil
+}
+
 func listProxies(api *apiclient.Client, w io.Writer) error {
 	proxies, err := api.ListAvailableProxies()
 	if err != nil {
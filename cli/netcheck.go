@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/p2p"
+)
+
+func printNetcheck(api *apiclient.Client, w io.Writer) error {
+	report, err := api.Netcheck()
+	if err != nil {
+		return err
+	}
+	peerNames := make(map[string]string)
+	knownPeers, err := api.KnownPeers()
+	if err != nil {
+		return err
+	}
+	for _, kp := range knownPeers {
+		peerNames[kp.PeerID] = kp.Alias
+	}
+
+	portMapping := "disabled"
+	if report.PortMapping.Enabled {
+		portMapping = "no NAT device found"
+		if report.PortMapping.NATDeviceFound {
+			portMapping = fmt.Sprintf("NAT device found, %d mapped", len(report.PortMapping.Mappings))
+		}
+	}
+	ipv6 := "no"
+	if report.IPv6.Works {
+		ipv6 = fmt.Sprintf("yes, %d peers connected", report.IPv6.ConnectedPeers)
+	} else if report.IPv6.HasGlobalAddress {
+		ipv6 = "has global address, no peers connected"
+	}
+
+	rows := [][]string{
+		{"NAT type", report.NATType},
+		{"Reachability", strings.ToLower(report.Reachability)},
+		{"UDP", formatTransportReachability(report.UDP)},
+		{"TCP", formatTransportReachability(report.TCP)},
+		{"Port mapping", portMapping},
+		{"Public addresses", formatList(report.PublicAddrs)},
+		{"IPv6", ipv6},
+		{"Relay reservations", formatList(report.RelayReservations)},
+	}
+	if proxy := report.UpstreamProxy; proxy != nil {
+		status := "reachable"
+		if !proxy.Reachable {
+			status = "unreachable: " + proxy.Error
+		}
+		dials := fmt.Sprintf("%d active, %d dials, %d failed", proxy.ActiveConns, proxy.Dials, proxy.FailedDials)
+		if proxy.LastDialError != "" {
+			dials += "\nlast error: " + proxy.LastDialError
+		}
+		rows = append(rows,
+			[]string{"Upstream proxy", fmt.Sprintf("%s (%s)", proxy.URL, status)},
+			[]string{"Proxied connections", dials},
+		)
+	}
+	table := tablewriter.NewWriter(w)
+	table.AppendBulk(rows)
+	table.Render()
+
+	fmt.Fprintln(w, "\nBootstrap peers:")
+	table = tablewriter.NewWriter(w)
+	table.SetHeader([]string{"peer id", "connected", "ping", "error"})
+	for _, peerID := range sortedKeys(report.BootstrapPeers) {
+		info := report.BootstrapPeers[peerID]
+		ping := ""
+		if info.Connected && info.Error == "" {
+			ping = time.Duration(info.Ping).Round(time.Millisecond).String()
+		}
+		table.Append([]string{peerID, fmt.Sprintf("%v", info.Connected), ping, info.Error})
+	}
+	table.Render()
+
+	if len(report.HolePunching) == 0 {
+		fmt.Fprintln(w, "\nHole punching: no attempts yet")
+		return nil
+	}
+	fmt.Fprintln(w, "\nHole punching:")
+	table = tablewriter.NewWriter(w)
+	table.SetHeader([]string{"peer", "time", "type", "result", "elapsed"})
+	for _, peerID := range sortedKeys(report.HolePunching) {
+		name := peerID
+		if peerName, ok := peerNames[peerID]; ok {
+			name = peerName
+		}
+		for _, event := range report.HolePunching[peerID] {
+			result := "success"
+			if !event.Success {
+				result = "failed"
+				if event.Error != "" {
+					result += ": " + event.Error
+				}
+			}
+			table.Append([]string{
+				name,
+				event.Time.Format(time.DateTime),
+				event.Type,
+				result,
+				time.Duration(event.Elapsed).Round(time.Millisecond).String(),
+			})
+		}
+	}
+	table.Render()
+
+	return nil
+}
+
+func formatTransportReachability(info p2p.TransportReachability) string {
+	return fmt.Sprintf("%s (NAT device: %s)", info.Status, strings.ToLower(info.NATDeviceType))
+}
+
+func formatList(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, "\n")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+)
+
+// parseAccessWindow parses window in format "[days ]HH:MM-HH:MM", e.g. "sat sun 22:00-02:00".
+func parseAccessWindow(value string) (config.AccessWindow, error) {
+	var window config.AccessWindow
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 {
+		return window, fmt.Errorf("invalid schedule %q, expected format: [days ]HH:MM-HH:MM", value)
+	}
+	start, end, found := strings.Cut(fields[len(fields)-1], "-")
+	if !found {
+		return window, fmt.Errorf("invalid schedule %q, expected format: [days ]HH:MM-HH:MM", value)
+	}
+	window.Start = start
+	window.End = end
+	if len(fields) > 1 {
+		window.Days = fields[:len(fields)-1]
+	}
+
+	return window, nil
+}
+
+func setPeerAccess(api *apiclient.Client, peerID string, exitNode bool, access config.PeerAccess, w io.Writer) error {
+	pcfg, err := api.KnownPeerConfig(peerID)
+	if err != nil {
+		return err
+	}
+
+	request := entity.UpdatePeerSettingsRequest{
+		PeerID:               peerID,
+		Alias:                pcfg.Alias,
+		DomainName:           pcfg.DomainName,
+		IPAddr:               pcfg.IPAddr,
+		AllowUsingAsExitNode: pcfg.WeAllowUsingAsExitNode,
+	}
+	if exitNode {
+		request.ExitNodeAccess = &access
+	} else {
+		request.Access = &access
+	}
+	err = api.UpdatePeerSettings(request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "peer access updated successfully")
+	return nil
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/entity"
+)
+
+func printPeerGroups(api *apiclient.Client, w io.Writer) error {
+	groups, err := api.PeerGroups()
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(w, "you have no peer groups")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"name", "exit node", "relay", "hide from dns", "members"})
+	for _, group := range groups {
+		table.Append([]string{
+			group.Name,
+			strconv.FormatBool(group.AllowUsingAsExitNode),
+			strconv.FormatBool(group.AllowUsingAsRelay),
+			strconv.FormatBool(group.HideFromDNS),
+			strconv.Itoa(len(group.Members)),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+func updatePeerGroup(api *apiclient.Client, request entity.UpdatePeerGroupRequest, w io.Writer) error {
+	err := api.UpdatePeerGroup(request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "peer group updated successfully")
+	return nil
+}
+
+func removePeerGroup(api *apiclient.Client, name string, w io.Writer) error {
+	err := api.RemovePeerGroup(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "peer group removed successfully")
+	return nil
+}
+
+func setPeerGroups(api *apiclient.Client, peerID string, groups []string, w io.Writer) error {
+	pcfg, err := api.KnownPeerConfig(peerID)
+	if err != nil {
+		return err
+	}
+
+	if groups == nil {
+		groups = []string{}
+	}
+	err = api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peerID,
+		Alias:                pcfg.Alias,
+		DomainName:           pcfg.DomainName,
+		IPAddr:               pcfg.IPAddr,
+		AllowUsingAsExitNode: pcfg.WeAllowUsingAsExitNode,
+		Groups:               groups,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "peer groups updated successfully")
+	return nil
+}
+
+func printPeerPermissions(api *apiclient.Client, peerID string, w io.Writer) error {
+	perms, err := api.PeerPermissions(peerID)
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"permission", "peer", "effective"})
+	table.AppendBulk([][]string{
+		{"Groups", strings.Join(perms.Groups, ", "), ""},
+		{"Exit node", strconv.FormatBool(perms.Own.AllowUsingAsExitNode), strconv.FormatBool(perms.Effective.AllowUsingAsExitNode)},
+		{"Relay", strconv.FormatBool(perms.Own.AllowUsingAsRelay), strconv.FormatBool(perms.Effective.AllowUsingAsRelay)},
+		{"Visible in DNS", strconv.FormatBool(perms.Own.VisibleInDNS), strconv.FormatBool(perms.Effective.VisibleInDNS)},
+		{"Suspended", "", strconv.FormatBool(perms.Effective.Suspended)},
+	})
+	table.Render()
+
+	return nil
+}
@@ -6,12 +6,14 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 
 	"github.com/anywherelan/awl/api/apiclient"
 	"github.com/anywherelan/awl/awldns"
 	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/protocol"
 )
 
 func printPeersStatus(api *apiclient.Client, format string, w io.Writer) error {
@@ -25,6 +27,7 @@ func printPeersStatus(api *apiclient.Client, format string, w io.Writer) error {
 		TableFormatConnection   = "c"
 		TableFormatVersion      = "v"
 		TableFormatExitNode     = "e"
+		TableFormatDevice       = "d"
 	)
 
 	fHeaderMap := map[string]string{
@@ -37,6 +40,7 @@ func printPeersStatus(api *apiclient.Client, format string, w io.Writer) error {
 		TableFormatConnection:   "connections\naddress | protocol",
 		TableFormatVersion:      "version",
 		TableFormatExitNode:     "exit node",
+		TableFormatDevice:       "device",
 	}
 
 	if len(format) < 1 {
@@ -80,8 +84,8 @@ func printPeersStatus(api *apiclient.Client, format string, w io.Writer) error {
 				if peer.DisplayName != "" {
 					info = append(info, peer.DisplayName)
 				}
-				if peer.DomainName != "" {
-					info = append(info, fmt.Sprintf("%s.%s", peer.DomainName, awldns.LocalDomain))
+				for _, domainName := range peer.DomainNames {
+					info = append(info, fmt.Sprintf("%s.%s", domainName, awldns.LocalDomain))
 				}
 				info = append(info, peer.IpAddr)
 
@@ -116,14 +120,31 @@ func printPeersStatus(api *apiclient.Client, format string, w io.Writer) error {
 				consStr := make([]string, 0, len(peer.Connections))
 				for _, con := range peer.Connections {
 					if con.ThroughRelay {
-						consStr = append(consStr, "through relay")
+						if con.RelayPeerName != "" {
+							consStr = append(consStr, fmt.Sprintf("through relay %s", con.RelayPeerName))
+						} else {
+							consStr = append(consStr, "through relay")
+						}
 						continue
 					}
 					consStr = append(consStr, fmt.Sprintf("%s | %s", con.Address, con.Protocol))
 				}
 				row = append(row, strings.Join(consStr, "\n"))
 			case TableFormatVersion:
-				row = append(row, peer.Version)
+				version := peer.Version
+				if peer.Compatibility == protocol.CompatibilityOutdated || peer.Compatibility == protocol.CompatibilityIncompatible {
+					version += fmt.Sprintf("\n(%s)", peer.Compatibility)
+				}
+				row = append(row, version)
+			case TableFormatDevice:
+				device := make([]string, 0, 2)
+				if peer.Capabilities != nil {
+					device = append(device, peer.Capabilities.OS+"/"+peer.Capabilities.Arch)
+					if peer.Capabilities.DeviceInfo != "" {
+						device = append(device, peer.Capabilities.DeviceInfo)
+					}
+				}
+				row = append(row, strings.Join(device, "\n"))
 			case TableFormatExitNode:
 				row = append(row, fmt.Sprintf("we allow:     %v\npeer allowed: %v", peer.WeAllowUsingAsExitNode, peer.AllowedUsingAsExitNode))
 			}
@@ -150,6 +171,20 @@ func printFriendRequests(api *apiclient.Client, w io.Writer) error {
 	return nil
 }
 
+func purgeFriendRequests(api *apiclient.Client, peerIDs []string, decline bool, w io.Writer) error {
+	response, err := api.PurgeAuthRequests(peerIDs, decline)
+	if err != nil {
+		return err
+	}
+
+	if decline {
+		fmt.Fprintf(w, "%d incoming requests declined\n", response.Purged)
+	} else {
+		fmt.Fprintf(w, "%d incoming requests removed\n", response.Purged)
+	}
+	return nil
+}
+
 func getPeerIdByAlias(api *apiclient.Client, alias string) (string, error) {
 	if alias == "" {
 		return "", errors.New("name is empty")
@@ -271,6 +306,35 @@ func changePeerIP(api *apiclient.Client, peerID, newIP string, w io.Writer) erro
 	return nil
 }
 
+func updatePeerStaticAddrs(api *apiclient.Client, peerID string, addrs []string, w io.Writer) error {
+	pcfg, err := api.KnownPeerConfig(peerID)
+	if err != nil {
+		return err
+	}
+
+	if addrs == nil {
+		addrs = []string{}
+	}
+	err = api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peerID,
+		Alias:                pcfg.Alias,
+		DomainName:           pcfg.DomainName,
+		IPAddr:               pcfg.IPAddr,
+		AllowUsingAsExitNode: pcfg.WeAllowUsingAsExitNode,
+		StaticAddrs:          addrs,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(addrs) == 0 {
+		fmt.Fprintln(w, "peer static addresses removed successfully")
+	} else {
+		fmt.Fprintln(w, "peer static addresses updated successfully")
+	}
+	return nil
+}
+
 func setAllowUsingAsExitNode(api *apiclient.Client, peerID string, allow bool, w io.Writer) error {
 	pcfg, err := api.KnownPeerConfig(peerID)
 	if err != nil {
@@ -291,3 +355,69 @@ func setAllowUsingAsExitNode(api *apiclient.Client, peerID string, allow bool, w
 	fmt.Fprintln(w, "AllowUsingAsExitNode config updated successfully")
 	return nil
 }
+
+func setAllowUsingAsRelay(api *apiclient.Client, peerID string, allow bool, w io.Writer) error {
+	pcfg, err := api.KnownPeerConfig(peerID)
+	if err != nil {
+		return err
+	}
+
+	err = api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peerID,
+		Alias:                pcfg.Alias,
+		DomainName:           pcfg.DomainName,
+		IPAddr:               pcfg.IPAddr,
+		AllowUsingAsExitNode: pcfg.WeAllowUsingAsExitNode,
+		AllowUsingAsRelay:    &allow,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "AllowUsingAsRelay config updated successfully")
+	return nil
+}
+
+func runSpeedTest(api *apiclient.Client, peerID, direction string, duration time.Duration, w io.Writer) error {
+	fmt.Fprintf(w, "running %s speed test for %s...\n", direction, duration)
+	result, err := api.SpeedTest(entity.SpeedTestRequest{
+		PeerID:      peerID,
+		Direction:   direction,
+		DurationSec: int(duration.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+
+	connection := "direct"
+	if result.ThroughRelay {
+		connection = "relay"
+	}
+	rows := [][]string{
+		{"Connection", connection},
+		{"Duration", result.Duration.String()},
+	}
+	if result.Upload != nil {
+		rows = append(rows, []string{"Upload", formatThroughput(*result.Upload)})
+	}
+	if result.Download != nil {
+		rows = append(rows, []string{"Download", formatThroughput(*result.Download)})
+	}
+	latency := result.Latency
+	rows = append(rows,
+		[]string{"RTT min/avg/max", fmt.Sprintf("%s / %s / %s",
+			latency.RTTMin.Round(time.Microsecond*100), latency.RTTAvg.Round(time.Microsecond*100), latency.RTTMax.Round(time.Microsecond*100))},
+		[]string{"Jitter", latency.Jitter.Round(time.Microsecond * 100).String()},
+		[]string{"Loss", fmt.Sprintf("%.1f%% (%d/%d)", latency.LossPercent, latency.ProbesLost, latency.ProbesSent)},
+	)
+
+	table := tablewriter.NewWriter(w)
+	table.AppendBulk(rows)
+	table.Render()
+
+	return nil
+}
+
+func formatThroughput(throughput entity.SpeedTestThroughput) string {
+	return fmt.Sprintf("%.2f Mbit/s (%.1f MiB transferred)", throughput.BitsPerSecond/1_000_000, float64(throughput.Bytes)/(1<<20))
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+)
+
+func printPeerPresence(api *apiclient.Client, peerID string, w io.Writer) error {
+	presence, err := api.PeerPresence(peerID)
+	if err != nil {
+		return err
+	}
+
+	status := "offline"
+	if presence.Online {
+		status = "online"
+	}
+	fmt.Fprintf(w, "Peer '%s' is %s\n", presence.DisplayName, status)
+	fmt.Fprintf(w, "Uptime: %.1f%% day, %.1f%% week, %.1f%% month (since %s)\n",
+		presence.Uptime.Day, presence.Uptime.Week, presence.Uptime.Month, presence.Since.Format("2006-01-02 15:04:05"))
+	if len(presence.Events) == 0 {
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"time", "status", "connection", "address family"})
+	for _, event := range presence.Events {
+		status := "offline"
+		if event.Online {
+			status = "online"
+		}
+		table.Append([]string{event.Time.Format("2006-01-02 15:04:05"), status, event.Connection, event.AddressFamily})
+	}
+	table.Render()
+
+	return nil
+}
+
+func setPeerPresenceNotify(api *apiclient.Client, peerID string, notify config.PresenceNotify, w io.Writer) error {
+	pcfg, err := api.KnownPeerConfig(peerID)
+	if err != nil {
+		return err
+	}
+
+	err = api.UpdatePeerSettings(entity.UpdatePeerSettingsRequest{
+		PeerID:               peerID,
+		Alias:                pcfg.Alias,
+		DomainName:           pcfg.DomainName,
+		IPAddr:               pcfg.IPAddr,
+		AllowUsingAsExitNode: pcfg.WeAllowUsingAsExitNode,
+		PresenceNotify:       &notify,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "peer presence notifications updated successfully")
+	return nil
+}
+
+func printPresenceWebhooks(api *apiclient.Client, w io.Writer) error {
+	webhookURLs, err := api.PresenceWebhooks()
+	if err != nil {
+		return err
+	}
+	if len(webhookURLs) == 0 {
+		fmt.Fprintln(w, "you have no presence webhooks")
+		return nil
+	}
+
+	fmt.Fprintln(w, strings.Join(webhookURLs, "\n"))
+	return nil
+}
+
+func setPresenceWebhooks(api *apiclient.Client, webhookURLs []string, w io.Writer) error {
+	err := api.UpdatePresenceWebhooks(webhookURLs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "presence webhooks updated successfully")
+	return nil
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/entity"
+)
+
+func privateNetworkStatus(api *apiclient.Client, w io.Writer) error {
+	info, err := api.PrivateNetwork()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Private network enabled: %v\n", info.Enabled)
+	fmt.Fprintf(w, "Private network active:  %v\n", info.Active)
+	if info.Enabled != info.Active {
+		fmt.Fprintln(w, "Restart awl to apply changes")
+	}
+	if info.Enabled {
+		fmt.Fprintf(w, "Network key:             %s\n", info.NetworkKey)
+	}
+	if info.DHTProtocolPrefix != "" {
+		fmt.Fprintf(w, "DHT protocol prefix:     %s\n", info.DHTProtocolPrefix)
+	}
+	if len(info.BootstrapPeers) > 0 {
+		fmt.Fprintf(w, "Bootstrap peers:\n  %s\n", strings.Join(info.BootstrapPeers, "\n  "))
+	}
+
+	return nil
+}
+
+func privateNetworkGenerateKey(api *apiclient.Client, w io.Writer) error {
+	key, err := api.GeneratePrivateNetworkKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, key)
+	return nil
+}
+
+// privateNetworkEnable sets network key, generates new one if key is empty.
+// Current DHT prefix and bootstrap peers are kept if they are not provided.
+func privateNetworkEnable(api *apiclient.Client, key, dhtPrefix string, bootstrapPeers []string, w io.Writer) error {
+	info, err := api.PrivateNetwork()
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		key, err = api.GeneratePrivateNetworkKey()
+		if err != nil {
+			return err
+		}
+	}
+	if dhtPrefix == "" {
+		dhtPrefix = info.DHTProtocolPrefix
+	}
+
+	err = api.UpdatePrivateNetwork(entity.UpdatePrivateNetworkRequest{
+		NetworkKey:        key,
+		DHTProtocolPrefix: dhtPrefix,
+		BootstrapPeers:    bootstrapPeers,
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err = api.PrivateNetwork()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Private network enabled, restart awl to apply changes")
+	fmt.Fprintf(w, "Share network key with your peers: %s\n", info.NetworkKey)
+	return nil
+}
+
+func privateNetworkDisable(api *apiclient.Client, w io.Writer) error {
+	err := api.UpdatePrivateNetwork(entity.UpdatePrivateNetworkRequest{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Private network disabled, restart awl to apply changes")
+	return nil
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/anywherelan/awl/api/apiclient"
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+)
+
+const secretsProviderNone = "none"
+
+func formatSecretsEncryption(provider string) string {
+	if provider == "" {
+		return "plain text"
+	}
+	return "encrypted by " + provider
+}
+
+func setSecretsEncryption(api *apiclient.Client, provider, keyFile string, readPassphrase func(string) (string, error), w io.Writer) error {
+	peerInfo, err := api.PeerInfo()
+	if err != nil {
+		return err
+	}
+
+	request := entity.UpdateSecretsEncryptionRequest{}
+	if provider != secretsProviderNone {
+		request.Provider = provider
+	}
+	if keyFile != "" {
+		// key file is opened by the server, which can be started in another directory
+		request.KeyFile, err = filepath.Abs(keyFile)
+		if err != nil {
+			return err
+		}
+	}
+	if peerInfo.SecretsEncryption == config.SecretsProviderPassphrase {
+		request.CurrentPassphrase, err = readPassphrase("current passphrase: ")
+		if err != nil {
+			return err
+		}
+	}
+	if request.Provider == config.SecretsProviderPassphrase {
+		request.Passphrase, err = readPassphrase("new passphrase: ")
+		if err != nil {
+			return err
+		}
+		repeated, err := readPassphrase("repeat new passphrase: ")
+		if err != nil {
+			return err
+		}
+		if repeated != request.Passphrase {
+			return errors.New("passphrases don't match")
+		}
+	}
+
+	err = api.UpdateSecretsEncryption(request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "config secrets: %s\n", formatSecretsEncryption(request.Provider))
+	return nil
+}
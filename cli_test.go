@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/anywherelan/awl/cli"
 	"github.com/anywherelan/awl/config"
 	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/protocol"
 )
 
 // runCLIAddr runs a CLI command against the given API address and returns stdout output.
@@ -43,7 +46,7 @@ func TestCLI_Me(t *testing.T) {
 			"Download rate", "Upload rate", "Bootstrap peers",
 			"DNS", "SOCKS5 Proxy", "SOCKS5 Proxy address",
 			"SOCKS5 Proxy exit node",
-			"VPN gateway client", "VPN gateway server",
+			"VPN gateway client", "VPN gateway server", "Relay service", "Config secrets",
 			"Reachability", "Uptime", "Server version",
 		} {
 			require.Contains(t, out, label)
@@ -89,6 +92,14 @@ func TestCLI_Me(t *testing.T) {
 		require.Contains(t, result, "Connections")
 	})
 
+	t.Run("Netcheck", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "netcheck")
+		require.NoError(t, err)
+		require.Contains(t, out, "NAT type")
+		require.Contains(t, out, "Bootstrap peers")
+		require.Contains(t, out, "Hole punching")
+	})
+
 	t.Run("Rename", func(t *testing.T) {
 		out, err := runCLI(ts, peer1, "me", "rename", "--name", "new-test-name")
 		require.NoError(t, err)
@@ -97,6 +108,73 @@ func TestCLI_Me(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "new-test-name", info.Name)
 	})
+
+	t.Run("SetDeviceInfo", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "me", "set_device_info", "--info", "home server")
+		require.NoError(t, err)
+		require.Equal(t, "my device info updated successfully\n", out)
+		info, err := peer1.api.PeerInfo()
+		require.NoError(t, err)
+		require.Equal(t, "new-test-name", info.Name)
+		require.Equal(t, "home server", info.Capabilities.DeviceInfo)
+		require.Equal(t, protocol.ProtocolVersion, info.Capabilities.ProtocolVersion)
+	})
+
+	t.Run("SetSecretsEncryption", func(t *testing.T) {
+		keyFile := filepath.Join(t.TempDir(), "awl.key")
+		out, err := runCLI(ts, peer1, "me", "set_secrets_encryption", "--provider", "keyfile", "--key_file", keyFile)
+		require.NoError(t, err)
+		require.Equal(t, "config secrets: encrypted by keyfile\n", out)
+		require.FileExists(t, keyFile)
+		data, err := os.ReadFile(filepath.Join(peer1.app.Conf.DataDir(), config.AppConfigFilename))
+		require.NoError(t, err)
+		require.NotContains(t, string(data), peer1.app.Conf.P2pNode.Identity)
+
+		out, err = runCLI(ts, peer1, "me", "status")
+		require.NoError(t, err)
+		require.Contains(t, out, "encrypted by keyfile")
+
+		out, err = runCLI(ts, peer1, "me", "set_secrets_encryption", "--provider", "none")
+		require.NoError(t, err)
+		require.Equal(t, "config secrets: plain text\n", out)
+		data, err = os.ReadFile(filepath.Join(peer1.app.Conf.DataDir(), config.AppConfigFilename))
+		require.NoError(t, err)
+		require.Contains(t, string(data), peer1.app.Conf.P2pNode.Identity)
+	})
+
+	t.Run("PresenceWebhooks", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "me", "presence_webhooks")
+		require.NoError(t, err)
+		require.Equal(t, "you have no presence webhooks\n", out)
+
+		_, err = runCLI(ts, peer1, "me", "set_presence_webhooks", "--url", "ftp://example.com")
+		require.ErrorContains(t, err, "expected http or https url")
+		out, err = runCLI(ts, peer1, "me", "set_presence_webhooks", "--url", "https://example.com/hook")
+		require.NoError(t, err)
+		require.Equal(t, "presence webhooks updated successfully\n", out)
+		out, err = runCLI(ts, peer1, "me", "presence_webhooks")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/hook\n", out)
+	})
+
+	t.Run("SetDomain", func(t *testing.T) {
+		_, err := runCLI(ts, peer1, "me", "set_domain", "--domain", "bad name")
+		require.ErrorContains(t, err, "invalid domain name")
+		out, err := runCLI(ts, peer1, "me", "set_domain", "--domain", "nas", "--alias", "files")
+		require.NoError(t, err)
+		require.Equal(t, "my domain names updated successfully\n", out)
+		out, err = runCLI(ts, peer1, "me", "status")
+		require.NoError(t, err)
+		require.Contains(t, out, "nas.awl, files.awl")
+	})
+
+	t.Run("RotateIdentity", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "me", "rotate_identity")
+		require.NoError(t, err)
+		require.Contains(t, out, "identity key rotated, new peer id: ")
+		require.NotEmpty(t, peer1.app.Conf.GetKeyRotations())
+		require.NotContains(t, out, peer1.PeerID())
+	})
 }
 
 // TestCLI_PeersSinglePeer covers peers/* error and empty-state cases using one peer.
@@ -186,6 +264,13 @@ func TestCLI_PeersRequests_WithRequest(t *testing.T) {
 	expected := fmt.Sprintf("Name: '%s' peerID: %s suggestedIP: %s\n",
 		reqs[0].Name, reqs[0].PeerID, reqs[0].SuggestedIP)
 	require.Equal(t, expected, out)
+
+	out, err = runCLI(ts, peer1, "peers", "purge_requests", "--pid", peer2.PeerID())
+	require.NoError(t, err)
+	require.Equal(t, "1 incoming requests removed\n", out)
+	reqs, err = peer1.api.AuthRequests()
+	require.NoError(t, err)
+	require.Empty(t, reqs)
 }
 
 // TestCLI_PeersAdd covers the two branches of "peers add": sending a new request,
@@ -251,7 +336,7 @@ func TestCLI_PeersRename(t *testing.T) {
 	})
 }
 
-// TestCLI_PeersUpdate covers update_domain, update_ip, and allow_exit_node on a shared pair.
+// TestCLI_PeersUpdate covers update_domain, update_ip, allow_exit_node and update_addrs on a shared pair.
 // Each subtest mutates an independent field of peer2's config.
 func TestCLI_PeersUpdate(t *testing.T) {
 	ts := NewTestSuite(t)
@@ -285,6 +370,159 @@ func TestCLI_PeersUpdate(t *testing.T) {
 		require.NoError(t, err)
 		require.True(t, pcfg.WeAllowUsingAsExitNode)
 	})
+
+	t.Run("AllowRelay", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "peers", "allow_relay", "--pid", peer2.PeerID(), "--allow")
+		require.NoError(t, err)
+		require.Equal(t, "AllowUsingAsRelay config updated successfully\n", out)
+		pcfg, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.True(t, pcfg.WeAllowUsingAsRelay)
+		require.True(t, pcfg.WeAllowUsingAsExitNode)
+	})
+
+	t.Run("Invites", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "peers", "invite", "create", "--ttl", "1h", "--uses", "2", "--without_addrs")
+		require.NoError(t, err)
+		require.Contains(t, out, protocol.InviteURIPrefix)
+		invites, err := peer1.api.Invites()
+		require.NoError(t, err)
+		require.Len(t, invites, 1)
+		require.Equal(t, 2, invites[0].MaxUses)
+		token, err := protocol.ParseInviteToken(invites[0].URI)
+		require.NoError(t, err)
+		require.Empty(t, token.Addrs)
+
+		out, err = runCLI(ts, peer1, "peers", "invite", "list")
+		require.NoError(t, err)
+		require.Contains(t, out, invites[0].ID)
+
+		out, err = runCLI(ts, peer1, "peers", "invite", "revoke", "--id", invites[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, "invite revoked successfully\n", out)
+		out, err = runCLI(ts, peer1, "peers", "invite", "list")
+		require.NoError(t, err)
+		require.Equal(t, "you have no active invites\n", out)
+	})
+
+	t.Run("StaticAddrs", func(t *testing.T) {
+		addr := "/ip4/192.168.1.10/udp/4363/quic-v1"
+		out, err := runCLI(ts, peer1, "peers", "update_addrs", "--pid", peer2.PeerID(),
+			"--addr", addr+"/p2p/"+peer2.PeerID(), "--addr", "/ip4/192.168.1.10/tcp/4363")
+		require.NoError(t, err)
+		require.Equal(t, "peer static addresses updated successfully\n", out)
+		pcfg, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Equal(t, []string{addr, "/ip4/192.168.1.10/tcp/4363"}, pcfg.StaticAddrs)
+		require.True(t, pcfg.WeAllowUsingAsExitNode)
+
+		_, err = runCLI(ts, peer1, "peers", "update_addrs", "--pid", peer2.PeerID(), "--addr", addr+"/p2p/"+peer1.PeerID())
+		require.Error(t, err)
+		_, err = runCLI(ts, peer1, "peers", "update_addrs", "--pid", peer2.PeerID())
+		require.Error(t, err)
+
+		out, err = runCLI(ts, peer1, "peers", "update_addrs", "--pid", peer2.PeerID(), "--clear")
+		require.NoError(t, err)
+		require.Equal(t, "peer static addresses removed successfully\n", out)
+		pcfg, err = peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Empty(t, pcfg.StaticAddrs)
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "peers", "groups", "list")
+		require.NoError(t, err)
+		require.Equal(t, "you have no peer groups\n", out)
+
+		out, err = runCLI(ts, peer1, "peers", "groups", "update", "--name", "family", "--hide_from_dns")
+		require.NoError(t, err)
+		require.Equal(t, "peer group updated successfully\n", out)
+		_, err = runCLI(ts, peer1, "peers", "set_groups", "--pid", peer2.PeerID(), "--group", "unknown")
+		require.Error(t, err)
+
+		out, err = runCLI(ts, peer1, "peers", "set_groups", "--pid", peer2.PeerID(), "--group", "family")
+		require.NoError(t, err)
+		require.Equal(t, "peer groups updated successfully\n", out)
+		pcfg, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Equal(t, []string{"family"}, pcfg.Groups)
+		require.True(t, pcfg.WeAllowUsingAsExitNode)
+
+		perms, err := peer1.api.PeerPermissions(peer2.PeerID())
+		require.NoError(t, err)
+		require.False(t, perms.Effective.VisibleInDNS)
+		out, err = runCLI(ts, peer1, "peers", "permissions", "--pid", peer2.PeerID())
+		require.NoError(t, err)
+		require.Contains(t, out, "family")
+
+		groups, err := peer1.api.PeerGroups()
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		require.Equal(t, []string{peer2.PeerID()}, groups[0].Members)
+		out, err = runCLI(ts, peer1, "peers", "groups", "list")
+		require.NoError(t, err)
+		require.Contains(t, out, "family")
+
+		out, err = runCLI(ts, peer1, "peers", "groups", "remove", "--name", "family")
+		require.NoError(t, err)
+		require.Equal(t, "peer group removed successfully\n", out)
+		pcfg, err = peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Empty(t, pcfg.Groups)
+	})
+
+	t.Run("Access", func(t *testing.T) {
+		_, err := runCLI(ts, peer1, "peers", "set_access", "--pid", peer2.PeerID())
+		require.Error(t, err)
+		_, err = runCLI(ts, peer1, "peers", "set_access", "--pid", peer2.PeerID(), "--schedule", "weekend 10:00-12:00")
+		require.Error(t, err)
+
+		out, err := runCLI(ts, peer1, "peers", "set_access", "--pid", peer2.PeerID(), "--exit_node",
+			"--expires_in", "48h", "--schedule", "sat sun 22:00-02:00")
+		require.NoError(t, err)
+		require.Equal(t, "peer access updated successfully\n", out)
+		pcfg, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Equal(t, []config.AccessWindow{{Days: []string{"sat", "sun"}, Start: "22:00", End: "02:00"}}, pcfg.ExitNodeAccess.Schedule)
+		require.WithinDuration(t, time.Now().Add(48*time.Hour), pcfg.ExitNodeAccess.ExpiresAt, time.Minute)
+		require.Zero(t, pcfg.Access)
+
+		out, err = runCLI(ts, peer1, "peers", "set_access", "--pid", peer2.PeerID(), "--exit_node", "--clear")
+		require.NoError(t, err)
+		require.Equal(t, "peer access updated successfully\n", out)
+		pcfg, err = peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Zero(t, pcfg.ExitNodeAccess)
+	})
+
+	t.Run("Presence", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "peers", "notify", "--pid", peer2.PeerID(), "--online")
+		require.NoError(t, err)
+		require.Equal(t, "peer presence notifications updated successfully\n", out)
+		pcfg, err := peer1.api.KnownPeerConfig(peer2.PeerID())
+		require.NoError(t, err)
+		require.Equal(t, config.PresenceNotify{Online: true}, pcfg.PresenceNotify)
+
+		out, err = runCLI(ts, peer1, "peers", "presence", "--pid", peer2.PeerID())
+		require.NoError(t, err)
+		require.Contains(t, out, "is online")
+		require.Contains(t, out, "Uptime: ")
+		require.Contains(t, out, "ADDRESS FAMILY")
+	})
+}
+
+func TestCLI_PeersSpeedtest(t *testing.T) {
+	ts := NewTestSuite(t)
+	peer1 := ts.NewTestPeer(false)
+	peer2 := ts.NewTestPeer(false)
+	ts.makeFriends(peer1, peer2)
+
+	out, err := runCLI(ts, peer1, "peers", "speedtest", "--pid", peer2.PeerID(), "--direction", "bidirectional", "--duration", "1s")
+	require.NoError(t, err)
+	require.Contains(t, out, "Upload")
+	require.Contains(t, out, "Download")
+	require.Contains(t, out, "Jitter")
+	require.Contains(t, out, "Loss")
 }
 
 // TestCLI_PeersRemove covers remove by peer ID and by alias.
@@ -454,6 +692,49 @@ func TestCLI_Gateway(t *testing.T) {
 	})
 }
 
+func TestCLI_PrivateNetwork(t *testing.T) {
+	ts := NewTestSuite(t)
+	peer1 := ts.NewTestPeer(false)
+
+	t.Run("GenerateKey", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "private_network", "generate_key")
+		require.NoError(t, err)
+		require.Len(t, strings.TrimSpace(out), 2*config.PrivateNetworkKeyLen)
+	})
+
+	t.Run("Enable", func(t *testing.T) {
+		key := strings.Repeat("ab", config.PrivateNetworkKeyLen)
+		out, err := runCLI(ts, peer1, "private_network", "enable", "--key", key, "--dht_prefix", "/mynet")
+		require.NoError(t, err)
+		require.Contains(t, out, "Private network enabled, restart awl to apply changes")
+		require.Contains(t, out, key)
+
+		out, err = runCLI(ts, peer1, "private_network", "status")
+		require.NoError(t, err)
+		require.Contains(t, out, "Private network enabled: true")
+		require.Contains(t, out, "Private network active:  false")
+		require.Contains(t, out, "DHT protocol prefix:     /mynet")
+		// bootstrap peers are kept
+		require.Contains(t, out, ts.bootstrapAddrsStr[0])
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		_, err := runCLI(ts, peer1, "private_network", "enable", "--key", "abcd")
+		require.Error(t, err)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		out, err := runCLI(ts, peer1, "private_network", "disable")
+		require.NoError(t, err)
+		require.Equal(t, "Private network disabled, restart awl to apply changes\n", out)
+
+		info, err := peer1.api.PrivateNetwork()
+		require.NoError(t, err)
+		require.False(t, info.Enabled)
+		require.Empty(t, info.DHTProtocolPrefix)
+	})
+}
+
 // TestCLI_ConnectionFailure verifies an error is returned when the daemon is unreachable.
 func TestCLI_ConnectionFailure(t *testing.T) {
 	_, err := runCLIAddr("127.0.0.1:1", "me", "status")
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+
 	"github.com/ncruces/zenity"
 )
 
@@ -51,3 +53,17 @@ func showQuestionDialog(title, message, okLabel string) bool {
 	}
 	return true
 }
+
+// showPassphraseDialog asks for the passphrase of config encrypted by passphrase provider.
+func showPassphraseDialog() (string, error) {
+	uid, hasUID := getRealUserID()
+	opts := []zenity.Option{zenity.Title("Anywherelan"), zenity.HideText(), zenity.Width(250)}
+	if hasUID {
+		opts = append(opts, zenity.UnixUID(uid))
+	}
+	passphrase, err := zenity.Entry("Enter config passphrase:", opts...)
+	if err == zenity.ErrCanceled {
+		return "", errors.New("canceled by user")
+	}
+	return passphrase, err
+}
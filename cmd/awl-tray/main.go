@@ -28,8 +28,9 @@ import (
 const appType = config.AppTypeAwlTray
 
 var (
-	app    *awl.Application
-	logger *log.ZapEventLogger
+	app *awl.Application
+	// logger is replaced by app logger on server start
+	logger = log.Logger("awl/tray")
 )
 
 func getConfig() (*config.Config, error) {
@@ -66,7 +67,7 @@ func onReady() {
 		logger.Errorf("init awl tray: load config %v", err)
 		return
 	}
-	if conf.Update.TrayAutoCheckEnabled {
+	if conf.Update.TrayAutoCheckEnabled && !conf.OfflineMode {
 		go func() {
 			if config.IsDevVersion() {
 				logger.Info("updates auto check is disabled for dev version")
@@ -103,10 +104,21 @@ func InitServer() (err error) {
 			app = nil
 		}
 	}()
+	// passphrase is asked only on server start, config is read without it when server is stopped
+	config.PassphrasePrompt = showPassphraseDialog
+	defer func() {
+		config.PassphrasePrompt = nil
+	}()
+
 	app = awl.New()
 	// TODO: setup logger in main(), before systray and others
 	//  now we can have panics because of this
-	logger = app.SetupLoggerAndConfig(appType)
+	logger, err = app.SetupLoggerAndConfig(appType)
+	if err != nil {
+		// app isn't closed, closing saves config and would overwrite the encrypted one
+		app = nil
+		return fmt.Errorf("failed to start server: %v", err)
+	}
 
 	err = app.Init(context.Background(), nil)
 	if err != nil {
@@ -169,6 +181,20 @@ func subscribeToNotifications(app *awl.Application) {
 			logger.Errorf("show notification: incoming friend request: %v", notifyErr)
 		}
 	}, app.Eventbus, new(awlevent.ReceivedAuthRequest))
+
+	awlevent.WrapSubscriptionToCallback(app.Ctx(), func(evt interface{}) {
+		presence := evt.(awlevent.PeerPresenceChanged)
+		title := fmt.Sprintf("Anywherelan: %s is offline", presence.DisplayName)
+		message := "Disconnected at " + presence.Time.Format(time.TimeOnly)
+		if presence.Online {
+			title = fmt.Sprintf("Anywherelan: %s is online", presence.DisplayName)
+			message = fmt.Sprintf("Connected at %s via %s connection", presence.Time.Format(time.TimeOnly), presence.Connection)
+		}
+		notifyErr := beeep.Notify(title, message, embeds.GetIconPath())
+		if notifyErr != nil {
+			logger.Errorf("show notification: peer presence: %v", notifyErr)
+		}
+	}, app.Eventbus, new(awlevent.PeerPresenceChanged))
 }
 
 func openWebGUI(a *awl.Application) error {
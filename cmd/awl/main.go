@@ -32,17 +32,21 @@ func main() {
 		}
 	}
 
+	config.PassphrasePrompt = config.TerminalPassphrasePrompt
 	app := awl.New()
-	logger := app.SetupLoggerAndConfig(appType)
+	logger, err := app.SetupLoggerAndConfig(appType)
+	if err != nil {
+		logger.Fatal(err)
+	}
 	ctx, ctxCancel := context.WithCancel(context.Background())
 
-	err := app.Init(ctx, nil)
+	err = app.Init(ctx, nil)
 	if err != nil {
 		logger.Fatalf("failed to init server: %v", err)
 	}
 	app.Api.SetupFrontend(awl.FrontendStatic())
 
-	if app.Conf.Update.TrayAutoCheckEnabled {
+	if app.Conf.Update.TrayAutoCheckEnabled && !app.Conf.OfflineMode {
 		go func() {
 			if config.IsDevVersion() {
 				logger.Info("updates auto check is disabled for dev version")
@@ -4,8 +4,11 @@ package anywherelan
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"golang.zx2c4.com/wireguard/tun"
@@ -34,18 +37,30 @@ func Setup(dataDir string) {
 	_ = os.Setenv(config.AppDataDirEnvKey, dataDir)
 }
 
-func GetConfig() string {
+// GetConfig returns exported config or a new one if config file doesn't exist.
+// Config with locked secrets is returned as is, so importing it back doesn't overwrite the identity.
+func GetConfig() (string, error) {
 	if globalDataDir == "" {
 		panic("call to GetConfig before Setup")
 	}
 
-	conf, loadConfigErr := config.LoadConfig(appType, eventbus.NewBus())
-	if loadConfigErr != nil {
-		conf = config.NewConfig(appType, eventbus.NewBus())
+	rawData, err := os.ReadFile(filepath.Join(config.CalcAppDataDir(), config.AppConfigFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		conf := config.NewConfig(appType, eventbus.NewBus())
+		return string(conf.Export()), nil
+	} else if err != nil {
+		return "", err
+	}
+
+	conf, err := config.LoadConfig(appType, eventbus.NewBus())
+	if errors.Is(err, config.ErrSecretsLocked) {
+		return string(rawData), nil
+	} else if err != nil {
+		return "", err
 	}
 
 	data := conf.Export()
-	return string(data)
+	return string(data), nil
 }
 
 // SocketProtector is the interface that the Android host app must implement
@@ -79,7 +94,11 @@ func StartServer(tunFD int32, protector SocketProtector) (err error) {
 	}()
 
 	globalApp = awl.New()
-	globalApp.SetupLoggerAndConfig(appType)
+	_, err = globalApp.SetupLoggerAndConfig(appType)
+	if err != nil {
+		globalApp = nil
+		return err
+	}
 	globalApp.SockMarker = sockmark.NewAndroid(protectorToFunc(protector))
 
 	// A tunFD of 0 means the host did not establish a VPN interface (VPN
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+const (
+	AuthRuleActionAccept = "accept"
+	AuthRuleActionQueue  = "queue"
+	AuthRuleActionReject = "reject"
+)
+
+// ValidateAuthRule checks rule action, name pattern syntax and time window.
+func ValidateAuthRule(rule AuthRule) error {
+	switch rule.Action {
+	case AuthRuleActionAccept, AuthRuleActionQueue, AuthRuleActionReject:
+	default:
+		return fmt.Errorf("rule %q: unknown action %q", rule.Name, rule.Action)
+	}
+	if _, err := path.Match(rule.NamePattern, ""); err != nil {
+		return fmt.Errorf("rule %q: invalid name pattern: %v", rule.Name, err)
+	}
+	if !rule.ActiveFrom.IsZero() && !rule.ActiveUntil.IsZero() && !rule.ActiveFrom.Before(rule.ActiveUntil) {
+		return fmt.Errorf("rule %q: activeFrom should be before activeUntil", rule.Name)
+	}
+	return nil
+}
+
+// Active reports whether now is within rule time window.
+func (r AuthRule) Active(now time.Time) bool {
+	if !r.ActiveFrom.IsZero() && now.Before(r.ActiveFrom) {
+		return false
+	}
+	if !r.ActiveUntil.IsZero() && !now.Before(r.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+// MatchName reports whether peer provided name matches NamePattern, empty pattern matches any name.
+func (r AuthRule) MatchName(name string) bool {
+	if r.NamePattern == "" {
+		return true
+	}
+	matched, _ := path.Match(r.NamePattern, name)
+	return matched
+}
+
+func (c *Config) GetAuthRequestLimits() AuthRequestLimitsConfig {
+	c.RLock()
+	defer c.RUnlock()
+	return c.P2pNode.AuthRequestLimits
+}
+
+func (c *Config) GetAuthRules() []AuthRule {
+	c.RLock()
+	defer c.RUnlock()
+	return append([]AuthRule(nil), c.P2pNode.AuthRules...)
+}
+
+func (c *Config) SetAuthRules(rules []AuthRule) error {
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if err := ValidateAuthRule(rule); err != nil {
+			return err
+		}
+		if rule.Name == "" {
+			return errors.New("rule name is required")
+		}
+		if _, exists := names[rule.Name]; exists {
+			return fmt.Errorf("rule %q: name is not unique", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+	}
+
+	c.Lock()
+	c.P2pNode.AuthRules = rules
+	c.save()
+	c.Unlock()
+	return nil
+}
@@ -1,7 +1,19 @@
 package config
 
 import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/mr-tron/base58/base58"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfig_GetBootstrapPeers(t *testing.T) {
@@ -11,3 +23,398 @@ func TestConfig_GetBootstrapPeers(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestConfig_GetBootstrapDNSAddrs(t *testing.T) {
+	cfg := &Config{}
+	cfg.P2pNode.BootstrapPeers = []string{
+		"/dnsaddr/bootstrap.example.com",
+		"/dnsaddr/bootstrap.example.com/p2p/12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn",
+		"/ip4/1.2.3.4/tcp/6150/p2p/12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn",
+	}
+	ignoreDefaults := true
+	cfg.P2pNode.IgnoreDefaultBootstrapPeers = &ignoreDefaults
+
+	dnsAddrs := cfg.GetBootstrapDNSAddrs()
+	require.Len(t, dnsAddrs, 1)
+	require.Equal(t, "/dnsaddr/bootstrap.example.com", dnsAddrs[0].String())
+	// dnsaddr without peer id doesn't make other bootstrap peers invalid
+	require.Len(t, cfg.GetBootstrapPeers(), 1)
+}
+
+func TestParseStaticAddr(t *testing.T) {
+	peerID := DefaultBootstrapPeers[0]
+	info, err := peer.AddrInfoFromP2pAddr(peerID)
+	require.NoError(t, err)
+
+	maddr, err := ParseStaticAddr("/ip4/192.168.1.10/udp/4363/quic-v1", info.ID)
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/192.168.1.10/udp/4363/quic-v1", maddr.String())
+
+	maddr, err = ParseStaticAddr("/ip4/192.168.1.10/tcp/4363/p2p/"+info.ID.String(), info.ID)
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/192.168.1.10/tcp/4363", maddr.String())
+
+	_, err = ParseStaticAddr("192.168.1.10:4363", info.ID)
+	require.Error(t, err)
+	_, err = ParseStaticAddr("/p2p/"+info.ID.String(), info.ID)
+	require.Error(t, err)
+
+	otherInfo, err := peer.AddrInfoFromP2pAddr(DefaultBootstrapPeers[1])
+	require.NoError(t, err)
+	_, err = ParseStaticAddr("/ip4/192.168.1.10/tcp/4363/p2p/"+otherInfo.ID.String(), info.ID)
+	require.Error(t, err)
+}
+
+func TestSetDefaults_ResourceLimits(t *testing.T) {
+	cfg := &Config{}
+	cfg.P2pNode.ResourceLimits.Protocols = map[string]ProtocolLimitsConfig{
+		ProtocolLimitsSOCKS5: {StreamsPerPeer: 16},
+	}
+	cfg.P2pNode.ConnManager.HighWater = 200
+	setDefaults(cfg, eventbus.NewBus())
+
+	require.Equal(t, ProtocolLimitsConfig{Streams: defaultProtocolLimits[ProtocolLimitsSOCKS5].Streams, StreamsPerPeer: 16},
+		cfg.P2pNode.ResourceLimits.Protocols[ProtocolLimitsSOCKS5])
+	require.Equal(t, defaultProtocolLimits[ProtocolLimitsTunnel], cfg.P2pNode.ResourceLimits.Protocols[ProtocolLimitsTunnel])
+	require.Len(t, cfg.P2pNode.ResourceLimits.Protocols, len(defaultProtocolLimits))
+	require.Equal(t, ConnManagerConfig{LowWater: 50, HighWater: 200, GracePeriodSec: 60}, cfg.P2pNode.ConnManager)
+}
+
+func TestSetDefaults_AuthRequestLimits(t *testing.T) {
+	cfg := &Config{}
+	cfg.P2pNode.AuthRequestLimits.RequestsPerMinute = 5
+	cfg.P2pNode.AuthRequestLimits.ProofOfWorkBits = 100
+	setDefaults(cfg, eventbus.NewBus())
+
+	require.Equal(t, AuthRequestLimitsConfig{PeerRequestsPerHour: 10, RequestsPerMinute: 5, MaxPending: 100, ProofOfWorkBits: 24},
+		cfg.GetAuthRequestLimits())
+}
+
+func TestConfig_UseInvite(t *testing.T) {
+	cfg := &Config{dataDir: t.TempDir(), Invites: map[string]Invite{}}
+	now := time.Now()
+	cfg.AddInvite(Invite{ID: "multi", CreatedAt: now, ExpiresAt: now.Add(time.Hour), MaxUses: 2, AllowUsingAsRelay: true})
+	cfg.AddInvite(Invite{ID: "expired", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute), MaxUses: 1})
+	_, err := cfg.UseInvite("expired")
+	require.ErrorContains(t, err, "expired")
+	require.Len(t, cfg.GetInvites(), 1)
+
+	invite, err := cfg.UseInvite("multi")
+	require.NoError(t, err)
+	require.Equal(t, 1, invite.Uses)
+	require.True(t, invite.AllowUsingAsRelay)
+	invite, err = cfg.UseInvite("multi")
+	require.NoError(t, err)
+	require.Equal(t, 2, invite.Uses)
+	_, err = cfg.UseInvite("multi")
+	require.ErrorContains(t, err, "not found")
+	require.Empty(t, cfg.Invites)
+
+	cfg.AddInvite(Invite{ID: "revoked", CreatedAt: now, ExpiresAt: now.Add(time.Hour), MaxUses: 1})
+	require.True(t, cfg.RemoveInvite("revoked"))
+	require.False(t, cfg.RemoveInvite("revoked"))
+	_, err = cfg.UseInvite("revoked")
+	require.ErrorContains(t, err, "not found")
+}
+
+func TestConfig_RemoveExpiredInvites(t *testing.T) {
+	dataDir := t.TempDir()
+	cfg := &Config{dataDir: dataDir, Invites: map[string]Invite{}}
+	now := time.Now()
+	cfg.Invites["expired"] = Invite{ID: "expired", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute), MaxUses: 1}
+	cfg.AddInvite(Invite{ID: "active", CreatedAt: now, ExpiresAt: now.Add(time.Hour), MaxUses: 1})
+	require.NotContains(t, cfg.Invites, "expired")
+
+	cfg.Invites["expired"] = Invite{ID: "expired", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute), MaxUses: 1}
+	invites := cfg.GetInvites()
+	require.Len(t, invites, 1)
+	require.Equal(t, "active", invites[0].ID)
+	require.NotContains(t, cfg.Invites, "expired")
+
+	data, err := os.ReadFile(filepath.Join(dataDir, AppConfigFilename))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `"expired"`)
+	require.Contains(t, string(data), `"active"`)
+}
+
+func TestAuthRule(t *testing.T) {
+	now := time.Now()
+	rule := AuthRule{Name: "laptops", NamePattern: "laptop-*", ActiveFrom: now.Add(-time.Hour), ActiveUntil: now.Add(time.Hour), Action: AuthRuleActionAccept}
+	require.NoError(t, ValidateAuthRule(rule))
+	require.True(t, rule.MatchName("laptop-1"))
+	require.False(t, rule.MatchName("phone"))
+	require.True(t, rule.Active(now))
+	require.False(t, rule.Active(now.Add(time.Hour)))
+	require.False(t, rule.Active(now.Add(-2*time.Hour)))
+	require.True(t, AuthRule{}.MatchName("any"))
+	require.True(t, AuthRule{}.Active(now))
+
+	require.ErrorContains(t, ValidateAuthRule(AuthRule{Name: "r", Action: "allow"}), "unknown action")
+	require.ErrorContains(t, ValidateAuthRule(AuthRule{Name: "r", Action: AuthRuleActionQueue, NamePattern: "["}), "invalid name pattern")
+	require.ErrorContains(t, ValidateAuthRule(AuthRule{Name: "r", Action: AuthRuleActionReject, ActiveFrom: now, ActiveUntil: now}), "activeFrom")
+
+	cfg := &Config{dataDir: t.TempDir()}
+	require.NoError(t, cfg.SetAuthRules([]AuthRule{rule}))
+	require.Equal(t, []AuthRule{rule}, cfg.GetAuthRules())
+	require.ErrorContains(t, cfg.SetAuthRules([]AuthRule{rule, rule}), "not unique")
+	require.ErrorContains(t, cfg.SetAuthRules([]AuthRule{{Action: AuthRuleActionAccept}}), "name is required")
+}
+
+func TestConfig_PeerGroups(t *testing.T) {
+	cfg := &Config{dataDir: t.TempDir()}
+	setDefaults(cfg, eventbus.NewBus())
+	peerID := "12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn"
+	cfg.KnownPeers[peerID] = KnownPeer{PeerID: peerID, IPAddr: "10.66.0.2", DomainName: "laptop", WeAllowUsingAsRelay: true}
+
+	require.Error(t, cfg.UpsertPeerGroup(PeerGroup{Name: "bad name"}))
+	require.NoError(t, cfg.UpsertPeerGroup(PeerGroup{Name: "family", AllowUsingAsExitNode: true}))
+	require.NoError(t, cfg.UpsertPeerGroup(PeerGroup{Name: "hidden", HideFromDNS: true}))
+
+	_, err := cfg.ValidPeerGroupsUnlocked([]string{"family", "unknown"})
+	require.ErrorContains(t, err, "not found")
+	groups, err := cfg.ValidPeerGroupsUnlocked([]string{"hidden", "family", "hidden"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"family", "hidden"}, groups)
+
+	perms, _ := cfg.PeerPermissions(peerID)
+	require.Equal(t, PeerPermissions{AllowUsingAsRelay: true, VisibleInDNS: true}, perms)
+	require.Contains(t, cfg.DNSNamesMapping(), "laptop")
+
+	knownPeer := cfg.KnownPeers[peerID]
+	knownPeer.Groups = groups
+	cfg.KnownPeers[peerID] = knownPeer
+	perms, _ = cfg.PeerPermissions(peerID)
+	require.Equal(t, PeerPermissions{AllowUsingAsExitNode: true, AllowUsingAsRelay: true}, perms)
+	require.NotContains(t, cfg.DNSNamesMapping(), "laptop")
+	require.Equal(t, []string{peerID}, cfg.GroupMembers("family"))
+
+	require.True(t, cfg.RemovePeerGroup("hidden"))
+	require.False(t, cfg.RemovePeerGroup("hidden"))
+	require.Equal(t, []string{"family"}, cfg.KnownPeers[peerID].Groups)
+	require.Len(t, cfg.GetPeerGroups(), 1)
+	require.Contains(t, cfg.DNSNamesMapping(), "laptop")
+}
+
+func TestConfig_PeersDomainNames(t *testing.T) {
+	cfg := &Config{dataDir: t.TempDir()}
+	setDefaults(cfg, eventbus.NewBus())
+	peerID1 := "12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn"
+	peerID2 := "12D3KooWKyuKS2MJsUR8u5QXVt4ec3JHGv5yczhFR7zYHfLYTdKW"
+	peerID3 := "12D3KooWLqVDjaVhXzDAaKSs5a8CnnbJAfXgSEoeqRCETxdyWkN1"
+	cfg.KnownPeers[peerID1] = KnownPeer{PeerID: peerID1, IPAddr: "10.66.0.2", DomainName: "laptop",
+		AnnouncedDomainName: "nas", AnnouncedDomainAliases: []string{"files"}}
+	cfg.KnownPeers[peerID2] = KnownPeer{PeerID: peerID2, IPAddr: "10.66.0.3", DomainName: "files",
+		AnnouncedDomainName: "nas", AnnouncedDomainAliases: []string{"laptop"}}
+	cfg.KnownPeers[peerID3] = KnownPeer{PeerID: peerID3, IPAddr: "10.66.0.4", DomainName: "phone"}
+
+	require.Equal(t, map[string][]string{
+		peerID1: {"nas", "files"},
+		peerID2: {"laptop"},
+		peerID3: {"phone"},
+	}, cfg.PeersDomainNames())
+	require.Equal(t, "nas", cfg.KnownPeers[peerID2].PreferredDomainName())
+
+	knownPeer := cfg.KnownPeers[peerID3]
+	knownPeer.DomainName = "nas"
+	knownPeer.DomainNameOverride = true
+	cfg.KnownPeers[peerID3] = knownPeer
+	mapping := cfg.DNSNamesMapping()
+	require.Equal(t, "10.66.0.4", mapping["nas"])
+	require.Equal(t, "10.66.0.2", mapping["files"])
+	require.Equal(t, "10.66.0.3", mapping[peerID2])
+
+	require.NoError(t, ValidateDomainNames("", nil))
+	require.NoError(t, ValidateDomainNames("nas", []string{"files"}))
+	require.ErrorContains(t, ValidateDomainNames("", []string{"files"}), "require domain name")
+	require.ErrorContains(t, ValidateDomainNames("nas", []string{"nas"}), "duplicate")
+	require.ErrorContains(t, ValidateDomainNames("Nas", nil), "invalid domain name")
+	require.ErrorContains(t, ValidateDomainNames(AdminHttpServerDomainName, nil), "invalid domain name")
+}
+
+func TestPeerAccess(t *testing.T) {
+	// 2024-01-06 is saturday
+	at := func(day int, clock string) time.Time {
+		parsed, err := time.Parse("15:04", clock)
+		require.NoError(t, err)
+		return time.Date(2024, 1, day, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	}
+
+	evening := AccessWindow{Days: []string{"sat"}, Start: "18:00", End: "23:00"}
+	require.True(t, evening.Contains(at(6, "18:00")))
+	require.False(t, evening.Contains(at(6, "23:00")))
+	require.False(t, evening.Contains(at(7, "19:00")))
+
+	overnight := AccessWindow{Days: []string{"sat"}, Start: "22:00", End: "02:00"}
+	require.True(t, overnight.Contains(at(6, "23:30")))
+	require.True(t, overnight.Contains(at(7, "01:59")))
+	require.False(t, overnight.Contains(at(7, "22:30")))
+	require.False(t, overnight.Contains(at(6, "01:00")))
+
+	require.True(t, AccessWindow{Start: "00:00", End: "00:00"}.Contains(at(3, "12:00")))
+	require.False(t, AccessWindow{Start: "25:00", End: "00:00"}.Contains(at(3, "12:00")))
+
+	access := PeerAccess{ExpiresAt: at(7, "12:00"), Schedule: []AccessWindow{evening, overnight}}
+	require.True(t, access.Allowed(at(6, "20:00")))
+	require.True(t, access.Allowed(at(7, "01:00")))
+	require.False(t, access.Allowed(at(6, "12:00")))
+	require.False(t, access.Expired(at(7, "11:59")))
+	require.True(t, access.Expired(at(7, "12:00")))
+	require.True(t, PeerAccess{}.Allowed(at(3, "12:00")))
+
+	require.NoError(t, ValidatePeerAccess(access))
+	require.Error(t, ValidatePeerAccess(PeerAccess{OnExpire: "delete"}))
+	require.Error(t, ValidatePeerAccess(PeerAccess{Schedule: []AccessWindow{{Start: "8:00pm", End: "23:00"}}}))
+	require.Error(t, ValidatePeerAccess(PeerAccess{Schedule: []AccessWindow{{Days: []string{"monday"}, Start: "08:00", End: "23:00"}}}))
+	require.NoError(t, ValidateExitNodeAccess(PeerAccess{OnExpire: AccessOnExpireSuspend}))
+	require.Error(t, ValidateExitNodeAccess(PeerAccess{OnExpire: AccessOnExpireBlock}))
+
+	cfg := &Config{dataDir: t.TempDir()}
+	setDefaults(cfg, eventbus.NewBus())
+	knownPeer := KnownPeer{WeAllowUsingAsExitNode: true, WeAllowUsingAsRelay: true, ExitNodeAccess: PeerAccess{Schedule: []AccessWindow{evening}}}
+	require.Equal(t, PeerPermissions{AllowUsingAsExitNode: true, AllowUsingAsRelay: true, VisibleInDNS: true},
+		cfg.peerPermissionsAt(knownPeer, at(6, "20:00")))
+	require.Equal(t, PeerPermissions{AllowUsingAsRelay: true, VisibleInDNS: true}, cfg.peerPermissionsAt(knownPeer, at(6, "12:00")))
+
+	knownPeer.Access = PeerAccess{ExpiresAt: at(6, "21:00")}
+	require.Equal(t, PeerPermissions{Suspended: true}, cfg.peerPermissionsAt(knownPeer, at(6, "22:00")))
+}
+
+func TestConfig_MigratePeer(t *testing.T) {
+	cfg := &Config{dataDir: t.TempDir()}
+	setDefaults(cfg, eventbus.NewBus())
+	oldID := "12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn"
+	newID := "12D3KooWLfCTdaVBMCVr2zMa2zShqJqNSxKTgpHZqaNTrAQFfMDL"
+	otherID := "12D3KooWPYBbafMHNRBZkNUUGWDYiP2EwgqYMXeNwtmAGWGQ1yK7"
+	cfg.KnownPeers[oldID] = KnownPeer{PeerID: oldID, Alias: "laptop", IPAddr: "10.66.0.2", DomainName: "laptop",
+		WeAllowUsingAsExitNode: true, Groups: []string{"family"}, Vouch: "vouch"}
+	cfg.KnownPeers[otherID] = KnownPeer{PeerID: otherID}
+	cfg.SOCKS5.UsingPeerID = oldID
+
+	_, err := cfg.MigratePeer(oldID, otherID)
+	require.ErrorContains(t, err, "already known")
+	cfg.BlockedPeers[newID] = BlockedPeer{PeerID: newID}
+	_, err = cfg.MigratePeer(oldID, newID)
+	require.ErrorContains(t, err, "blocked")
+	delete(cfg.BlockedPeers, newID)
+
+	knownPeer, err := cfg.MigratePeer(oldID, newID)
+	require.NoError(t, err)
+	require.Equal(t, KnownPeer{PeerID: newID, Alias: "laptop", IPAddr: "10.66.0.2", DomainName: "laptop",
+		WeAllowUsingAsExitNode: true, Groups: []string{"family"}}, knownPeer)
+	require.Equal(t, knownPeer, cfg.KnownPeers[newID])
+	require.NotContains(t, cfg.KnownPeers, oldID)
+	require.Equal(t, newID, cfg.SOCKS5.UsingPeerID)
+
+	_, err = cfg.MigratePeer(oldID, newID)
+	require.ErrorContains(t, err, "not found")
+}
+
+func TestConfig_KeyRotation(t *testing.T) {
+	cfg := &Config{dataDir: t.TempDir()}
+	setDefaults(cfg, eventbus.NewBus())
+	require.False(t, cfg.ApplyNextIdentity())
+
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	nextKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	keyBytes, err := key.Raw()
+	require.NoError(t, err)
+	nextKeyBytes, err := nextKey.Raw()
+	require.NoError(t, err)
+	cfg.P2pNode.Identity = base58.Encode(keyBytes)
+	require.Equal(t, keyBytes, cfg.LatestPrivKey())
+
+	for i := range maxKeyRotations + 2 {
+		require.NoError(t, cfg.AddKeyRotation(nextKey, strconv.Itoa(i)))
+	}
+	require.Len(t, cfg.GetKeyRotations(), maxKeyRotations)
+	require.Equal(t, strconv.Itoa(maxKeyRotations+1), cfg.GetKeyRotations()[maxKeyRotations-1])
+	require.Equal(t, keyBytes, cfg.PrivKey())
+	require.Equal(t, nextKeyBytes, cfg.LatestPrivKey())
+
+	require.True(t, cfg.ApplyNextIdentity())
+	require.Equal(t, nextKeyBytes, cfg.PrivKey())
+	require.Empty(t, cfg.P2pNode.NextIdentity)
+}
+
+func TestConfig_SecretsEncryption(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(AppDataDirEnvKey, dataDir)
+	cfg := NewConfig(AppTypeAwl, eventbus.NewBus())
+	cfg.P2pNode.Identity = "identity-secret"
+	cfg.HttpBasicAuth.Password = "http-secret"
+	cfg.SOCKS5.Password = "socks5-secret"
+
+	readConfigFile := func() string {
+		data, err := os.ReadFile(filepath.Join(dataDir, AppConfigFilename))
+		require.NoError(t, err)
+		return string(data)
+	}
+	requireEncrypted := func(data string) {
+		for _, secret := range []string{"identity-secret", "http-secret", "socks5-secret"} {
+			require.NotContains(t, data, secret)
+		}
+		require.Contains(t, data, encryptedSecretPrefix)
+	}
+	requireLoaded := func() {
+		loaded, err := LoadConfig(AppTypeAwl, eventbus.NewBus())
+		require.NoError(t, err)
+		require.Equal(t, "identity-secret", loaded.P2pNode.Identity)
+		require.Equal(t, "http-secret", loaded.HttpBasicAuth.Password)
+		require.Equal(t, "socks5-secret", loaded.SOCKS5.Password)
+		require.Empty(t, loaded.P2pNode.NextIdentity)
+	}
+
+	require.ErrorContains(t, cfg.SetSecretsEncryption(SecretsProviderPassphrase, "", ""), "passphrase is required")
+	require.ErrorContains(t, cfg.SetSecretsEncryption("unknown", "", ""), "unknown secrets provider")
+
+	require.NoError(t, cfg.SetSecretsEncryption(SecretsProviderPassphrase, "passphrase", ""))
+	requireEncrypted(readConfigFile())
+	require.True(t, cfg.CheckPassphrase("passphrase"))
+	require.False(t, cfg.CheckPassphrase("wrong"))
+	_, err := LoadConfig(AppTypeAwl, eventbus.NewBus())
+	require.ErrorIs(t, err, ErrSecretsLocked)
+	t.Setenv(PassphraseEnvKey, "wrong")
+	_, err = LoadConfig(AppTypeAwl, eventbus.NewBus())
+	require.ErrorIs(t, err, ErrSecretsLocked)
+	t.Setenv(PassphraseEnvKey, "passphrase")
+	requireLoaded()
+
+	exported := cfg.Export()
+	requireEncrypted(string(exported))
+	require.NoError(t, ImportConfig(exported, t.TempDir()))
+	broken := strings.Replace(string(exported), encryptedSecretPrefix, encryptedSecretPrefix+"!", 1)
+	require.ErrorContains(t, ImportConfig([]byte(broken), t.TempDir()), "invalid secrets")
+
+	keyFile := filepath.Join(t.TempDir(), "awl.key")
+	require.NoError(t, cfg.SetSecretsEncryption(SecretsProviderKeyFile, "", keyFile))
+	require.False(t, cfg.CheckPassphrase("passphrase"))
+	requireEncrypted(readConfigFile())
+	requireLoaded()
+	require.NoError(t, os.Remove(keyFile))
+	_, err = LoadConfig(AppTypeAwl, eventbus.NewBus())
+	require.ErrorIs(t, err, ErrSecretsLocked)
+
+	require.NoError(t, cfg.SetSecretsEncryption("", "", ""))
+	data := readConfigFile()
+	require.NotContains(t, data, encryptedSecretPrefix)
+	require.Contains(t, data, "identity-secret")
+	requireLoaded()
+}
+
+func TestConfig_SecretsEncryptionAndroid(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(AppDataDirEnvKey, dataDir)
+	cfg := NewConfig(AppTypeAwlAndroid, eventbus.NewBus())
+	cfg.P2pNode.Identity = "identity-secret"
+
+	require.ErrorIs(t, cfg.SetSecretsEncryption(SecretsProviderPassphrase, "passphrase", ""), ErrSecretsProviderUnsupported)
+	require.ErrorIs(t, cfg.SetSecretsEncryption(SecretsProviderKeyring, "", ""), ErrSecretsProviderUnsupported)
+	require.Empty(t, cfg.SecretsProvider())
+
+	require.NoError(t, cfg.SetSecretsEncryption(SecretsProviderKeyFile, "", filepath.Join(dataDir, "awl.key")))
+	loaded, err := LoadConfig(AppTypeAwlAndroid, eventbus.NewBus())
+	require.NoError(t, err)
+	require.Equal(t, "identity-secret", loaded.P2pNode.Identity)
+}
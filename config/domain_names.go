@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/anywherelan/awl/awldns"
+	"github.com/anywherelan/awl/protocol"
+)
+
+// Ranks of domain names claimed by known peers. When several peers claim the same name,
+// it resolves to the peer with the lowest rank and then with the lowest peer ID.
+const (
+	// domainNameRankLocal is KnownPeer.DomainName of peers without announced names or overridden by user
+	domainNameRankLocal = iota
+	domainNameRankAnnounced
+	domainNameRankAnnouncedAlias
+	// domainNameRankFallback is KnownPeer.DomainName of peers with announced names, it keeps old names working
+	domainNameRankFallback
+)
+
+type domainNameClaim struct {
+	name string
+	rank int
+}
+
+// IsAnnounceableDomainName reports whether peers can announce the domain name.
+func IsAnnounceableDomainName(domain string) bool {
+	return domain != "" && domain != AdminHttpServerDomainName && awldns.IsValidDomainName(domain)
+}
+
+// ValidateDomainNames checks our domain names announced to known peers, empty name disables announcing.
+func ValidateDomainNames(name string, aliases []string) error {
+	if name == "" {
+		if len(aliases) > 0 {
+			return fmt.Errorf("domain aliases require domain name")
+		}
+		return nil
+	}
+	if len(aliases) > protocol.MaxDomainAliases {
+		return fmt.Errorf("too many domain aliases: %d, max %d", len(aliases), protocol.MaxDomainAliases)
+	}
+	names := append([]string{name}, aliases...)
+	for i, domain := range names {
+		if !IsAnnounceableDomainName(domain) {
+			return fmt.Errorf("invalid domain name %q", domain)
+		}
+		if slices.Contains(names[:i], domain) {
+			return fmt.Errorf("duplicate domain name %q", domain)
+		}
+	}
+	return nil
+}
+
+// PreferredDomainName returns the peer's announced domain name unless user has overridden it.
+func (kp KnownPeer) PreferredDomainName() string {
+	if kp.AnnouncedDomainName != "" && !kp.DomainNameOverride {
+		return kp.AnnouncedDomainName
+	}
+	return kp.DomainName
+}
+
+func (kp KnownPeer) domainNameClaims() []domainNameClaim {
+	if kp.AnnouncedDomainName == "" || kp.DomainNameOverride {
+		if kp.DomainName == "" {
+			return nil
+		}
+		return []domainNameClaim{{name: kp.DomainName, rank: domainNameRankLocal}}
+	}
+
+	claims := make([]domainNameClaim, 0, len(kp.AnnouncedDomainAliases)+2)
+	claims = append(claims, domainNameClaim{name: kp.AnnouncedDomainName, rank: domainNameRankAnnounced})
+	for _, alias := range kp.AnnouncedDomainAliases {
+		claims = append(claims, domainNameClaim{name: alias, rank: domainNameRankAnnouncedAlias})
+	}
+	if kp.DomainName != "" {
+		claims = append(claims, domainNameClaim{name: kp.DomainName, rank: domainNameRankFallback})
+	}
+	return claims
+}
+
+// PeersDomainNames returns domain names which resolve to known peers visible in DNS, keyed by peer ID.
+// Peers which lost all their names in collisions have empty lists.
+func (c *Config) PeersDomainNames() map[string][]string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.peersDomainNamesUnlocked()
+}
+
+func (c *Config) peersDomainNamesUnlocked() map[string][]string {
+	type owner struct {
+		peerID string
+		rank   int
+	}
+	owners := make(map[string]owner)
+	claims := make(map[string][]domainNameClaim, len(c.KnownPeers))
+	for peerID, knownPeer := range c.KnownPeers {
+		if !c.PeerPermissionsUnlocked(knownPeer).VisibleInDNS {
+			continue
+		}
+		claims[peerID] = knownPeer.domainNameClaims()
+		for _, claim := range claims[peerID] {
+			current, exists := owners[claim.name]
+			if !exists || claim.rank < current.rank || claim.rank == current.rank && peerID < current.peerID {
+				owners[claim.name] = owner{peerID: peerID, rank: claim.rank}
+			}
+		}
+	}
+
+	result := make(map[string][]string, len(claims))
+	for peerID, peerClaims := range claims {
+		names := make([]string, 0, len(peerClaims))
+		for _, claim := range peerClaims {
+			if owners[claim.name].peerID == peerID && !slices.Contains(names, claim.name) {
+				names = append(names, claim.name)
+			}
+		}
+		result[peerID] = names
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/mr-tron/base58/base58"
+
+	"github.com/anywherelan/awl/awlevent"
+)
+
+// maxKeyRotations limits the chain of key rotations sent to known peers.
+const maxKeyRotations = 8
+
+// LatestPrivKey returns NextIdentity if key rotation is pending, otherwise Identity.
+func (c *Config) LatestPrivKey() []byte {
+	c.RLock()
+	nextIdentity := c.P2pNode.NextIdentity
+	c.RUnlock()
+	if nextIdentity == "" {
+		return c.PrivKey()
+	}
+
+	b, err := base58.Decode(nextIdentity)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// AddKeyRotation saves the next identity key, it's used after restart, and the rotation announcing it.
+func (c *Config) AddKeyRotation(nextKey crypto.PrivKey, rotation string) error {
+	by, err := nextKey.Raw()
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.P2pNode.NextIdentity = base58.Encode(by)
+	c.P2pNode.KeyRotations = append(c.P2pNode.KeyRotations, rotation)
+	if len(c.P2pNode.KeyRotations) > maxKeyRotations {
+		c.P2pNode.KeyRotations = slices.Delete(c.P2pNode.KeyRotations, 0, len(c.P2pNode.KeyRotations)-maxKeyRotations)
+	}
+	c.save()
+	c.Unlock()
+
+	return nil
+}
+
+func (c *Config) GetKeyRotations() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return slices.Clone(c.P2pNode.KeyRotations)
+}
+
+// ApplyNextIdentity replaces Identity with NextIdentity, it must be called before p2p host is initialized.
+func (c *Config) ApplyNextIdentity() bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.P2pNode.NextIdentity == "" {
+		return false
+	}
+	c.P2pNode.Identity = c.P2pNode.NextIdentity
+	c.P2pNode.NextIdentity = ""
+	c.save()
+
+	return true
+}
+
+// MigratePeer moves the known peer to its new peer ID after key rotation. Alias, IP address, domain name,
+// permissions and groups are kept, the vouch signed by the old peer key is dropped.
+func (c *Config) MigratePeer(oldPeerID, newPeerID string) (KnownPeer, error) {
+	c.Lock()
+	knownPeer, exists := c.KnownPeers[oldPeerID]
+	if !exists {
+		c.Unlock()
+		return KnownPeer{}, fmt.Errorf("peer %s not found", oldPeerID)
+	}
+	if _, exists := c.KnownPeers[newPeerID]; exists {
+		c.Unlock()
+		return KnownPeer{}, fmt.Errorf("peer %s is already known", newPeerID)
+	}
+	if _, blocked := c.BlockedPeers[newPeerID]; blocked {
+		c.Unlock()
+		return KnownPeer{}, fmt.Errorf("peer %s is blocked", newPeerID)
+	}
+
+	delete(c.KnownPeers, oldPeerID)
+	knownPeer.PeerID = newPeerID
+	knownPeer.Vouch = ""
+	c.KnownPeers[newPeerID] = knownPeer
+	if c.SOCKS5.UsingPeerID == oldPeerID {
+		c.SOCKS5.UsingPeerID = newPeerID
+	}
+	if c.VPNGateway.GatewayPeerID == oldPeerID {
+		c.VPNGateway.GatewayPeerID = newPeerID
+	}
+	c.save()
+	c.Unlock()
+
+	_ = c.emitter.Emit(awlevent.KnownPeerChanged{})
+	return knownPeer, nil
+}
@@ -0,0 +1,202 @@
+//go:build linux && !android
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const keyringSupported = true
+
+// Keys are stored in OS keyring via freedesktop Secret Service API, e.g. GNOME Keyring or KWallet.
+const (
+	secretServiceName       = "org.freedesktop.secrets"
+	secretServicePath       = "/org/freedesktop/secrets"
+	secretServiceInterface  = "org.freedesktop.Secret.Service"
+	secretDefaultCollection = "/org/freedesktop/secrets/aliases/default"
+	secretPromptTimeout     = 2 * time.Minute
+)
+
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type secretServiceSession struct {
+	conn    *dbus.Conn
+	service dbus.BusObject
+	path    dbus.ObjectPath
+}
+
+func keyringAttributes(keyID string) map[string]string {
+	return map[string]string{
+		"application": AppDataDirectory,
+		"key_id":      keyID,
+	}
+}
+
+func keyringGet(keyID string) ([]byte, error) {
+	s, err := openSecretServiceSession()
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	item, err := s.findItem(keyID)
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[dbus.ObjectPath]secretServiceSecret
+	err = s.service.Call(secretServiceInterface+".GetSecrets", 0, []dbus.ObjectPath{item}, s.path).Store(&secrets)
+	if err != nil {
+		return nil, fmt.Errorf("get secret: %v", err)
+	}
+	secret, exists := secrets[item]
+	if !exists || len(secret.Value) != secretsKeyLen {
+		return nil, errors.New("invalid secret")
+	}
+	return secret.Value, nil
+}
+
+func keyringSet(keyID string, key []byte) error {
+	s, err := openSecretServiceSession()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	collection := s.conn.Object(secretServiceName, secretDefaultCollection)
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant("Anywherelan config key"),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(keyringAttributes(keyID)),
+	}
+	secret := secretServiceSecret{Session: s.path, Parameters: []byte{}, Value: key, ContentType: "application/octet-stream"}
+	var item, prompt dbus.ObjectPath
+	err = collection.Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, secret, true).Store(&item, &prompt)
+	if err != nil {
+		return fmt.Errorf("create item: %v", err)
+	}
+	_, err = s.runPrompt(prompt)
+	return err
+}
+
+func keyringDelete(keyID string) error {
+	s, err := openSecretServiceSession()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	item, err := s.findItem(keyID)
+	if err != nil {
+		return err
+	}
+	var prompt dbus.ObjectPath
+	err = s.conn.Object(secretServiceName, item).Call("org.freedesktop.Secret.Item.Delete", 0).Store(&prompt)
+	if err != nil {
+		return fmt.Errorf("delete item: %v", err)
+	}
+	_, err = s.runPrompt(prompt)
+	return err
+}
+
+func openSecretServiceSession() (*secretServiceSession, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to session bus: %v", err)
+	}
+	s := &secretServiceSession{
+		conn:    conn,
+		service: conn.Object(secretServiceName, secretServicePath),
+	}
+	var output dbus.Variant
+	err = s.service.Call(secretServiceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &s.path)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("open secret service session: %v", err)
+	}
+	return s, nil
+}
+
+func (s *secretServiceSession) close() {
+	_ = s.conn.Object(secretServiceName, s.path).Call("org.freedesktop.Secret.Session.Close", 0).Err
+	_ = s.conn.Close()
+}
+
+// findItem returns unlocked item with the key, locked keyring is unlocked by prompt.
+func (s *secretServiceSession) findItem(keyID string) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.service.Call(secretServiceInterface+".SearchItems", 0, keyringAttributes(keyID)).Store(&unlocked, &locked)
+	if err != nil {
+		return "", fmt.Errorf("search items: %v", err)
+	}
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+	if len(locked) == 0 {
+		return "", fmt.Errorf("key %s not found", keyID)
+	}
+
+	var prompt dbus.ObjectPath
+	err = s.service.Call(secretServiceInterface+".Unlock", 0, locked[:1]).Store(&unlocked, &prompt)
+	if err != nil {
+		return "", fmt.Errorf("unlock: %v", err)
+	}
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+	result, err := s.runPrompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	paths, ok := result.Value().([]dbus.ObjectPath)
+	if !ok || len(paths) == 0 {
+		return "", errors.New("keyring is locked")
+	}
+	return paths[0], nil
+}
+
+// runPrompt shows prompt of Secret Service, e.g. to unlock keyring, and waits for the user.
+func (s *secretServiceSession) runPrompt(prompt dbus.ObjectPath) (dbus.Variant, error) {
+	if prompt == "/" || prompt == "" {
+		return dbus.Variant{}, nil
+	}
+	err := s.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface("org.freedesktop.Secret.Prompt"),
+		dbus.WithMatchMember("Completed"),
+	)
+	if err != nil {
+		return dbus.Variant{}, err
+	}
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	err = s.conn.Object(secretServiceName, prompt).Call("org.freedesktop.Secret.Prompt.Prompt", 0, "").Err
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("prompt: %v", err)
+	}
+	timeout := time.After(secretPromptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != prompt || len(signal.Body) < 2 {
+				continue
+			}
+			if dismissed, _ := signal.Body[0].(bool); dismissed {
+				return dbus.Variant{}, errors.New("prompt dismissed")
+			}
+			result, _ := signal.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errors.New("prompt timeout")
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux || android
+
+package config
+
+import "errors"
+
+const keyringSupported = false
+
+var errKeyringUnsupported = errors.New("keyring is supported only on linux, use passphrase or keyfile")
+
+func keyringGet(string) ([]byte, error) {
+	return nil, errKeyringUnsupported
+}
+
+func keyringSet(string, []byte) error {
+	return errKeyringUnsupported
+}
+
+func keyringDelete(string) error {
+	return errKeyringUnsupported
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/anywherelan/awl/awldns"
 	"github.com/anywherelan/awl/awlevent"
+	"github.com/anywherelan/awl/protocol"
 )
 
 const (
@@ -118,6 +119,10 @@ func LoadConfig(appType AppType, bus awlevent.Bus) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = conf.unlockSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSecretsLocked, err)
+	}
 	conf.dataDir = dataDir
 	setDefaults(conf, bus)
 	return conf, nil
@@ -129,6 +134,10 @@ func ImportConfig(data []byte, directory string) error {
 	if err != nil {
 		return fmt.Errorf("invalid format: %v", err)
 	}
+	err = conf.validateEncryptedSecrets()
+	if err != nil {
+		return fmt.Errorf("invalid secrets: %v", err)
+	}
 
 	path := filepath.Join(directory, AppConfigFilename)
 	err = os.WriteFile(path, data, filesPerm)
@@ -156,9 +165,57 @@ func setDefaults(conf *Config, bus awlevent.Bus) {
 	if conf.P2pNode.ReconnectionIntervalSec == 0 {
 		conf.P2pNode.ReconnectionIntervalSec = 20
 	}
+	if conf.P2pNode.QualityProbeIntervalSec == 0 {
+		conf.P2pNode.QualityProbeIntervalSec = 30
+	}
 	if conf.P2pNode.ParallelSendingStreamsCount == 0 {
 		conf.P2pNode.ParallelSendingStreamsCount = 1
 	}
+	if conf.P2pNode.ResourceLimits.Protocols == nil {
+		conf.P2pNode.ResourceLimits.Protocols = make(map[string]ProtocolLimitsConfig)
+	}
+	for name, defaultLimits := range defaultProtocolLimits {
+		limits := conf.P2pNode.ResourceLimits.Protocols[name]
+		if limits.Streams == 0 {
+			limits.Streams = defaultLimits.Streams
+		}
+		if limits.StreamsPerPeer == 0 {
+			limits.StreamsPerPeer = defaultLimits.StreamsPerPeer
+		}
+		conf.P2pNode.ResourceLimits.Protocols[name] = limits
+	}
+	if conf.P2pNode.ConnManager.LowWater == 0 {
+		conf.P2pNode.ConnManager.LowWater = 50
+	}
+	if conf.P2pNode.ConnManager.HighWater == 0 {
+		conf.P2pNode.ConnManager.HighWater = 100
+	}
+	if conf.P2pNode.ConnManager.GracePeriodSec == 0 {
+		conf.P2pNode.ConnManager.GracePeriodSec = 60
+	}
+	limits := &conf.P2pNode.AuthRequestLimits
+	if limits.PeerRequestsPerHour == 0 {
+		limits.PeerRequestsPerHour = 10
+	}
+	if limits.RequestsPerMinute == 0 {
+		limits.RequestsPerMinute = 60
+	}
+	if limits.MaxPending <= 0 {
+		limits.MaxPending = 100
+	}
+	if limits.ProofOfWorkBits < 0 || limits.ProofOfWorkBits > protocol.MaxProofOfWorkBits {
+		logger.Warnf("incorrect config: auth request proof of work bits %d, should be from 0 to %d",
+			limits.ProofOfWorkBits, protocol.MaxProofOfWorkBits)
+		limits.ProofOfWorkBits = min(max(limits.ProofOfWorkBits, 0), protocol.MaxProofOfWorkBits)
+	}
+	if conf.P2pNode.AuthRules == nil {
+		conf.P2pNode.AuthRules = make([]AuthRule, 0)
+	}
+	for _, rule := range conf.P2pNode.AuthRules {
+		if err := ValidateAuthRule(rule); err != nil {
+			logger.Warnf("incorrect config: auth %v, the rule is ignored", err)
+		}
+	}
 
 	// Other
 	if conf.LoggerLevel == "" {
@@ -195,6 +252,35 @@ func setDefaults(conf *Config, bus awlevent.Bus) {
 		conf.SOCKS5.ListenAddress = defaultSOCKS5ListenAddress
 	}
 
+	if conf.SpeedTest.MaxDurationSec == 0 {
+		conf.SpeedTest.MaxDurationSec = 30
+	}
+	if conf.RelayService.MaxReservations == 0 {
+		conf.RelayService.MaxReservations = 32
+	}
+	if conf.RelayService.MaxCircuits == 0 {
+		conf.RelayService.MaxCircuits = 16
+	}
+
+	if conf.Presence.RetentionDays <= 0 {
+		conf.Presence.RetentionDays = 30
+	}
+	if conf.Presence.NotifyDelaySec <= 0 {
+		conf.Presence.NotifyDelaySec = 30
+	}
+	if conf.Presence.WebhookURLs == nil {
+		conf.Presence.WebhookURLs = make([]string, 0)
+	}
+	for _, webhookURL := range conf.Presence.WebhookURLs {
+		if err := ValidateWebhookURL(webhookURL); err != nil {
+			logger.Warnf("incorrect config: presence webhook %v", err)
+		}
+	}
+
+	if err := ValidateDomainNames(conf.P2pNode.DomainName, conf.P2pNode.DomainAliases); err != nil {
+		logger.Warnf("incorrect config: announced domain names: %v", err)
+	}
+
 	if conf.DNS.ListenAddress == "" {
 		conf.DNS.ListenAddress = awldns.DefaultDNSAddress
 	}
@@ -225,19 +311,24 @@ func setDefaults(conf *Config, bus awlevent.Bus) {
 	if conf.BlockedPeers == nil {
 		conf.BlockedPeers = make(map[string]BlockedPeer)
 	}
+	if conf.PeerGroups == nil {
+		conf.PeerGroups = make(map[string]PeerGroup)
+	}
+	if conf.Invites == nil {
+		conf.Invites = make(map[string]Invite)
+	}
 
 	if conf.dataDir == "" {
 		conf.dataDir = CalcAppDataDir()
 	}
 
 	// Create dirs
-	// TODO: currently PeerstoreDataDir is not used
-	// peerstoreDir := filepath.Join(conf.dataDir, DhtPeerstoreDataDirectory)
-	// err := os.MkdirAll(peerstoreDir, dirsPerm)
-	// if err != nil {
-	//	logger.Warnf("could not create peerstore directory: %v", err)
-	// }
-	// ChownFileIfNeeded(peerstoreDir)
+	peerstoreDir := filepath.Join(conf.dataDir, DhtPeerstoreDataDirectory)
+	err := os.MkdirAll(peerstoreDir, dirsPerm)
+	if err != nil {
+		logger.Warnf("could not create peerstore directory: %v", err)
+	}
+	ChownFileIfNeeded(peerstoreDir)
 
 	emitter, err := bus.Emitter(new(awlevent.KnownPeerChanged), eventbus.Stateful)
 	if err != nil {
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// AccessOnExpireSuspend keeps expired peer in known peers without any access, it's the default.
+	AccessOnExpireSuspend = "suspend"
+	// AccessOnExpireRemove removes expired peer from known peers, it can send a new auth request.
+	AccessOnExpireRemove = "remove"
+	// AccessOnExpireBlock removes expired peer from known peers and blocks it.
+	AccessOnExpireBlock = "block"
+
+	accessWindowTimeLayout = "15:04"
+)
+
+var accessWindowDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Allowed reports whether the access isn't expired and t is within one of schedule windows.
+// Access without expiry and schedule is always allowed.
+func (a PeerAccess) Allowed(t time.Time) bool {
+	if a.Expired(t) {
+		return false
+	}
+	if len(a.Schedule) == 0 {
+		return true
+	}
+	for _, window := range a.Schedule {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a PeerAccess) Expired(t time.Time) bool {
+	return !a.ExpiresAt.IsZero() && !t.Before(a.ExpiresAt)
+}
+
+// Contains reports whether t in its location is within the window.
+// Invalid windows don't contain anything.
+func (w AccessWindow) Contains(t time.Time) bool {
+	start, err := time.Parse(accessWindowTimeLayout, w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(accessWindowTimeLayout, w.End)
+	if err != nil {
+		return false
+	}
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+	nowMin := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	prevDay := (day + 6) % 7
+
+	switch {
+	case startMin == endMin:
+		return w.hasDay(day)
+	case startMin < endMin:
+		return w.hasDay(day) && nowMin >= startMin && nowMin < endMin
+	default:
+		// window ends next day
+		return w.hasDay(day) && nowMin >= startMin || w.hasDay(prevDay) && nowMin < endMin
+	}
+}
+
+func (w AccessWindow) hasDay(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, accessWindowDays[day])
+}
+
+// ValidatePeerAccess checks schedule windows and expire action.
+func ValidatePeerAccess(access PeerAccess) error {
+	switch access.OnExpire {
+	case "", AccessOnExpireSuspend, AccessOnExpireRemove, AccessOnExpireBlock:
+	default:
+		return fmt.Errorf("unknown expire action %q", access.OnExpire)
+	}
+	for i, window := range access.Schedule {
+		if _, err := time.Parse(accessWindowTimeLayout, window.Start); err != nil {
+			return fmt.Errorf("schedule window %d: invalid start time %q, expected HH:MM", i, window.Start)
+		}
+		if _, err := time.Parse(accessWindowTimeLayout, window.End); err != nil {
+			return fmt.Errorf("schedule window %d: invalid end time %q, expected HH:MM", i, window.End)
+		}
+		for _, day := range window.Days {
+			if !slices.Contains(accessWindowDays, day) {
+				return fmt.Errorf("schedule window %d: unknown day %q, expected one of %v", i, day, accessWindowDays)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateExitNodeAccess is ValidatePeerAccess for exit node grant, it can only be suspended.
+func ValidateExitNodeAccess(access PeerAccess) error {
+	if access.OnExpire != "" && access.OnExpire != AccessOnExpireSuspend {
+		return errors.New("exit node access can only be suspended on expire")
+	}
+	return ValidatePeerAccess(access)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anywherelan/awl/awlevent"
+)
+
+const maxPeerGroupNameLen = 64
+
+// PeerPermissions are effective permissions of the known peer, it's a union of peer and its groups permissions
+// limited by the peer access, see PeerAccess.
+type PeerPermissions struct {
+	AllowUsingAsExitNode bool
+	AllowUsingAsRelay    bool
+	VisibleInDNS         bool
+	// Suspended is true when the peer access is expired or out of schedule, it has no permissions then
+	Suspended bool
+}
+
+// ValidatePeerGroupName allows latin letters, digits, '-' and '_'.
+func ValidatePeerGroupName(name string) error {
+	if name == "" || len(name) > maxPeerGroupNameLen {
+		return fmt.Errorf("group name should be from 1 to %d characters", maxPeerGroupNameLen)
+	}
+	if strings.ContainsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) {
+		return errors.New("group name should contain only latin letters, digits, '-' and '_'")
+	}
+	return nil
+}
+
+// PeerPermissionsUnlocked is PeerPermissions without locking; the caller must hold the lock.
+func (c *Config) PeerPermissionsUnlocked(knownPeer KnownPeer) PeerPermissions {
+	return c.peerPermissionsAt(knownPeer, time.Now())
+}
+
+func (c *Config) peerPermissionsAt(knownPeer KnownPeer, now time.Time) PeerPermissions {
+	if !knownPeer.Access.Allowed(now) {
+		return PeerPermissions{Suspended: true}
+	}
+	perms := PeerPermissions{
+		AllowUsingAsExitNode: knownPeer.WeAllowUsingAsExitNode,
+		AllowUsingAsRelay:    knownPeer.WeAllowUsingAsRelay,
+		VisibleInDNS:         true,
+	}
+	for _, name := range knownPeer.Groups {
+		group, exists := c.PeerGroups[name]
+		if !exists {
+			continue
+		}
+		perms.AllowUsingAsExitNode = perms.AllowUsingAsExitNode || group.AllowUsingAsExitNode
+		perms.AllowUsingAsRelay = perms.AllowUsingAsRelay || group.AllowUsingAsRelay
+		perms.VisibleInDNS = perms.VisibleInDNS && !group.HideFromDNS
+	}
+	if !knownPeer.ExitNodeAccess.Allowed(now) {
+		perms.AllowUsingAsExitNode = false
+	}
+	return perms
+}
+
+// PeerPermissions returns effective permissions of the known peer.
+func (c *Config) PeerPermissions(peerID string) (PeerPermissions, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	knownPeer, exists := c.KnownPeers[peerID]
+	if !exists {
+		return PeerPermissions{}, false
+	}
+	return c.PeerPermissionsUnlocked(knownPeer), true
+}
+
+// GetPeerGroups returns groups sorted by name.
+func (c *Config) GetPeerGroups() []PeerGroup {
+	c.RLock()
+	defer c.RUnlock()
+	groups := slices.Collect(maps.Values(c.PeerGroups))
+	slices.SortFunc(groups, func(a, b PeerGroup) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return groups
+}
+
+// GroupMembers returns IDs of known peers which are members of the group.
+func (c *Config) GroupMembers(name string) []string {
+	c.RLock()
+	defer c.RUnlock()
+	var members []string
+	for peerID, knownPeer := range c.KnownPeers {
+		if slices.Contains(knownPeer.Groups, name) {
+			members = append(members, peerID)
+		}
+	}
+	slices.Sort(members)
+	return members
+}
+
+// UpsertPeerGroup creates the group or updates its permissions.
+func (c *Config) UpsertPeerGroup(group PeerGroup) error {
+	if err := ValidatePeerGroupName(group.Name); err != nil {
+		return err
+	}
+	c.Lock()
+	c.PeerGroups[group.Name] = group
+	c.save()
+	c.Unlock()
+
+	_ = c.emitter.Emit(awlevent.KnownPeerChanged{})
+	return nil
+}
+
+// RemovePeerGroup removes the group and its membership of all known peers.
+func (c *Config) RemovePeerGroup(name string) bool {
+	c.Lock()
+	_, exists := c.PeerGroups[name]
+	if exists {
+		delete(c.PeerGroups, name)
+		for peerID, knownPeer := range c.KnownPeers {
+			if slices.Contains(knownPeer.Groups, name) {
+				knownPeer.Groups = slices.DeleteFunc(slices.Clone(knownPeer.Groups), func(group string) bool { return group == name })
+				c.KnownPeers[peerID] = knownPeer
+			}
+		}
+		c.save()
+	}
+	c.Unlock()
+
+	if exists {
+		_ = c.emitter.Emit(awlevent.KnownPeerChanged{})
+	}
+	return exists
+}
+
+// ValidPeerGroupsUnlocked validates that all groups exist and returns them sorted and without duplicates;
+// the caller must hold the lock.
+func (c *Config) ValidPeerGroupsUnlocked(groups []string) ([]string, error) {
+	result := make([]string, 0, len(groups))
+	for _, name := range groups {
+		if _, exists := c.PeerGroups[name]; !exists {
+			return nil, fmt.Errorf("group %q not found", name)
+		}
+		result = append(result, name)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
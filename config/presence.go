@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// ValidateWebhookURL checks that webhook URL is absolute http or https URL.
+func ValidateWebhookURL(webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", webhookURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("invalid url %q: expected http or https url", webhookURL)
+	}
+	return nil
+}
+
+func (c *Config) GetPresenceConfig() PresenceConfig {
+	c.RLock()
+	defer c.RUnlock()
+	presence := c.Presence
+	presence.WebhookURLs = slices.Clone(presence.WebhookURLs)
+	return presence
+}
+
+// SetPresenceWebhooks replaces webhook URLs which receive presence notifications.
+func (c *Config) SetPresenceWebhooks(webhookURLs []string) error {
+	for _, webhookURL := range webhookURLs {
+		if err := ValidateWebhookURL(webhookURL); err != nil {
+			return err
+		}
+	}
+
+	c.Lock()
+	c.Presence.WebhookURLs = append(make([]string, 0, len(webhookURLs)), webhookURLs...)
+	c.save()
+	c.Unlock()
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+)
+
+const (
+	PrivateNetworkKeyLen = 32
+	// swarmKeyHeader is a header of swarm.key file used by IPFS and other libp2p apps.
+	swarmKeyHeader = "/key/swarm/psk/1.0.0/"
+)
+
+// GeneratePrivateNetworkKey returns new random hex-encoded pre-shared key for private network.
+func GeneratePrivateNetworkKey() (string, error) {
+	key := make([]byte, PrivateNetworkKeyLen)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// ParsePrivateNetworkKey accepts hex-encoded key or swarm.key file content.
+func ParsePrivateNetworkKey(key string) (pnet.PSK, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, swarmKeyHeader) {
+		psk, err := pnet.DecodeV1PSK(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("decode swarm key: %v", err)
+		}
+		return psk, nil
+	}
+
+	psk, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode hex key: %v", err)
+	}
+	if len(psk) != PrivateNetworkKeyLen {
+		return nil, fmt.Errorf("invalid key length: expected %d bytes, got %d", PrivateNetworkKeyLen, len(psk))
+	}
+	return psk, nil
+}
+
+// ValidateDHTProtocolPrefix checks custom DHT protocol prefix, empty prefix means default one.
+func ValidateDHTProtocolPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+		return errors.New("dht protocol prefix should start and not end with '/'")
+	}
+	if strings.ContainsFunc(prefix, func(r rune) bool { return r <= ' ' || r > '~' }) {
+		return errors.New("dht protocol prefix should contain only printable ascii characters")
+	}
+	return nil
+}
+
+// PrivateNetworkKey returns pre-shared key of private network or nil if private network mode is disabled.
+func (c *Config) PrivateNetworkKey() (pnet.PSK, error) {
+	c.RLock()
+	key := c.P2pNode.PrivateNetworkKey
+	c.RUnlock()
+
+	if key == "" {
+		return nil, nil
+	}
+	return ParsePrivateNetworkKey(key)
+}
+
+func (c *Config) IsPrivateNetwork() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.P2pNode.PrivateNetworkKey != ""
+}
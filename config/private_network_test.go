@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrivateNetworkKey(t *testing.T) {
+	key, err := GeneratePrivateNetworkKey()
+	require.NoError(t, err)
+	psk, err := ParsePrivateNetworkKey(key)
+	require.NoError(t, err)
+	require.Equal(t, key, hex.EncodeToString(psk))
+
+	swarmKey := swarmKeyHeader + "\n/base16/\n" + strings.ToUpper(key) + "\n"
+	psk, err = ParsePrivateNetworkKey(swarmKey)
+	require.NoError(t, err)
+	require.Equal(t, key, hex.EncodeToString(psk))
+
+	_, err = ParsePrivateNetworkKey(key[:10])
+	require.Error(t, err)
+	_, err = ParsePrivateNetworkKey("not a hex key")
+	require.Error(t, err)
+}
+
+func TestValidateDHTProtocolPrefix(t *testing.T) {
+	require.NoError(t, ValidateDHTProtocolPrefix(""))
+	require.NoError(t, ValidateDHTProtocolPrefix("/mynet"))
+	require.NoError(t, ValidateDHTProtocolPrefix("/awl/mynet"))
+	require.Error(t, ValidateDHTProtocolPrefix("mynet"))
+	require.Error(t, ValidateDHTProtocolPrefix("/mynet/"))
+	require.Error(t, ValidateDHTProtocolPrefix("/my net"))
+}
+
+func TestConfig_GetBootstrapPeers_PrivateNetwork(t *testing.T) {
+	conf := &Config{}
+	require.NotEmpty(t, conf.GetBootstrapPeers())
+
+	conf.P2pNode.PrivateNetworkKey = strings.Repeat("ab", PrivateNetworkKeyLen)
+	require.Empty(t, conf.GetBootstrapPeers())
+}
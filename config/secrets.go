@@ -0,0 +1,373 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Secrets are encrypted by AES-256-GCM in config file when SecretsConfig.Provider is set.
+// In memory they are stored in plain text, so the rest of the app doesn't know about encryption.
+const (
+	SecretsProviderPassphrase = "passphrase"
+	SecretsProviderKeyring    = "keyring"
+	SecretsProviderKeyFile    = "keyfile"
+
+	// PassphraseEnvKey is used to unlock config encrypted by passphrase without prompt.
+	PassphraseEnvKey = "AWL_PASSPHRASE"
+
+	encryptedSecretPrefix = "enc:v1:"
+	secretsKeyLen         = 32
+	secretsSaltLen        = 16
+	secretsCheckName      = "check"
+	secretsCheckValue     = "anywherelan"
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrSecretsLocked              = errors.New("config secrets are locked")
+	ErrSecretsProviderUnsupported = errors.New("secrets provider is not supported on this platform")
+)
+
+// PassphrasePrompt asks for the passphrase when PassphraseEnvKey is not set.
+// It's nil by default, so CLI commands which read config don't ask for it.
+var PassphrasePrompt func() (string, error)
+
+type secretField struct {
+	name  string
+	value *string
+}
+
+func (c *Config) secretFields() []secretField {
+	return []secretField{
+		{"p2pNode.identity", &c.P2pNode.Identity},
+		{"p2pNode.nextIdentity", &c.P2pNode.NextIdentity},
+		{"p2pNode.privateNetworkKey", &c.P2pNode.PrivateNetworkKey},
+		{"p2pNode.upstreamProxy.url", &c.P2pNode.UpstreamProxy.URL},
+		{"httpBasicAuth.password", &c.HttpBasicAuth.Password},
+		{"socks5.password", &c.SOCKS5.Password},
+	}
+}
+
+// TerminalPassphrasePrompt reads passphrase from terminal without echo.
+func TerminalPassphrasePrompt() (string, error) {
+	fd := int(os.Stdin.Fd()) //nolint:gosec
+	if !term.IsTerminal(fd) {
+		return "", errors.New("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Enter config passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
+
+// SecretsProvider returns provider of the key which encrypts secrets, empty if encryption is disabled.
+func (c *Config) SecretsProvider() string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Secrets.Provider
+}
+
+// CheckPassphrase reports whether passphrase derives the current key.
+func (c *Config) CheckPassphrase(passphrase string) bool {
+	c.RLock()
+	secrets := c.Secrets
+	currentKey := c.secretsKey
+	c.RUnlock()
+	if secrets.Provider != SecretsProviderPassphrase {
+		return false
+	}
+
+	key, err := deriveSecretsKey(passphrase, secrets.Salt)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, currentKey) == 1
+}
+
+// isSecretsProviderSupported reports whether secrets could be unlocked with provider on startup.
+// Android app has no way to ask for passphrase and no OS keyring.
+func (c *Config) isSecretsProviderSupported(provider string) bool {
+	switch provider {
+	case SecretsProviderPassphrase:
+		return c.appType != AppTypeAwlAndroid
+	case SecretsProviderKeyring:
+		return keyringSupported && c.appType != AppTypeAwlAndroid
+	}
+	return true
+}
+
+// SetSecretsEncryption encrypts secrets with a new key from provider or disables encryption when provider is empty.
+// Key of keyring provider is generated and stored in OS keyring, key of keyfile provider is read from keyFile
+// or generated if the file doesn't exist.
+func (c *Config) SetSecretsEncryption(provider, passphrase, keyFile string) error {
+	if !c.isSecretsProviderSupported(provider) {
+		return fmt.Errorf("%w: %q, use keyfile", ErrSecretsProviderUnsupported, provider)
+	}
+	secrets := SecretsConfig{Provider: provider}
+	var key []byte
+	var err error
+	switch provider {
+	case "":
+	case SecretsProviderPassphrase:
+		if passphrase == "" {
+			return errors.New("passphrase is required")
+		}
+		salt := make([]byte, secretsSaltLen)
+		_, _ = rand.Read(salt)
+		secrets.Salt = base64.StdEncoding.EncodeToString(salt)
+		key, err = deriveSecretsKey(passphrase, secrets.Salt)
+		if err != nil {
+			return err
+		}
+	case SecretsProviderKeyring:
+		key = make([]byte, secretsKeyLen)
+		_, _ = rand.Read(key)
+		keyID := make([]byte, 8)
+		_, _ = rand.Read(keyID)
+		secrets.KeyID = hex.EncodeToString(keyID)
+		err = keyringSet(secrets.KeyID, key)
+		if err != nil {
+			return fmt.Errorf("save key to keyring: %v", err)
+		}
+	case SecretsProviderKeyFile:
+		if keyFile == "" {
+			return errors.New("key file is required")
+		}
+		secrets.KeyFile, err = filepath.Abs(keyFile)
+		if err != nil {
+			return err
+		}
+		key, err = readOrCreateKeyFile(secrets.KeyFile)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown secrets provider %q", provider)
+	}
+	if key != nil {
+		secrets.Check, err = encryptSecret(key, secretsCheckName, secretsCheckValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Lock()
+	prevSecrets := c.Secrets
+	c.Secrets = secrets
+	c.secretsKey = key
+	c.save()
+	c.Unlock()
+
+	if prevSecrets.Provider == SecretsProviderKeyring && prevSecrets.KeyID != secrets.KeyID {
+		err = keyringDelete(prevSecrets.KeyID)
+		if err != nil {
+			logger.Warnf("delete previous key from keyring: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// marshalUnlocked returns config file content with encrypted secrets; the caller must hold the lock.
+func (c *Config) marshalUnlocked() ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil || c.secretsKey == nil {
+		return data, err
+	}
+
+	encrypted := new(Config)
+	err = json.Unmarshal(data, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range encrypted.secretFields() {
+		*field.value, err = encryptSecret(c.secretsKey, field.name, *field.value)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %s: %v", field.name, err)
+		}
+	}
+	return json.MarshalIndent(encrypted, "", "  ")
+}
+
+// unlockSecrets decrypts secrets of config loaded from file.
+func (c *Config) unlockSecrets() error {
+	if c.Secrets.Provider == "" {
+		for _, field := range c.secretFields() {
+			if strings.HasPrefix(*field.value, encryptedSecretPrefix) {
+				return fmt.Errorf("%s is encrypted, but secrets provider is not set", field.name)
+			}
+		}
+		return nil
+	}
+
+	key, err := c.loadSecretsKey()
+	if err != nil {
+		return err
+	}
+	check, err := decryptSecret(key, secretsCheckName, c.Secrets.Check)
+	if err != nil || check != secretsCheckValue {
+		return errors.New("invalid key")
+	}
+	for _, field := range c.secretFields() {
+		*field.value, err = decryptSecret(key, field.name, *field.value)
+		if err != nil {
+			return fmt.Errorf("decrypt %s: %v", field.name, err)
+		}
+	}
+	c.secretsKey = key
+
+	return nil
+}
+
+func (c *Config) loadSecretsKey() ([]byte, error) {
+	switch c.Secrets.Provider {
+	case SecretsProviderPassphrase:
+		passphrase, exists := os.LookupEnv(PassphraseEnvKey)
+		if !exists && PassphrasePrompt != nil {
+			var err error
+			passphrase, err = PassphrasePrompt()
+			if err != nil {
+				return nil, fmt.Errorf("read passphrase: %v", err)
+			}
+		} else if !exists {
+			return nil, fmt.Errorf("passphrase is required, set it in %s environment variable", PassphraseEnvKey)
+		}
+		return deriveSecretsKey(passphrase, c.Secrets.Salt)
+	case SecretsProviderKeyring:
+		key, err := keyringGet(c.Secrets.KeyID)
+		if err != nil {
+			return nil, fmt.Errorf("read key from keyring: %v", err)
+		}
+		return key, nil
+	case SecretsProviderKeyFile:
+		return readKeyFile(c.Secrets.KeyFile)
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", c.Secrets.Provider)
+	}
+}
+
+// validateEncryptedSecrets checks format of secrets of imported config, they can't be decrypted without its key.
+func (c *Config) validateEncryptedSecrets() error {
+	switch c.Secrets.Provider {
+	case "":
+		return c.unlockSecrets()
+	case SecretsProviderPassphrase, SecretsProviderKeyring, SecretsProviderKeyFile:
+	default:
+		return fmt.Errorf("unknown secrets provider %q", c.Secrets.Provider)
+	}
+	if c.Secrets.Check == "" {
+		return errors.New("secrets check value is not set")
+	}
+	for _, field := range c.secretFields() {
+		if !strings.HasPrefix(*field.value, encryptedSecretPrefix) {
+			continue
+		}
+		_, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*field.value, encryptedSecretPrefix))
+		if err != nil {
+			return fmt.Errorf("invalid encrypted %s: %v", field.name, err)
+		}
+	}
+	return nil
+}
+
+func deriveSecretsKey(passphrase, salt string) ([]byte, error) {
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil || len(saltBytes) == 0 {
+		return nil, errors.New("invalid salt")
+	}
+	return scrypt.Key([]byte(passphrase), saltBytes, scryptN, scryptR, scryptP, secretsKeyLen)
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %v", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != secretsKeyLen {
+		return nil, fmt.Errorf("invalid key file %s: expected %d hex-encoded bytes", path, secretsKeyLen)
+	}
+	return key, nil
+}
+
+func readOrCreateKeyFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); err == nil {
+		return readKeyFile(path)
+	}
+
+	key := make([]byte, secretsKeyLen)
+	_, _ = rand.Read(key)
+	err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), filesPerm)
+	if err != nil {
+		return nil, fmt.Errorf("save key file: %v", err)
+	}
+	ChownFileIfNeeded(path)
+	return key, nil
+}
+
+// encryptSecret encrypts value, name is used as additional data, so encrypted values can't be swapped.
+func encryptSecret(key []byte, name, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	aead, err := newSecretsAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	_, _ = rand.Read(nonce)
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+
+	return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret decrypts value, values in plain text are returned as is and encrypted on next save.
+func decryptSecret(key []byte, name, value string) (string, error) {
+	encoded, found := strings.CutPrefix(value, encryptedSecretPrefix)
+	if !found {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newSecretsAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newSecretsAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
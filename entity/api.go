@@ -6,6 +6,7 @@ import (
 	kbucket "github.com/libp2p/go-libp2p-kbucket"
 	"github.com/libp2p/go-libp2p/core/metrics"
 
+	"github.com/anywherelan/awl/config"
 	"github.com/anywherelan/awl/p2p"
 	"github.com/anywherelan/awl/protocol"
 )
@@ -29,6 +30,12 @@ type (
 		// optional: specific IP address for the peer
 		IPAddr string `validate:"omitempty,ipv4"`
 	}
+	PurgeAuthRequestsRequest struct {
+		// PeerIDs of pending auth requests to purge, empty value purges all of them
+		PeerIDs []string
+		// Decline blocks senders like declining requests one by one, otherwise they can send requests again
+		Decline bool
+	}
 	PeerIDRequest struct {
 		PeerID string `validate:"required"`
 	}
@@ -39,14 +46,100 @@ type (
 		// TODO: support ipv6
 		IPAddr               string `validate:"required,ipv4"`
 		AllowUsingAsExitNode bool
+		// AllowUsingAsRelay allows the peer to use our relay service. Omitted or null keeps current value.
+		AllowUsingAsRelay *bool
+		// StaticAddrs are multiaddrs of the peer dialed before DHT lookup.
+		// Omitted or null keeps current addresses, empty list removes them.
+		StaticAddrs []string
+		// Groups are names of peer groups, the peer inherits their permissions. Omitted or null keeps current groups.
+		Groups []string
+		// Access limits the time when the peer has access to us. Omitted or null keeps current value, empty object removes limits.
+		Access *config.PeerAccess
+		// ExitNodeAccess limits the time when the peer can use us as exit node. Omitted or null keeps current value.
+		ExitNodeAccess *config.PeerAccess
+		// PresenceNotify enables notifications when the peer goes online or offline. Omitted or null keeps current value.
+		PresenceNotify *config.PresenceNotify
 	}
 	UpdateMySettingsRequest struct {
 		Name string
+		// DeviceInfo is a free-form description of the device sent to known peers. Omitted or null keeps current value.
+		DeviceInfo *string `validate:"omitempty,max=256"`
+		// DomainName is announced to known peers, they resolve us by it. Omitted or null keeps current value, empty value disables announcing.
+		DomainName *string
+		// DomainAliases are additional announced domain names. Omitted or null keeps current value.
+		DomainAliases []string
+	}
+
+	UpdateSecretsEncryptionRequest struct {
+		// Provider of the key which encrypts secrets in config file, empty value disables encryption
+		Provider string `validate:"omitempty,oneof=passphrase keyring keyfile" enums:"passphrase,keyring,keyfile"`
+		// Passphrase is a new passphrase for passphrase provider
+		Passphrase string
+		// CurrentPassphrase is required to change encryption when current provider is passphrase
+		CurrentPassphrase string
+		// KeyFile is a path to file with the key for keyfile provider, it's generated if the file doesn't exist
+		KeyFile string
 	}
 
 	UpdateProxySettingsRequest struct {
 		UsingPeerID string
 	}
+
+	SpeedTestRequest struct {
+		PeerID    string `validate:"required"`
+		Direction string `validate:"required,oneof=upload download bidirectional" enums:"upload,download,bidirectional"`
+		// Test duration in seconds, remote peer may shorten it. Default is 10 seconds.
+		DurationSec int `validate:"omitempty,gte=1,lte=300"`
+	}
+
+	UpdatePrivateNetworkRequest struct {
+		// NetworkKey is hex-encoded key or swarm.key file content, empty key disables private network mode.
+		NetworkKey string
+		// DHTProtocolPrefix separates DHT of private swarm, empty means default prefix.
+		DHTProtocolPrefix string
+		// BootstrapPeers are full multiaddrs of peers in private network or /dnsaddr/ multiaddrs without peer ID.
+		// Omitted or null keeps current peers, empty list removes them.
+		BootstrapPeers []string
+	}
+
+	CreateInviteRequest struct {
+		// Invite lifetime in seconds. Default is 24 hours, max is 30 days.
+		TTLSec int `validate:"omitempty,gte=1,lte=2592000"`
+		// MaxUses is a number of peers which can join with the invite. Default is 1.
+		MaxUses              int `validate:"omitempty,gte=1"`
+		AllowUsingAsExitNode bool
+		AllowUsingAsRelay    bool
+		// WithoutAddrs excludes our addresses from the invite, then the holder finds us only via DHT.
+		WithoutAddrs bool
+	}
+	InviteIDRequest struct {
+		ID string `validate:"required"`
+	}
+	JoinInviteRequest struct {
+		// Invite is awl:// URI or invite token
+		Invite string `validate:"required"`
+		// optional: peer name, the inviting peer name is used by default
+		Alias string
+		// optional: specific IP address for the peer
+		IPAddr string `validate:"omitempty,ipv4"`
+	}
+	UpdatePeerGroupRequest struct {
+		Name                 string `validate:"required"`
+		AllowUsingAsExitNode bool
+		AllowUsingAsRelay    bool
+		HideFromDNS          bool
+	}
+	PeerGroupNameRequest struct {
+		Name string `validate:"required"`
+	}
+	UpdatePresenceWebhooksRequest struct {
+		// WebhookURLs replace all URLs which receive presence notifications
+		WebhookURLs []string
+	}
+	UpdateAuthRulesRequest struct {
+		// Rules replace all auth rules, the first matching rule is applied
+		Rules []config.AuthRule `validate:"required"`
+	}
 )
 
 // Responses
@@ -58,23 +151,41 @@ type (
 		Alias                         string
 		Version                       string
 		IpAddr                        string
-		DomainName                    string
+		DomainName                    string   // announced domain name or local one if user has overridden it
+		DomainNames                   []string // names resolving to the peer, a name claimed by several peers resolves to one of them
+		AnnouncedDomainName           string   // empty for peers which don't announce domain name
+		DomainNameOverride            bool     // user has changed domain name, announced one isn't used
 		Connected                     bool
 		Confirmed                     bool
 		Declined                      bool
 		WeAllowUsingAsExitNode        bool
 		AllowedUsingAsExitNode        bool
 		RemoteVPNGatewayServerEnabled bool
-		LastSeen                      time.Time
-		Connections                   []p2p.ConnectionInfo
-		NetworkStats                  metrics.Stats
-		NetworkStatsInIECUnits        StatsInUnits
-		Ping                          time.Duration `swaggertype:"primitive,integer"`
+		// RemoteRelayServiceEnabled is true when peer runs relay service for us
+		RemoteRelayServiceEnabled bool
+		WeAllowUsingAsRelay       bool
+		AllowedUsingAsRelay       bool
+		Groups                    []string
+		AccessSuspended           bool
+		Capabilities              *protocol.PeerCapabilities
+		Compatibility             string `enums:",compatible,outdated,incompatible"`
+		LastSeen                  time.Time
+		PresenceNotify            config.PresenceNotify
+		Uptime                    PresenceUptime
+		Connections               []p2p.ConnectionInfo
+		NetworkStats              metrics.Stats
+		NetworkStatsInIECUnits    StatsInUnits
+		// RelayedNetworkStats is traffic of relayed connections through the peer or relayed by us for the peer
+		RelayedNetworkStats metrics.Stats
+		Ping                time.Duration `swaggertype:"primitive,integer"`
+		ConnectionQuality   p2p.ConnectionQuality
 	}
 
 	PeerInfo struct {
 		PeerID                  string
 		Name                    string
+		DomainName              string        // announced to known peers
+		DomainAliases           []string      // announced to known peers
 		Uptime                  time.Duration `swaggertype:"primitive,integer"`
 		ServerVersion           string
 		NetworkStats            metrics.Stats
@@ -82,11 +193,20 @@ type (
 		TotalBootstrapPeers     int
 		ConnectedBootstrapPeers int
 		Reachability            string `enums:"Unknown,Public,Private"`
-		AwlDNSAddress           string
-		IsAwlDNSSetAsSystem     bool
-		VPN                     VPNInfo
-		SOCKS5                  SOCKS5Info
-		VPNGateway              VPNGatewayInfo
+		// OfflineMode is true when node is isolated from the internet and works only in LAN
+		OfflineMode bool
+		// PrivateNetwork is true when node accepts connections only from peers with the same network key
+		PrivateNetwork bool
+		// RelayServiceEnabled is true when node runs relay and DHT bootstrap service for known peers
+		RelayServiceEnabled bool
+		// SecretsEncryption is provider of the key which encrypts secrets in config file, empty if they are not encrypted
+		SecretsEncryption   string `enums:",passphrase,keyring,keyfile"`
+		AwlDNSAddress       string
+		IsAwlDNSSetAsSystem bool
+		VPN                 VPNInfo
+		SOCKS5              SOCKS5Info
+		VPNGateway          VPNGatewayInfo
+		Capabilities        protocol.PeerCapabilities
 	}
 
 	VPNInfo struct {
@@ -121,6 +241,61 @@ type (
 		// SuggestedIP is a free IP address generated for this peer
 		SuggestedIP string
 	}
+	PeerGroup struct {
+		config.PeerGroup
+		// Members are IDs of known peers in the group
+		Members []string
+	}
+	PeerPermissions struct {
+		PeerID string
+		Groups []string
+		// Own are permissions set for the peer itself
+		Own config.PeerPermissions
+		// Effective are own permissions combined with permissions of the peer groups
+		Effective config.PeerPermissions
+	}
+	// AuthDecision is a result of applying auth rules to incoming auth request from unknown peer.
+	AuthDecision struct {
+		PeerID string
+		Name   string
+		Time   time.Time
+		// Rule is a name of the rule which fired, built-in rules are invite, autoAcceptAuthRequests and default
+		Rule   string
+		Action string `enums:"accept,queue,reject"`
+		// InviteID is set when accepted peer used our invite
+		InviteID string `json:",omitempty"`
+		// VouchedBy are our confirmed known peers which vouched for the peer
+		VouchedBy            []string `json:",omitempty"`
+		AllowUsingAsExitNode bool
+		AllowUsingAsRelay    bool
+	}
+	// PeerPresence is a history of known peer connections within config.PresenceConfig RetentionDays.
+	// Time when this node wasn't running is counted as offline.
+	PeerPresence struct {
+		PeerID      string
+		DisplayName string
+		Online      bool
+		// Since is the beginning of the history
+		Since  time.Time
+		Uptime PresenceUptime
+		// Events are sorted by time, the oldest first
+		Events []PresenceEvent
+	}
+	// PresenceEvent is a change of known peer presence: it went online or offline, or its connection type changed.
+	PresenceEvent struct {
+		Time   time.Time
+		Online bool
+		// Connection is direct or relay, it's empty for offline events
+		Connection string `json:",omitempty" enums:"direct,relay"`
+		// AddressFamily of the remote address, for relayed connections it's the address of the relay
+		AddressFamily string `json:",omitempty" enums:"ip4,ip6"`
+	}
+	// PresenceUptime is a percentage of time the peer was online during the last day, week and month.
+	PresenceUptime struct {
+		Day   float64
+		Week  float64
+		Month float64
+	}
 
 	ListAvailableProxiesResponse struct {
 		Proxies []AvailableProxy
@@ -162,6 +337,70 @@ type (
 		PeerName  string
 		Connected bool
 	}
+
+	PrivateNetworkInfo struct {
+		// Enabled is true when private network key is set in config.
+		Enabled bool
+		// Active is true when node is running in private network mode. Changes are applied after restart.
+		Active            bool
+		NetworkKey        string
+		DHTProtocolPrefix string
+		BootstrapPeers    []string
+	}
+	GeneratePrivateNetworkKeyResponse struct {
+		NetworkKey string
+	}
+	PurgeAuthRequestsResponse struct {
+		// Purged is the number of removed pending auth requests
+		Purged int
+	}
+	RotateIdentityResponse struct {
+		// NewPeerID is used after restart, known peers keep our settings for it
+		NewPeerID string
+	}
+
+	SpeedTestResult struct {
+		PeerID       string
+		Direction    string
+		ThroughRelay bool
+		Duration     time.Duration `swaggertype:"primitive,integer"`
+		// Upload and Download are measured by the receiving side, nil if not tested.
+		Upload   *SpeedTestThroughput
+		Download *SpeedTestThroughput
+		// Latency is measured during the whole test, so it shows latency under load.
+		Latency SpeedTestLatency
+	}
+	SpeedTestThroughput struct {
+		Bytes         int64
+		BitsPerSecond float64
+	}
+	SpeedTestLatency struct {
+		ProbesSent int
+		// ProbesLost are probes without reply or with reply delayed more than 2 seconds.
+		ProbesLost  int
+		LossPercent float64
+		RTTMin      time.Duration `swaggertype:"primitive,integer"`
+		RTTAvg      time.Duration `swaggertype:"primitive,integer"`
+		RTTMax      time.Duration `swaggertype:"primitive,integer"`
+		// Jitter is the mean difference between consecutive RTTs.
+		Jitter time.Duration `swaggertype:"primitive,integer"`
+	}
+
+	Invite struct {
+		ID string
+		// URI is awl:// pairing link, it's also a QR code payload
+		URI       string
+		CreatedAt time.Time
+		ExpiresAt time.Time
+		MaxUses   int
+		Uses      int
+		// AllowUsingAsExitNode and AllowUsingAsRelay are set for peers which join with the invite
+		AllowUsingAsExitNode bool
+		AllowUsingAsRelay    bool
+	}
+	JoinInviteResponse struct {
+		PeerID string
+	}
 )
 
 type (
@@ -170,7 +409,9 @@ type (
 		DHT         DhtDebugInfo
 		Connections ConnectionsDebugInfo
 		Bandwidth   BandwidthDebugInfo
-		KnownPeers  []KnownPeersResponse
+		// ResourceManager contains resource limits, current usage and blocked requests
+		ResourceManager p2p.ResourceManagerDebugInfo
+		KnownPeers      []KnownPeersResponse
 	}
 
 	GeneralDebugInfo struct {
@@ -54,4 +54,77 @@ var (
 		Name:      "peer_latency_seconds",
 		Help:      "Last measured latency to known peers in seconds.",
 	}, []string{"peer_id"})
+
+	P2PPeerProbeRTTSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "peer_probe_rtt_seconds",
+		Help:      "RTT of connection quality probes to known peers in seconds.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.35, 0.5, 1, 2},
+	}, []string{"peer_id", "connection_type"})
+
+	P2PPeerProbeJitterSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "peer_probe_jitter_seconds",
+		Help:      "Jitter of connection quality probes to known peers in seconds, measured per probing round.",
+		Buckets:   []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"peer_id", "connection_type"})
+
+	P2PPeerProbesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "peer_probes_total",
+		Help:      "Total number of connection quality probes to known peers.",
+	}, []string{"peer_id", "result"})
+
+	P2PPeerConnectionTypeChangesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "peer_connection_type_changes_total",
+		Help:      "Total number of connection type changes between direct and relay for known peers.",
+	}, []string{"peer_id", "connection_type"})
+
+	P2PKnownPeerStartupConnectSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "known_peer_startup_connect_seconds",
+		Help:      "Time from node start to the first connection with each known peer.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 3, 5, 10, 15, 30, 60, 120},
+	})
+
+	P2PRelayServiceRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "relay_service_requests_total",
+		Help:      "Total number of reservation and connect requests to relay service for known peers.",
+	}, []string{"type", "result"})
+
+	P2PUpstreamProxyDialsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "upstream_proxy_dials_total",
+		Help:      "Total number of outbound TCP connections dialed through upstream proxy.",
+	}, []string{"result"})
+
+	P2PResourceManagerBlockedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "resource_manager_blocked_total",
+		Help:      "Total number of streams, connections and memory reservations blocked by resource limits.",
+	}, []string{"scope", "resource"})
+
+	P2PGossipedAddrsReceivedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "gossiped_addrs_received_total",
+		Help:      "Total number of known peers addresses received from other known peers.",
+	})
+
+	P2PBootstrapRefreshesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "p2p",
+		Name:      "bootstrap_refreshes_total",
+		Help:      "Total number of bootstrap peers refreshes from dnsaddr records and signed bootstrap list.",
+	}, []string{"source", "result"})
 )
@@ -62,6 +62,34 @@ var (
 		Help:      "Total number of auth requests received.",
 	})
 
+	PeersAuthRequestsDroppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "peers",
+		Name:      "auth_requests_dropped_total",
+		Help:      "Total number of auth requests from unknown peers dropped by rate limits, strangers requirements or evicted from pending.",
+	}, []string{"reason"})
+
+	PeersAuthInvitesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "peers",
+		Name:      "auth_invites_total",
+		Help:      "Total number of received auth requests with invite tokens.",
+	}, []string{"result"})
+
+	PeersAuthDecisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "peers",
+		Name:      "auth_decisions_total",
+		Help:      "Total number of auth rules decisions for auth requests from unknown peers.",
+	}, []string{"action"})
+
+	PeersKeyRotationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "peers",
+		Name:      "key_rotations_total",
+		Help:      "Total number of known peers migrated to a new peer ID after their key rotation.",
+	}, []string{"result"})
+
 	PeersStatusRequestsSentTotal = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
 		Subsystem: "peers",
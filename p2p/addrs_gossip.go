@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+
+	"github.com/anywherelan/awl/metrics"
+)
+
+const (
+	// MaxGossipedAddrs limits the number of addresses of one peer which are sent to or accepted from other known peer.
+	MaxGossipedAddrs = 8
+
+	// gossipedAddrTTL - gossiped addresses are only hints, they are refreshed by the next status exchange.
+	gossipedAddrTTL        = 10 * time.Minute
+	gossipedConnectTimeout = 5 * time.Second
+)
+
+// WorkingAddrs returns remote addresses of direct outbound connections to the peer.
+// These addresses were dialed successfully, so they are shared with mutual known peers.
+func (p *P2p) WorkingAddrs(peerID peer.ID) []multiaddr.Multiaddr {
+	var addrs []multiaddr.Multiaddr
+	for _, conn := range p.connsToPeer(peerID) {
+		if !isDirectConn(conn) || conn.Stat().Direction != network.DirOutbound {
+			continue
+		}
+		addr := conn.RemoteMultiaddr()
+		if !slices.ContainsFunc(addrs, addr.Equal) {
+			addrs = append(addrs, addr)
+		}
+		if len(addrs) == MaxGossipedAddrs {
+			break
+		}
+	}
+	return addrs
+}
+
+// AddGossipedAddrs adds addresses of known peer received from other known peer to peerstore and dials them
+// if there is no direct connection to the peer. Addresses are not trusted, the dial verifies peer ID.
+func (p *P2p) AddGossipedAddrs(peerID peer.ID, addrs []multiaddr.Multiaddr) {
+	if peerID == p.host.ID() {
+		return
+	}
+	addrs = slices.DeleteFunc(slices.Clone(addrs), isRelayAddr)
+	if len(addrs) > MaxGossipedAddrs {
+		addrs = addrs[:MaxGossipedAddrs]
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	metrics.P2PGossipedAddrsReceivedTotal.Add(float64(len(addrs)))
+	p.host.Peerstore().AddAddrs(peerID, addrs, gossipedAddrTTL)
+	if slices.ContainsFunc(p.connsToPeer(peerID), isDirectConn) {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(p.ctx, gossipedConnectTimeout)
+		defer cancel()
+		// upgrade relayed connection to direct one
+		ctx = network.WithForceDirectDial(ctx, "addrs gossip")
+		err := p.host.Connect(ctx, peer.AddrInfo{ID: peerID, Addrs: addrs})
+		if err != nil {
+			p.logger.Debugf("connect to known peer %s by gossiped addresses: %v", peerID, err)
+			return
+		}
+		p.observeKnownPeerConnected(peerID)
+	}()
+}
+
+// ShareableAddrs returns our announced addresses without loopback and relay ones, they can be shared with peers out of band.
+func (p *P2p) ShareableAddrs() []multiaddr.Multiaddr {
+	return slices.DeleteFunc(p.host.Addrs(), func(addr multiaddr.Multiaddr) bool {
+		return manet.IsIPLoopback(addr) || isRelayAddr(addr)
+	})
+}
@@ -0,0 +1,35 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP2p_AddGossipedAddrs(t *testing.T) {
+	newHost := func() host.Host {
+		h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = h.Close() })
+		return h
+	}
+	h := newHost()
+	knownPeer := newHost()
+
+	p := NewP2p(context.Background())
+	defer p.ctxCancel()
+	p.host = h
+
+	relayAddr := mustNewMultiaddr("/ip4/1.2.3.4/tcp/4363/p2p/12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn/p2p-circuit")
+	p.AddGossipedAddrs(knownPeer.ID(), append([]multiaddr.Multiaddr{relayAddr}, knownPeer.Addrs()...))
+	require.Eventually(t, func() bool {
+		return p.IsConnected(knownPeer.ID())
+	}, 5*time.Second, 50*time.Millisecond)
+	require.NotContains(t, h.Peerstore().Addrs(knownPeer.ID()), relayAddr)
+	require.Equal(t, knownPeer.Addrs(), p.WorkingAddrs(knownPeer.ID()))
+}
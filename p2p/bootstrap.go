@@ -0,0 +1,486 @@
+package p2p
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+
+	"github.com/anywherelan/awl/metrics"
+)
+
+const (
+	BootstrapSourceConfig  = "config"
+	BootstrapSourceDNSAddr = "dnsaddr"
+	BootstrapSourceList    = "list"
+
+	// bootstrapRefreshInterval - dnsaddr records and bootstrap list change rarely, cached peers are used between refreshes.
+	bootstrapRefreshInterval = 6 * time.Hour
+	bootstrapRefreshTimeout  = 30 * time.Second
+	bootstrapConnectTimeout  = 5 * time.Second
+	maxBootstrapListSize     = 1 << 20
+	// maxDNSAddrDepth limits resolving of dnsaddr records which point to other dnsaddr records.
+	maxDNSAddrDepth = 4
+
+	// bootstrapListSignaturePrefix separates bootstrap list signatures from other signatures made by the same key.
+	bootstrapListSignaturePrefix = "awl-bootstrap-list:"
+)
+
+var bootstrapCacheKey = ds.NewKey("/awl/bootstrap_cache")
+
+// BootstrapList is a versioned list of bootstrap peers published over HTTPS as SignedBootstrapList.
+type BootstrapList struct {
+	// Version must grow with each published list, lists older than the cached one are rejected,
+	// so an outdated list can't be replayed.
+	Version int64
+	// Peers are full multiaddrs with /p2p/ peer ID.
+	Peers []string
+}
+
+// SignedBootstrapList is a served document, List is JSON encoded BootstrapList.
+type SignedBootstrapList struct {
+	List      []byte
+	Signature []byte
+}
+
+// BootstrapListSource is a location and a signer of bootstrap list.
+type BootstrapListSource struct {
+	URL    string
+	Signer crypto.PubKey
+
+	// client is replaced on host init to dial with socket marking and via upstream proxy
+	client *http.Client
+}
+
+// NewBootstrapListSource validates HTTPS list URL and extracts public key from signer peer ID.
+func NewBootstrapListSource(listURL, signerID string) (*BootstrapListSource, error) {
+	parsedURL, err := url.Parse(listURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %v", err)
+	}
+	if parsedURL.Scheme != "https" || parsedURL.Host == "" {
+		return nil, fmt.Errorf("url must be https")
+	}
+	signer, err := peer.Decode(signerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signer id: %v", err)
+	}
+	signerKey, err := signer.ExtractPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("extract signer public key: %v", err)
+	}
+
+	return &BootstrapListSource{URL: listURL, Signer: signerKey, client: http.DefaultClient}, nil
+}
+
+// Fetch downloads bootstrap list and verifies its signature.
+func (s *BootstrapListSource) Fetch(ctx context.Context) (BootstrapList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return BootstrapList{}, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return BootstrapList{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return BootstrapList{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var signed SignedBootstrapList
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxBootstrapListSize)).Decode(&signed)
+	if err != nil {
+		return BootstrapList{}, fmt.Errorf("decode: %v", err)
+	}
+	return VerifyBootstrapList(signed, s.Signer)
+}
+
+// SignBootstrapList encodes and signs the list for publishing.
+func SignBootstrapList(list BootstrapList, key crypto.PrivKey) (SignedBootstrapList, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return SignedBootstrapList{}, err
+	}
+	signature, err := key.Sign(append([]byte(bootstrapListSignaturePrefix), data...))
+	if err != nil {
+		return SignedBootstrapList{}, err
+	}
+	return SignedBootstrapList{List: data, Signature: signature}, nil
+}
+
+// VerifyBootstrapList checks the signature and returns decoded list with valid peers addresses.
+func VerifyBootstrapList(signed SignedBootstrapList, signer crypto.PubKey) (BootstrapList, error) {
+	valid, err := signer.Verify(append([]byte(bootstrapListSignaturePrefix), signed.List...), signed.Signature)
+	if err != nil {
+		return BootstrapList{}, fmt.Errorf("verify signature: %v", err)
+	}
+	if !valid {
+		return BootstrapList{}, errors.New("invalid signature")
+	}
+
+	var list BootstrapList
+	err = json.Unmarshal(signed.List, &list)
+	if err != nil {
+		return BootstrapList{}, fmt.Errorf("decode list: %v", err)
+	}
+	if _, err = list.AddrInfos(); err != nil {
+		return BootstrapList{}, err
+	}
+	return list, nil
+}
+
+func (l BootstrapList) AddrInfos() ([]peer.AddrInfo, error) {
+	addrs := make([]multiaddr.Multiaddr, 0, len(l.Peers))
+	for _, s := range l.Peers {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer %q: %v", s, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return peer.AddrInfosFromP2pAddrs(addrs...)
+}
+
+// ResolveBootstrapDNSAddrs resolves /dnsaddr/ multiaddrs via DNS TXT records to bootstrap peers.
+// Resolved addresses without peer ID are skipped. Error is returned only if nothing was resolved.
+func ResolveBootstrapDNSAddrs(ctx context.Context, resolver *madns.Resolver, addrs []multiaddr.Multiaddr) ([]peer.AddrInfo, error) {
+	var resolved []multiaddr.Multiaddr
+	var errs []error
+	for depth := 0; len(addrs) > 0 && depth < maxDNSAddrDepth; depth++ {
+		var next []multiaddr.Multiaddr
+		for _, addr := range addrs {
+			results, err := resolver.Resolve(ctx, addr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("resolve %s: %v", addr, err))
+				continue
+			}
+			for _, result := range results {
+				if _, err := result.ValueForProtocol(multiaddr.P_DNSADDR); err == nil {
+					next = append(next, result)
+				} else {
+					resolved = append(resolved, result)
+				}
+			}
+		}
+		addrs = next
+	}
+
+	var infos []peer.AddrInfo
+	for _, addr := range resolved {
+		transport, peerID := peer.SplitAddr(addr)
+		if peerID == "" || transport == nil {
+			continue
+		}
+		i := slices.IndexFunc(infos, func(info peer.AddrInfo) bool { return info.ID == peerID })
+		if i == -1 {
+			infos = append(infos, peer.AddrInfo{ID: peerID})
+			i = len(infos) - 1
+		}
+		infos[i].Addrs = append(infos[i].Addrs, transport)
+	}
+	if len(infos) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return infos, nil
+}
+
+// MaintainBootstrapPeers periodically resolves /dnsaddr/ bootstrap addresses and fetches signed bootstrap list.
+// Until the first refresh completes, peers cached from the previous run are used.
+func (p *P2p) MaintainBootstrapPeers(ctx context.Context) {
+	if len(p.config.BootstrapDNSAddrs) == 0 && p.config.BootstrapList == nil {
+		return
+	}
+
+	ticker := time.NewTicker(bootstrapRefreshInterval)
+	defer ticker.Stop()
+	for {
+		p.refreshBootstrapPeers(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *P2p) refreshBootstrapPeers(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, bootstrapRefreshTimeout)
+	defer cancel()
+
+	if len(p.config.BootstrapDNSAddrs) > 0 {
+		infos, err := ResolveBootstrapDNSAddrs(ctx, madns.DefaultResolver, p.config.BootstrapDNSAddrs)
+		if err != nil {
+			p.logger.Warnf("resolve bootstrap dnsaddr: %v", err)
+			metrics.P2PBootstrapRefreshesTotal.WithLabelValues(BootstrapSourceDNSAddr, "failure").Inc()
+		} else {
+			p.bootstrapPeers.setDynamic(BootstrapSourceDNSAddr, infos)
+			metrics.P2PBootstrapRefreshesTotal.WithLabelValues(BootstrapSourceDNSAddr, "success").Inc()
+		}
+	}
+
+	if source := p.config.BootstrapList; source != nil {
+		list, err := source.Fetch(ctx)
+		if err == nil {
+			err = p.bootstrapPeers.setList(source.URL, list)
+		}
+		if err != nil {
+			p.logger.Warnf("fetch bootstrap list: %v", err)
+			metrics.P2PBootstrapRefreshesTotal.WithLabelValues(BootstrapSourceList, "failure").Inc()
+		} else {
+			metrics.P2PBootstrapRefreshesTotal.WithLabelValues(BootstrapSourceList, "success").Inc()
+		}
+	}
+
+	p.persistBootstrapCache(ctx)
+	p.connectNewBootstrapPeers(ctx)
+}
+
+// connectNewBootstrapPeers connects to bootstrap peers which appeared after refresh,
+// so DHT can bootstrap even if all peers known at start are gone.
+func (p *P2p) connectNewBootstrapPeers(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, bootstrapConnectTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, peerAddr := range p.bootstrapPeers.peers() {
+		if p.IsConnected(peerAddr.ID) {
+			continue
+		}
+		wg.Add(1)
+		p.host.ConnManager().Protect(peerAddr.ID, protectedBootstrapPeerTag)
+		go func() {
+			defer wg.Done()
+			err := p.host.Connect(ctx, peerAddr)
+			p.bootstrapPeers.reportConnect(peerAddr.ID, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// loadBootstrapCache restores bootstrap peers resolved in the previous run, so startup doesn't wait for DNS and HTTPS.
+func (p *P2p) loadBootstrapCache(ctx context.Context) {
+	if p.config.DHTDatastore == nil {
+		return
+	}
+	data, err := p.config.DHTDatastore.Get(ctx, bootstrapCacheKey)
+	if errors.Is(err, ds.ErrNotFound) {
+		return
+	} else if err != nil {
+		p.logger.Errorf("load bootstrap cache: %v", err)
+		return
+	}
+	var cache bootstrapCache
+	err = json.Unmarshal(data, &cache)
+	if err != nil {
+		p.logger.Errorf("unmarshal bootstrap cache: %v", err)
+		return
+	}
+
+	// peers of removed sources are not used anymore
+	if len(p.config.BootstrapDNSAddrs) == 0 {
+		delete(cache.Peers, BootstrapSourceDNSAddr)
+	}
+	if p.config.BootstrapList == nil || p.config.BootstrapList.URL != cache.ListURL {
+		delete(cache.Peers, BootstrapSourceList)
+		cache.ListURL, cache.ListVersion = "", 0
+	}
+	p.bootstrapPeers.restore(cache)
+}
+
+func (p *P2p) persistBootstrapCache(ctx context.Context) {
+	if p.config.DHTDatastore == nil {
+		return
+	}
+	data, err := json.Marshal(p.bootstrapPeers.cache())
+	if err != nil {
+		p.logger.Errorf("marshal bootstrap cache: %v", err)
+		return
+	}
+	err = p.config.DHTDatastore.Put(ctx, bootstrapCacheKey, data)
+	if err != nil {
+		p.logger.Errorf("save bootstrap cache: %v", err)
+	}
+}
+
+type bootstrapCache struct {
+	ListURL     string `json:",omitempty"`
+	ListVersion int64  `json:",omitempty"`
+	// Peers are resolved from dnsaddr records and fetched from bootstrap list, keyed by source.
+	Peers  map[string][]peer.AddrInfo
+	Health map[peer.ID]bootstrapPeerHealth
+}
+
+type bootstrapPeerHealth struct {
+	LastConnected time.Time `json:",omitzero"`
+	// Failures is a number of failed connections since the last successful one.
+	Failures int `json:",omitempty"`
+}
+
+// bootstrapPeerSet merges bootstrap peers from config, dnsaddr records and bootstrap list,
+// and ranks them by connection health: peers with fewer failures and recent connections go first.
+type bootstrapPeerSet struct {
+	lock        sync.RWMutex
+	static      []peer.AddrInfo
+	dynamic     map[string][]peer.AddrInfo
+	listURL     string
+	listVersion int64
+	health      map[peer.ID]bootstrapPeerHealth
+
+	ranked  []peer.AddrInfo
+	sources map[peer.ID]string
+}
+
+func newBootstrapPeerSet(static []peer.AddrInfo) *bootstrapPeerSet {
+	s := &bootstrapPeerSet{
+		static:  static,
+		dynamic: make(map[string][]peer.AddrInfo),
+		health:  make(map[peer.ID]bootstrapPeerHealth),
+	}
+	s.rebuild()
+	return s
+}
+
+func (s *bootstrapPeerSet) peers() []peer.AddrInfo {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return slices.Clone(s.ranked)
+}
+
+func (s *bootstrapPeerSet) peerInfo(peerID peer.ID) (source string, health bootstrapPeerHealth) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.sources[peerID], s.health[peerID]
+}
+
+func (s *bootstrapPeerSet) setDynamic(source string, infos []peer.AddrInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.dynamic[source] = infos
+	s.rebuild()
+}
+
+func (s *bootstrapPeerSet) setList(listURL string, list BootstrapList) error {
+	infos, err := list.AddrInfos()
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if listURL == s.listURL && list.Version < s.listVersion {
+		return fmt.Errorf("list version %d is older than cached version %d", list.Version, s.listVersion)
+	}
+	s.listURL, s.listVersion = listURL, list.Version
+	s.dynamic[BootstrapSourceList] = infos
+	s.rebuild()
+	return nil
+}
+
+func (s *bootstrapPeerSet) reportConnect(peerID peer.ID, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, exists := s.sources[peerID]; !exists {
+		return
+	}
+	health := s.health[peerID]
+	if err == nil {
+		health.LastConnected = time.Now()
+		health.Failures = 0
+	} else {
+		health.Failures++
+	}
+	s.health[peerID] = health
+	s.rank()
+}
+
+func (s *bootstrapPeerSet) cache() bootstrapCache {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	cache := bootstrapCache{
+		ListURL:     s.listURL,
+		ListVersion: s.listVersion,
+		Peers:       make(map[string][]peer.AddrInfo, len(s.dynamic)),
+		Health:      make(map[peer.ID]bootstrapPeerHealth, len(s.health)),
+	}
+	for source, infos := range s.dynamic {
+		cache.Peers[source] = slices.Clone(infos)
+	}
+	for peerID, health := range s.health {
+		cache.Health[peerID] = health
+	}
+	return cache
+}
+
+func (s *bootstrapPeerSet) restore(cache bootstrapCache) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listURL, s.listVersion = cache.ListURL, cache.ListVersion
+	for source, infos := range cache.Peers {
+		s.dynamic[source] = infos
+	}
+	for peerID, health := range cache.Health {
+		s.health[peerID] = health
+	}
+	s.rebuild()
+}
+
+// rebuild merges peers from all sources, the first source of the peer wins. Must be called with lock held.
+func (s *bootstrapPeerSet) rebuild() {
+	s.ranked = s.ranked[:0]
+	s.sources = make(map[peer.ID]string)
+	add := func(source string, infos []peer.AddrInfo) {
+		for _, info := range infos {
+			if _, exists := s.sources[info.ID]; !exists {
+				s.sources[info.ID] = source
+				s.ranked = append(s.ranked, peer.AddrInfo{ID: info.ID, Addrs: slices.Clone(info.Addrs)})
+				continue
+			}
+			i := slices.IndexFunc(s.ranked, func(ranked peer.AddrInfo) bool { return ranked.ID == info.ID })
+			for _, addr := range info.Addrs {
+				if !slices.ContainsFunc(s.ranked[i].Addrs, addr.Equal) {
+					s.ranked[i].Addrs = append(s.ranked[i].Addrs, addr)
+				}
+			}
+		}
+	}
+	add(BootstrapSourceConfig, s.static)
+	add(BootstrapSourceDNSAddr, s.dynamic[BootstrapSourceDNSAddr])
+	add(BootstrapSourceList, s.dynamic[BootstrapSourceList])
+
+	for peerID := range s.health {
+		if _, exists := s.sources[peerID]; !exists {
+			delete(s.health, peerID)
+		}
+	}
+	s.rank()
+}
+
+// rank must be called with lock held.
+func (s *bootstrapPeerSet) rank() {
+	slices.SortStableFunc(s.ranked, func(a, b peer.AddrInfo) int {
+		healthA, healthB := s.health[a.ID], s.health[b.ID]
+		return cmp.Or(
+			cmp.Compare(healthA.Failures, healthB.Failures),
+			healthB.LastConnected.Compare(healthA.LastConnected),
+		)
+	})
+}
@@ -0,0 +1,212 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBootstrapPeer1 = "12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn"
+	testBootstrapPeer2 = "12D3KooWKF1xbKxWTXXYaW6W5qCTqAHf8r5PCadAGb2bQHKqxWrv"
+	testBootstrapPeer3 = "12D3KooWQeAvoyVnRm6T5XzWpKD8AzM1buzBL6o95iCodCZVQAsV"
+)
+
+func TestBootstrapListSource_Fetch(t *testing.T) {
+	signerKey, signerPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	signerID, err := peer.IDFromPublicKey(signerPub)
+	require.NoError(t, err)
+	list := BootstrapList{Version: 3, Peers: []string{"/ip4/1.2.3.4/udp/6150/quic-v1/p2p/" + testBootstrapPeer1}}
+	signed, err := SignBootstrapList(list, signerKey)
+	require.NoError(t, err)
+
+	served := signed
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(served)
+	}))
+	defer server.Close()
+
+	source, err := NewBootstrapListSource(server.URL, signerID.String())
+	require.NoError(t, err)
+	source.client = server.Client()
+
+	fetched, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, list, fetched)
+
+	// list is modified after signing
+	served.List = []byte(`{"Version":4,"Peers":[]}`)
+	_, err = source.Fetch(context.Background())
+	require.ErrorContains(t, err, "invalid signature")
+
+	// list is signed by another key
+	otherKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	served, err = SignBootstrapList(list, otherKey)
+	require.NoError(t, err)
+	_, err = source.Fetch(context.Background())
+	require.ErrorContains(t, err, "invalid signature")
+
+	_, err = NewBootstrapListSource("http://example.com/bootstrap.json", signerID.String())
+	require.Error(t, err)
+	_, err = NewBootstrapListSource(server.URL, "invalid")
+	require.Error(t, err)
+}
+
+func TestBootstrapListSource_FetchWithMarkAndProxy(t *testing.T) {
+	signerKey, signerPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	signerID, err := peer.IDFromPublicKey(signerPub)
+	require.NoError(t, err)
+	list := BootstrapList{Version: 1, Peers: []string{"/ip4/1.2.3.4/udp/6150/quic-v1/p2p/" + testBootstrapPeer1}}
+	signed, err := SignBootstrapList(list, signerKey)
+	require.NoError(t, err)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(signed)
+	}))
+	defer server.Close()
+
+	proxyAddr, connects := startHTTPProxy(t, "user", "pass")
+	p := NewP2p(context.Background())
+	p.config.UpstreamProxy, err = NewUpstreamProxy("http://user:pass@"+proxyAddr, nil)
+	require.NoError(t, err)
+	var marks atomic.Int64
+	client := p.newOutboundHTTPClient(func(network, address string, c syscall.RawConn) error {
+		marks.Add(1)
+		return nil
+	})
+	client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	source, err := NewBootstrapListSource(server.URL, signerID.String())
+	require.NoError(t, err)
+	source.client = client
+	fetched, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, list, fetched)
+	require.EqualValues(t, 1, marks.Load())
+	require.EqualValues(t, 1, connects.Load())
+}
+
+func TestResolveBootstrapDNSAddrs(t *testing.T) {
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(&madns.MockResolver{
+		TXT: map[string][]string{
+			"_dnsaddr.bootstrap.example.com": {
+				"dnsaddr=/ip4/1.2.3.4/udp/6150/quic-v1/p2p/" + testBootstrapPeer1,
+				"dnsaddr=/ip4/1.2.3.4/tcp/6150/p2p/" + testBootstrapPeer1,
+				"dnsaddr=/dnsaddr/nested.example.com",
+				"dnsaddr=/ip4/1.2.3.5/tcp/6150",
+			},
+			"_dnsaddr.nested.example.com": {
+				"dnsaddr=/ip6/::1/tcp/6150/p2p/" + testBootstrapPeer2,
+			},
+		},
+	}))
+	require.NoError(t, err)
+
+	infos, err := ResolveBootstrapDNSAddrs(context.Background(), resolver, []ma.Multiaddr{mustNewMultiaddr("/dnsaddr/bootstrap.example.com")})
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, testBootstrapPeer1, infos[0].ID.String())
+	require.Len(t, infos[0].Addrs, 2)
+	require.Equal(t, testBootstrapPeer2, infos[1].ID.String())
+	require.Equal(t, "/ip6/::1/tcp/6150", infos[1].Addrs[0].String())
+}
+
+func Test_bootstrapPeerSet(t *testing.T) {
+	peer1, peer2, peer3 := mustDecodePeerID(testBootstrapPeer1), mustDecodePeerID(testBootstrapPeer2), mustDecodePeerID(testBootstrapPeer3)
+	configAddr := mustNewMultiaddr("/ip4/1.2.3.4/tcp/6150")
+	resolvedAddr := mustNewMultiaddr("/ip4/1.2.3.4/udp/6150/quic-v1")
+	set := newBootstrapPeerSet([]peer.AddrInfo{{ID: peer1, Addrs: []ma.Multiaddr{configAddr}}})
+
+	set.setDynamic(BootstrapSourceDNSAddr, []peer.AddrInfo{{ID: peer1, Addrs: []ma.Multiaddr{resolvedAddr}}, {ID: peer2}})
+	err := set.setList("https://example.com", BootstrapList{Version: 2, Peers: []string{"/ip4/1.2.3.6/tcp/6150/p2p/" + testBootstrapPeer3}})
+	require.NoError(t, err)
+	require.Equal(t, []peer.AddrInfo{
+		{ID: peer1, Addrs: []ma.Multiaddr{configAddr, resolvedAddr}},
+		{ID: peer2},
+		{ID: peer3, Addrs: []ma.Multiaddr{mustNewMultiaddr("/ip4/1.2.3.6/tcp/6150")}},
+	}, set.peers())
+	source, _ := set.peerInfo(peer1)
+	require.Equal(t, BootstrapSourceConfig, source)
+	source, _ = set.peerInfo(peer3)
+	require.Equal(t, BootstrapSourceList, source)
+
+	err = set.setList("https://example.com", BootstrapList{Version: 1})
+	require.ErrorContains(t, err, "older than cached version")
+
+	// failed peers go last, recently connected go first
+	set.reportConnect(peer1, errors.New("timeout"))
+	set.reportConnect(peer3, nil)
+	set.reportConnect(peer2, context.Canceled)
+	require.Equal(t, []peer.ID{peer3, peer2, peer1}, peerIDs(set.peers()))
+	_, health := set.peerInfo(peer1)
+	require.Equal(t, 1, health.Failures)
+	set.reportConnect(peer1, nil)
+	_, health = set.peerInfo(peer1)
+	require.Zero(t, health.Failures)
+	require.Equal(t, peer1, set.peers()[0].ID)
+}
+
+func TestP2p_bootstrapCache(t *testing.T) {
+	datastore := dssync.MutexWrap(ds.NewMapDatastore())
+	listSource := &BootstrapListSource{URL: "https://example.com"}
+	p := NewP2p(context.Background())
+	defer p.ctxCancel()
+	p.config.DHTDatastore = datastore
+	p.config.BootstrapDNSAddrs = []ma.Multiaddr{mustNewMultiaddr("/dnsaddr/bootstrap.example.com")}
+	p.config.BootstrapList = listSource
+	p.bootstrapPeers = newBootstrapPeerSet(nil)
+	p.bootstrapPeers.setDynamic(BootstrapSourceDNSAddr, []peer.AddrInfo{{ID: mustDecodePeerID(testBootstrapPeer1), Addrs: []ma.Multiaddr{mustNewMultiaddr("/ip4/1.2.3.4/tcp/6150")}}})
+	err := p.bootstrapPeers.setList(listSource.URL, BootstrapList{Version: 5, Peers: []string{"/ip4/1.2.3.6/tcp/6150/p2p/" + testBootstrapPeer2}})
+	require.NoError(t, err)
+	p.bootstrapPeers.reportConnect(mustDecodePeerID(testBootstrapPeer2), errors.New("timeout"))
+	p.persistBootstrapCache(context.Background())
+
+	restored := NewP2p(context.Background())
+	defer restored.ctxCancel()
+	restored.config = p.config
+	restored.loadBootstrapCache(context.Background())
+	require.Equal(t, p.bootstrapPeers.peers(), restored.bootstrapPeers.peers())
+	_, health := restored.bootstrapPeers.peerInfo(mustDecodePeerID(testBootstrapPeer2))
+	require.Equal(t, 1, health.Failures)
+	err = restored.bootstrapPeers.setList(listSource.URL, BootstrapList{Version: 4})
+	require.ErrorContains(t, err, "older than cached version")
+
+	// peers of sources removed from config are not restored
+	restored = NewP2p(context.Background())
+	defer restored.ctxCancel()
+	restored.config.DHTDatastore = datastore
+	restored.loadBootstrapCache(context.Background())
+	require.Empty(t, restored.bootstrapPeers.peers())
+}
+
+func mustDecodePeerID(s string) peer.ID {
+	peerID, err := peer.Decode(s)
+	if err != nil {
+		panic(err)
+	}
+	return peerID
+}
+
+func peerIDs(infos []peer.AddrInfo) []peer.ID {
+	result := make([]peer.ID, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, info.ID)
+	}
+	return result
+}
@@ -0,0 +1,383 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-log/v2"
+)
+
+const (
+	snapshotMagic    = "AWLDS\x01"
+	snapshotFilePerm = 0600
+
+	// DefaultSnapshotMaxSize is enough for a few thousands of peerstore records.
+	DefaultSnapshotMaxSize = 8 << 20
+	// DefaultMemoryMaxEntries bounds number of entries kept in memory.
+	DefaultMemoryMaxEntries = 20000
+
+	// memoryEvictTarget is the share of memory limits left after eviction,
+	// so the datastore isn't scanned for eviction on every put at the limit.
+	memoryEvictTarget = 0.9
+)
+
+var snapshotCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+type SnapshotDatastoreOptions struct {
+	// MaxSize bounds snapshot file size. Entries with the lowest priority are dropped first, see PriorityPrefixes.
+	MaxSize int
+	// MaxMemorySize and MaxMemoryEntries bound the datastore in memory, entries with the lowest priority are evicted first.
+	// MaxMemorySize is twice MaxSize by default.
+	MaxMemorySize    int
+	MaxMemoryEntries int
+	// PriorityPrefixes lists key prefixes in order of importance. Keys without matching prefix go last.
+	PriorityPrefixes []string
+	// AfterSave is called after the snapshot file is written.
+	AfterSave func(path string)
+}
+
+// SnapshotDatastore is an in-memory datastore which is periodically persisted to a single file.
+// All changes made between flushes cost one file write, which keeps disk IO low on mobile devices
+// compared to LSM based stores. Corrupted snapshot is moved aside and the datastore starts empty.
+type SnapshotDatastore struct {
+	*dssync.MutexDatastore
+	path   string
+	opts   SnapshotDatastoreOptions
+	logger *log.ZapEventLogger
+
+	dirty     atomic.Bool
+	flushLock sync.Mutex
+
+	// sizesLock guards sizes and memorySize, they account keys and values kept in memory
+	sizesLock  sync.Mutex
+	sizes      map[string]int
+	memorySize int
+}
+
+func OpenSnapshotDatastore(path string, opts SnapshotDatastoreOptions) (*SnapshotDatastore, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultSnapshotMaxSize
+	}
+	if opts.MaxMemorySize <= 0 {
+		opts.MaxMemorySize = 2 * opts.MaxSize
+	}
+	if opts.MaxMemoryEntries <= 0 {
+		opts.MaxMemoryEntries = DefaultMemoryMaxEntries
+	}
+	d := &SnapshotDatastore{
+		MutexDatastore: dssync.MutexWrap(ds.NewMapDatastore()),
+		path:           path,
+		opts:           opts,
+		logger:         log.Logger("awl/p2p/datastore"),
+		sizes:          make(map[string]int),
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("create datastore dir: %v", err)
+	}
+	entries, err := d.load()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return d, nil
+	case err != nil:
+		corruptedPath := path + ".corrupted"
+		d.logger.Warnf("datastore snapshot %s is corrupted, starting with empty datastore: %v", path, err)
+		if renameErr := os.Rename(path, corruptedPath); renameErr != nil {
+			return nil, fmt.Errorf("move corrupted snapshot aside: %v", renameErr)
+		}
+		return d, nil
+	}
+
+	ctx := context.Background()
+	d.sizesLock.Lock()
+	defer d.sizesLock.Unlock()
+	for _, entry := range entries {
+		err = d.putUnlocked(ctx, ds.RawKey(entry.Key), entry.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.evictUnlocked(ctx, "") > 0 {
+		d.dirty.Store(true)
+	}
+	d.logger.Infof("loaded %d datastore entries from %s", len(entries), path)
+
+	return d, nil
+}
+
+func (d *SnapshotDatastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	d.sizesLock.Lock()
+	defer d.sizesLock.Unlock()
+
+	d.dirty.Store(true)
+	err := d.putUnlocked(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	d.evictUnlocked(ctx, key.String())
+	return nil
+}
+
+func (d *SnapshotDatastore) Delete(ctx context.Context, key ds.Key) error {
+	d.sizesLock.Lock()
+	defer d.sizesLock.Unlock()
+
+	d.dirty.Store(true)
+	err := d.MutexDatastore.Delete(ctx, key)
+	if err != nil {
+		return err
+	}
+	d.memorySize -= d.sizes[key.String()]
+	delete(d.sizes, key.String())
+	return nil
+}
+
+// Batch applies operations one by one via Put and Delete, so memory limits apply to batches too.
+func (d *SnapshotDatastore) Batch(_ context.Context) (ds.Batch, error) {
+	return ds.NewBasicBatch(d), nil
+}
+
+func (d *SnapshotDatastore) putUnlocked(ctx context.Context, key ds.Key, value []byte) error {
+	err := d.MutexDatastore.Put(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	size := len(key.String()) + len(value)
+	d.memorySize += size - d.sizes[key.String()]
+	d.sizes[key.String()] = size
+	return nil
+}
+
+// evictUnlocked removes entries with the lowest priority when memory limits are exceeded and returns number of removed entries.
+// Key which is being put and private keys are never evicted.
+func (d *SnapshotDatastore) evictUnlocked(ctx context.Context, keepKey string) int {
+	if d.memorySize <= d.opts.MaxMemorySize && len(d.sizes) <= d.opts.MaxMemoryEntries {
+		return 0
+	}
+
+	candidates := make([]string, 0, len(d.sizes))
+	for key := range d.sizes {
+		if key != keepKey && !strings.HasSuffix(key, "/priv") {
+			candidates = append(candidates, key)
+		}
+	}
+	slices.SortFunc(candidates, func(a, b string) int {
+		if pa, pb := d.priority(a), d.priority(b); pa != pb {
+			return pb - pa
+		}
+		return strings.Compare(a, b)
+	})
+
+	targetSize := int(float64(d.opts.MaxMemorySize) * memoryEvictTarget)
+	targetEntries := int(float64(d.opts.MaxMemoryEntries) * memoryEvictTarget)
+	evicted := 0
+	for _, key := range candidates {
+		if d.memorySize <= targetSize && len(d.sizes) <= targetEntries {
+			break
+		}
+		err := d.MutexDatastore.Delete(ctx, ds.RawKey(key))
+		if err != nil {
+			d.logger.Errorf("evict datastore entry %s: %v", key, err)
+			continue
+		}
+		d.memorySize -= d.sizes[key]
+		delete(d.sizes, key)
+		evicted++
+	}
+	d.logger.Warnf("datastore exceeds memory limits of %d bytes or %d entries, evicted %d entries", d.opts.MaxMemorySize, d.opts.MaxMemoryEntries, evicted)
+
+	return evicted
+}
+
+// Close persists pending changes.
+func (d *SnapshotDatastore) Close() error {
+	return errors.Join(d.Flush(), d.MutexDatastore.Close())
+}
+
+// RunFlusher persists changes every interval until ctx is done.
+func (d *SnapshotDatastore) RunFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.Flush()
+			if err != nil {
+				d.logger.Errorf("flush datastore: %v", err)
+			}
+		}
+	}
+}
+
+// Flush writes snapshot to disk if there were changes since the last flush.
+func (d *SnapshotDatastore) Flush() error {
+	d.flushLock.Lock()
+	defer d.flushLock.Unlock()
+
+	if !d.dirty.Swap(false) {
+		return nil
+	}
+	err := d.save()
+	if err != nil {
+		d.dirty.Store(true)
+	}
+	return err
+}
+
+func (d *SnapshotDatastore) save() error {
+	results, err := d.MutexDatastore.Query(context.Background(), query.Query{})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	entries = slices.DeleteFunc(entries, func(e query.Entry) bool {
+		// libp2p keeps private key of the host in peerstore, it is already stored in config
+		return strings.HasSuffix(e.Key, "/priv")
+	})
+	slices.SortFunc(entries, func(a, b query.Entry) int {
+		if pa, pb := d.priority(a.Key), d.priority(b.Key); pa != pb {
+			return pa - pb
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	data, written := encodeSnapshot(entries, d.opts.MaxSize)
+	if written < len(entries) {
+		d.logger.Warnf("datastore snapshot exceeds max size %d bytes, dropped %d of %d entries", d.opts.MaxSize, len(entries)-written, len(entries))
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(d.path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	err = errors.Join(err, tmpFile.Close())
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmpFile.Name(), snapshotFilePerm)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile.Name(), d.path)
+	if err != nil {
+		return err
+	}
+	if d.opts.AfterSave != nil {
+		d.opts.AfterSave(d.path)
+	}
+
+	return nil
+}
+
+func (d *SnapshotDatastore) load() ([]query.Entry, error) {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(data)
+}
+
+func (d *SnapshotDatastore) priority(key string) int {
+	for i, prefix := range d.opts.PriorityPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return i
+		}
+	}
+	return len(d.opts.PriorityPrefixes)
+}
+
+// encodeSnapshot encodes entries until maxSize is reached and returns number of written entries.
+// Format: magic, then uvarint key length, key, uvarint value length, value for every entry, then CRC32-C of all previous bytes.
+func encodeSnapshot(entries []query.Entry, maxSize int) ([]byte, int) {
+	buf := make([]byte, 0, 4096)
+	buf = append(buf, snapshotMagic...)
+	written := 0
+	for _, entry := range entries {
+		entrySize := 2*binary.MaxVarintLen64 + len(entry.Key) + len(entry.Value)
+		if len(buf)+entrySize+crc32.Size > maxSize {
+			break
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(entry.Key)))
+		buf = append(buf, entry.Key...)
+		buf = binary.AppendUvarint(buf, uint64(len(entry.Value)))
+		buf = append(buf, entry.Value...)
+		written++
+	}
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf, snapshotCRCTable))
+
+	return buf, written
+}
+
+func decodeSnapshot(data []byte) ([]query.Entry, error) {
+	if len(data) < len(snapshotMagic)+crc32.Size || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errors.New("invalid snapshot header")
+	}
+	body, checksum := data[:len(data)-crc32.Size], binary.BigEndian.Uint32(data[len(data)-crc32.Size:])
+	if crc32.Checksum(body, snapshotCRCTable) != checksum {
+		return nil, errors.New("snapshot checksum mismatch")
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(body[len(snapshotMagic):]))
+	readChunk := func() ([]byte, error) {
+		size, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(len(body)) {
+			return nil, fmt.Errorf("invalid entry size %d", size)
+		}
+		chunk := make([]byte, size)
+		_, err = io.ReadFull(reader, chunk)
+		return chunk, err
+	}
+
+	var entries []query.Entry
+	for {
+		key, err := readChunk()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		value, err := readChunk()
+		if err != nil {
+			return nil, fmt.Errorf("read value: %w", noEOF(err))
+		}
+		entries = append(entries, query.Entry{Key: string(key), Value: value})
+	}
+}
+
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDatastore_Persist(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "datastore.bin")
+
+	d, err := OpenSnapshotDatastore(path, SnapshotDatastoreOptions{})
+	require.NoError(t, err)
+	require.NoError(t, d.Put(ctx, ds.NewKey("/peers/addrs/a"), []byte("addr")))
+	require.NoError(t, d.Put(ctx, ds.NewKey("/peers/keys/a/priv"), []byte("secret")))
+	batch, err := d.Batch(ctx)
+	require.NoError(t, err)
+	require.NoError(t, batch.Put(ctx, ds.NewKey("/dht/record"), []byte("record")))
+	require.NoError(t, batch.Commit(ctx))
+	require.NoError(t, d.Close())
+
+	d, err = OpenSnapshotDatastore(path, SnapshotDatastoreOptions{})
+	require.NoError(t, err)
+	value, err := d.Get(ctx, ds.NewKey("/peers/addrs/a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("addr"), value)
+	value, err = d.Get(ctx, ds.NewKey("/dht/record"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("record"), value)
+	_, err = d.Get(ctx, ds.NewKey("/peers/keys/a/priv"))
+	require.ErrorIs(t, err, ds.ErrNotFound)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(snapshotFilePerm), info.Mode().Perm())
+
+	// nothing changed, file is not rewritten
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, d.Flush())
+	require.NoFileExists(t, path)
+}
+
+func TestSnapshotDatastore_Corrupted(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "datastore.bin")
+
+	d, err := OpenSnapshotDatastore(path, SnapshotDatastoreOptions{})
+	require.NoError(t, err)
+	require.NoError(t, d.Put(ctx, ds.NewKey("/key"), []byte("value")))
+	require.NoError(t, d.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(snapshotMagic)+2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, snapshotFilePerm))
+
+	d, err = OpenSnapshotDatastore(path, SnapshotDatastoreOptions{})
+	require.NoError(t, err)
+	_, err = d.Get(ctx, ds.NewKey("/key"))
+	require.ErrorIs(t, err, ds.ErrNotFound)
+	require.FileExists(t, path+".corrupted")
+	require.NoFileExists(t, path)
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), snapshotFilePerm))
+	_, err = OpenSnapshotDatastore(path, SnapshotDatastoreOptions{})
+	require.NoError(t, err)
+}
+
+func TestSnapshotDatastore_MaxSize(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "datastore.bin")
+	opts := SnapshotDatastoreOptions{
+		MaxSize:          1024,
+		PriorityPrefixes: []string{"/important"},
+	}
+
+	d, err := OpenSnapshotDatastore(path, opts)
+	require.NoError(t, err)
+	value := []byte(strings.Repeat("v", 100))
+	for _, key := range []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h", "/i", "/j"} {
+		require.NoError(t, d.Put(ctx, ds.NewKey(key), value))
+	}
+	require.NoError(t, d.Put(ctx, ds.NewKey("/important/key"), value))
+	require.NoError(t, d.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.LessOrEqual(t, info.Size(), int64(opts.MaxSize))
+
+	d, err = OpenSnapshotDatastore(path, opts)
+	require.NoError(t, err)
+	_, err = d.Get(ctx, ds.NewKey("/important/key"))
+	require.NoError(t, err)
+	results, err := d.Query(ctx, query.Query{KeysOnly: true})
+	require.NoError(t, err)
+	entries, err := results.Rest()
+	require.NoError(t, err)
+	require.Less(t, len(entries), 11)
+}
+
+func TestSnapshotDatastore_MemoryLimits(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "datastore.bin")
+	opts := SnapshotDatastoreOptions{
+		MaxMemorySize:    2000,
+		MaxMemoryEntries: 10,
+		PriorityPrefixes: []string{"/important"},
+	}
+
+	d, err := OpenSnapshotDatastore(path, opts)
+	require.NoError(t, err)
+	require.NoError(t, d.Put(ctx, ds.NewKey("/important/key"), []byte("value")))
+	require.NoError(t, d.Put(ctx, ds.NewKey("/peers/keys/a/priv"), []byte("secret")))
+	batch, err := d.Batch(ctx)
+	require.NoError(t, err)
+	for i := range 20 {
+		require.NoError(t, batch.Put(ctx, ds.NewKey(fmt.Sprintf("/other/%d", i)), []byte("value")))
+	}
+	require.NoError(t, batch.Commit(ctx))
+	require.NoError(t, d.Put(ctx, ds.NewKey("/other/last"), []byte("value")))
+
+	countEntries := func() int {
+		results, err := d.Query(ctx, query.Query{KeysOnly: true})
+		require.NoError(t, err)
+		entries, err := results.Rest()
+		require.NoError(t, err)
+		return len(entries)
+	}
+	require.LessOrEqual(t, countEntries(), opts.MaxMemoryEntries)
+	_, err = d.Get(ctx, ds.NewKey("/important/key"))
+	require.NoError(t, err)
+	_, err = d.Get(ctx, ds.NewKey("/peers/keys/a/priv"))
+	require.NoError(t, err)
+	_, err = d.Get(ctx, ds.NewKey("/other/last"))
+	require.NoError(t, err)
+
+	// large values are bounded by size
+	require.NoError(t, d.Delete(ctx, ds.NewKey("/other/last")))
+	for i := range 5 {
+		require.NoError(t, d.Put(ctx, ds.NewKey(fmt.Sprintf("/large/%d", i)), []byte(strings.Repeat("v", 500))))
+	}
+	require.LessOrEqual(t, d.memorySize, opts.MaxMemorySize)
+	require.NoError(t, d.Close())
+}
@@ -0,0 +1,79 @@
+package p2p
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	// MDNSServiceName differs from libp2p default to not interfere with other libp2p apps in LAN.
+	MDNSServiceName = "_awl._udp"
+
+	// mdnsAddrTTL - peers re-announce themselves, so LAN addresses don't need to be kept for long.
+	mdnsAddrTTL        = 10 * time.Minute
+	mdnsConnectTimeout = 5 * time.Second
+)
+
+// mdnsNotifee handles peers discovered in LAN. Only known peers are added to peerstore and dialed.
+type mdnsNotifee struct {
+	p *P2p
+}
+
+func (n mdnsNotifee) HandlePeerFound(info peer.AddrInfo) {
+	p := n.p
+	if info.ID == p.host.ID() || p.config.IsKnownPeer == nil || !p.config.IsKnownPeer(info.ID) {
+		return
+	}
+	info.Addrs = slices.DeleteFunc(info.Addrs, manet.IsIPLoopback)
+	if len(info.Addrs) == 0 {
+		return
+	}
+
+	p.logger.Debugf("found known peer %s in LAN: %v", info.ID, info.Addrs)
+	p.host.Peerstore().AddAddrs(info.ID, info.Addrs, mdnsAddrTTL)
+	if slices.ContainsFunc(p.connsToPeer(info.ID), isDirectConn) {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(p.ctx, mdnsConnectTimeout)
+		defer cancel()
+		// upgrade relayed connection to direct one
+		ctx = network.WithForceDirectDial(ctx, "mdns")
+		err := p.host.Connect(ctx, info)
+		if err != nil {
+			p.logger.Debugf("connect to known peer %s found in LAN: %v", info.ID, err)
+			return
+		}
+		p.observeKnownPeerConnected(info.ID)
+	}()
+}
+
+func (p *P2p) startMDNS() error {
+	p.mdns = mdns.NewMdnsService(p.host, MDNSServiceName, mdnsNotifee{p: p})
+	return p.mdns.Start()
+}
+
+// isLANAddr reports whether addr is a direct address in private network.
+func isLANAddr(addr multiaddr.Multiaddr) bool {
+	if isRelayAddr(addr) {
+		return false
+	}
+	return manet.IsPrivateAddr(addr) && !manet.IsIPLoopback(addr)
+}
+
+func isDirectConn(conn network.Conn) bool {
+	return !isRelayAddr(conn.RemoteMultiaddr())
+}
+
+func isRelayAddr(addr multiaddr.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT)
+	return err == nil
+}
@@ -0,0 +1,64 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP2p_getDialRanker_PrefersLAN(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	p := NewP2p(context.Background())
+	p.config.Peerstore = ps
+
+	lanAddr := mustNewMultiaddr("/ip4/192.168.1.10/udp/4363/quic-v1")
+	publicAddr := mustNewMultiaddr("/ip4/1.2.3.4/udp/4363/quic-v1")
+	relayAddr := mustNewMultiaddr("/ip4/5.6.7.8/udp/4363/quic-v1/p2p/12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn/p2p-circuit")
+
+	delays := make(map[string]time.Duration)
+	for _, addrDelay := range p.getDialRanker()([]ma.Multiaddr{relayAddr, publicAddr, lanAddr}) {
+		delays[addrDelay.Addr.String()] = addrDelay.Delay
+	}
+	require.Zero(t, delays[lanAddr.String()])
+	require.GreaterOrEqual(t, delays[relayAddr.String()], lanRelayDelay)
+
+	delays = make(map[string]time.Duration)
+	for _, addrDelay := range p.getDialRanker()([]ma.Multiaddr{relayAddr, publicAddr}) {
+		delays[addrDelay.Addr.String()] = addrDelay.Delay
+	}
+	require.Less(t, delays[relayAddr.String()], lanRelayDelay)
+}
+
+func Test_mdnsNotifee(t *testing.T) {
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer h.Close()
+
+	knownPeer, err := peer.Decode("12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn")
+	require.NoError(t, err)
+	unknownPeer, err := peer.Decode("12D3KooWKF1xbKxWTXXYaW6W5qCTqAHf8r5PCadAGb2bQHKqxWrv")
+	require.NoError(t, err)
+
+	p := NewP2p(context.Background())
+	defer p.ctxCancel()
+	p.host = h
+	p.config.IsKnownPeer = func(id peer.ID) bool {
+		return id == knownPeer
+	}
+	notifee := mdnsNotifee{p: p}
+	lanAddr := mustNewMultiaddr("/ip4/192.168.1.10/udp/4363/quic-v1")
+	loopbackAddr := mustNewMultiaddr("/ip4/127.0.0.1/udp/4363/quic-v1")
+
+	notifee.HandlePeerFound(peer.AddrInfo{ID: unknownPeer, Addrs: []ma.Multiaddr{lanAddr}})
+	require.Empty(t, h.Peerstore().Addrs(unknownPeer))
+
+	notifee.HandlePeerFound(peer.AddrInfo{ID: knownPeer, Addrs: []ma.Multiaddr{lanAddr, loopbackAddr}})
+	require.Equal(t, []ma.Multiaddr{lanAddr}, h.Peerstore().Addrs(knownPeer))
+}
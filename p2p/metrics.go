@@ -11,6 +11,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	circuitproto "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	"github.com/multiformats/go-multiaddr"
 )
 
@@ -37,17 +38,24 @@ type ConnectionInfo struct {
 	Multiaddr    string
 	ThroughRelay bool
 	RelayPeerID  string
-	Address      string
-	Protocol     string
-	Direction    string
-	Opened       time.Time
-	Transient    bool
+	// RelayPeerName is a display name of known peer which relays the connection
+	RelayPeerName string `json:",omitempty"`
+	Address       string
+	Protocol      string
+	Direction     string
+	Opened        time.Time
+	Transient     bool
 }
 
 type BootstrapPeerDebugInfo struct {
 	Error       string   `json:",omitempty"`
 	Connections []string `json:",omitempty"`
 	Ping        Duration `json:",omitempty" swaggertype:"string"`
+	// Source is where the peer comes from: config, resolved dnsaddr record or signed bootstrap list
+	Source        string    `enums:"config,dnsaddr,list"`
+	LastConnected time.Time `json:",omitzero"`
+	// Failures is a number of failed connections since the last successful one, healthy peers are dialed first
+	Failures int `json:",omitempty"`
 }
 
 func (p *P2p) Uptime() time.Duration {
@@ -207,16 +215,47 @@ func (p *P2p) NetworkStatsForPeer(peerID peer.ID) metrics.Stats {
 	return p.bandwidthCounter.GetBandwidthForPeer(peerID)
 }
 
+// RelayedNetworkStatsForPeer returns traffic of relayed connections through the peer or relayed by us for the peer.
+func (p *P2p) RelayedNetworkStatsForPeer(peerID peer.ID) metrics.Stats {
+	return p.relayedBandwidthCounter.GetBandwidthForPeer(peerID)
+}
+
+// relayBandwidthReporter additionally counts traffic of circuit relay streams by peer.
+// For relay it's traffic relayed for the peer, for relay client it's traffic relayed through the peer.
+type relayBandwidthReporter struct {
+	metrics.Reporter
+	relayed metrics.Reporter
+}
+
+func (r relayBandwidthReporter) LogSentMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	r.Reporter.LogSentMessageStream(size, proto, p)
+	if isCircuitProtocol(proto) {
+		r.relayed.LogSentMessageStream(size, proto, p)
+	}
+}
+
+func (r relayBandwidthReporter) LogRecvMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	r.Reporter.LogRecvMessageStream(size, proto, p)
+	if isCircuitProtocol(proto) {
+		r.relayed.LogRecvMessageStream(size, proto, p)
+	}
+}
+
+func isCircuitProtocol(proto protocol.ID) bool {
+	return proto == circuitproto.ProtoIDv2Hop || proto == circuitproto.ProtoIDv2Stop
+}
+
 // BootstrapPeersStats returns total peers count and connected count.
 func (p *P2p) BootstrapPeersStats() (int, int) {
 	connected := 0
-	for _, peerAddr := range p.bootstrapPeers {
+	bootstrapPeers := p.bootstrapPeers.peers()
+	for _, peerAddr := range bootstrapPeers {
 		if p.IsConnected(peerAddr.ID) {
 			connected += 1
 		}
 	}
 
-	return len(p.bootstrapPeers), connected
+	return len(bootstrapPeers), connected
 }
 
 func (p *P2p) BootstrapPeersStatsDetailed() map[string]BootstrapPeerDebugInfo {
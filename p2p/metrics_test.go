@@ -3,8 +3,13 @@ package p2p
 import (
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	circuitproto "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_parseMultiaddrToInfo(t *testing.T) {
@@ -86,3 +91,19 @@ func mustNewMultiaddr(addrStr string) ma.Multiaddr {
 	}
 	return multiaddr
 }
+
+func Test_relayBandwidthReporter(t *testing.T) {
+	peerID, err := peer.Decode("12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn")
+	require.NoError(t, err)
+	total, relayed := metrics.NewBandwidthCounter(), metrics.NewBandwidthCounter()
+	reporter := relayBandwidthReporter{Reporter: total, relayed: relayed}
+
+	reporter.LogSentMessageStream(100, circuitproto.ProtoIDv2Hop, peerID)
+	reporter.LogRecvMessageStream(200, circuitproto.ProtoIDv2Stop, peerID)
+	reporter.LogSentMessageStream(1000, "/awl/tunnel/", peerID)
+
+	require.Eventually(t, func() bool {
+		stats := relayed.GetBandwidthForPeer(peerID)
+		return stats.TotalOut == 100 && stats.TotalIn == 200 && total.GetBandwidthForPeer(peerID).TotalOut == 1100
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,426 @@
+package p2p
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	basichost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	NATTypeNone                = "none"
+	NATTypePortMapped          = "port-mapped"
+	NATTypeEndpointIndependent = "endpoint-independent"
+	NATTypeEndpointDependent   = "endpoint-dependent"
+	NATTypeUnknown             = "unknown"
+
+	ReachabilityStatusReachable   = "reachable"
+	ReachabilityStatusUnreachable = "unreachable"
+	ReachabilityStatusUnknown     = "unknown"
+
+	HolePunchEventDirectDial = "direct_dial"
+	HolePunchEventHolePunch  = "hole_punch"
+
+	netcheckPingTimeout = 3 * time.Second
+
+	holePunchHistoryPerPeer = 10
+	holePunchMaxPeers       = 100
+)
+
+type NetcheckReport struct {
+	// NATType is derived from AutoNATv2 confirmed addresses, port mappings and
+	// observed NAT device types. One of NATType* constants.
+	NATType      string
+	Reachability string `enums:"Unknown,Public,Private"`
+	UDP          TransportReachability
+	TCP          TransportReachability
+	PortMapping  PortMappingInfo
+	PublicAddrs  []string
+	IPv6         IPv6Info
+	// BootstrapPeers are keyed by peer ID.
+	BootstrapPeers map[string]BootstrapPeerNetcheck
+	// RelayReservations contains peer IDs of relays we hold a reservation with.
+	RelayReservations []string
+	// HolePunching is keyed by peer ID, newest events last.
+	HolePunching map[string][]HolePunchEvent
+	// UpstreamProxy is set when outbound TCP connections are dialed via proxy.
+	UpstreamProxy *UpstreamProxyNetcheck `json:",omitempty"`
+}
+
+type UpstreamProxyNetcheck struct {
+	// URL is proxy url without credentials.
+	URL string
+	// Reachable is true when bootstrap peer is reachable via proxy.
+	Reachable   bool
+	Error       string `json:",omitempty"`
+	Dials       int64
+	FailedDials int64
+	ActiveConns int64
+	// LastDialError is the last error of dialing peer via proxy.
+	LastDialError string `json:",omitempty"`
+}
+
+type TransportReachability struct {
+	Status        string `enums:"reachable,unreachable,unknown"`
+	NATDeviceType string `enums:"Unknown,Endpoint Independent,Endpoint Dependent"`
+	Reachable     []string
+	Unreachable   []string
+	Unknown       []string
+}
+
+type PortMappingInfo struct {
+	Enabled        bool
+	NATDeviceFound bool
+	// Mappings maps listen addresses to external addresses obtained via UPnP or NAT-PMP.
+	Mappings map[string]string
+}
+
+type IPv6Info struct {
+	HasGlobalAddress bool
+	ConnectedPeers   int
+	Works            bool
+}
+
+type BootstrapPeerNetcheck struct {
+	Connected bool
+	Ping      Duration `json:",omitempty" swaggertype:"string"`
+	Error     string   `json:",omitempty"`
+}
+
+type HolePunchEvent struct {
+	Time    time.Time
+	Type    string `enums:"direct_dial,hole_punch"`
+	Success bool
+	Elapsed Duration `swaggertype:"string"`
+	Error   string   `json:",omitempty"`
+}
+
+// Netcheck collects connectivity diagnostics. Connected bootstrap peers are
+// pinged to get fresh latency, everything else comes from already gathered state.
+func (p *P2p) Netcheck(ctx context.Context) NetcheckReport {
+	reachable, unreachable, unknown := p.ConfirmedAddrs()
+	report := NetcheckReport{
+		Reachability:   p.Reachability().String(),
+		UDP:            p.transportReachability(network.NATTransportUDP, reachable, unreachable, unknown),
+		TCP:            p.transportReachability(network.NATTransportTCP, reachable, unreachable, unknown),
+		PortMapping:    p.portMappingInfo(),
+		PublicAddrs:    make([]string, 0),
+		IPv6:           p.ipv6Info(),
+		BootstrapPeers: p.pingBootstrapPeers(ctx),
+		HolePunching:   p.holePunchTracer.history(),
+	}
+
+	for _, addr := range p.AnnouncedAs() {
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			continue
+		}
+		if manet.IsPublicAddr(addr) {
+			report.PublicAddrs = append(report.PublicAddrs, addr.String())
+		}
+	}
+	sort.Strings(report.PublicAddrs)
+
+	report.RelayReservations = p.relayReservations()
+	report.NATType = natType(report)
+	report.UpstreamProxy = p.upstreamProxyNetcheck(ctx)
+
+	return report
+}
+
+func (p *P2p) upstreamProxyNetcheck(ctx context.Context) *UpstreamProxyNetcheck {
+	upstreamProxy := p.config.UpstreamProxy
+	if upstreamProxy == nil {
+		return nil
+	}
+
+	var targets []multiaddr.Multiaddr
+	for _, info := range p.config.BootstrapPeers {
+		targets = append(targets, info.Addrs...)
+	}
+	err := upstreamProxy.Check(ctx, targets)
+	stats := upstreamProxy.Stats()
+	info := &UpstreamProxyNetcheck{
+		URL:           upstreamProxy.String(),
+		Reachable:     err == nil,
+		Dials:         stats.Dials,
+		FailedDials:   stats.FailedDials,
+		ActiveConns:   stats.ActiveConns,
+		LastDialError: stats.LastError,
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	return info
+}
+
+func (p *P2p) transportReachability(proto network.NATTransportProtocol, reachable, unreachable, unknown []multiaddr.Multiaddr) TransportReachability {
+	code := multiaddr.P_UDP
+	if proto == network.NATTransportTCP {
+		code = multiaddr.P_TCP
+	}
+	filter := func(addrs []multiaddr.Multiaddr) []string {
+		res := make([]string, 0)
+		for _, addr := range addrs {
+			if _, err := addr.ValueForProtocol(code); err == nil {
+				res = append(res, addr.String())
+			}
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	info := TransportReachability{
+		Reachable:     filter(reachable),
+		Unreachable:   filter(unreachable),
+		Unknown:       filter(unknown),
+		NATDeviceType: p.natDeviceType(proto).String(),
+	}
+	switch {
+	case len(info.Reachable) > 0:
+		info.Status = ReachabilityStatusReachable
+	case len(info.Unreachable) > 0:
+		info.Status = ReachabilityStatusUnreachable
+	default:
+		info.Status = ReachabilityStatusUnknown
+	}
+
+	return info
+}
+
+func (p *P2p) portMappingInfo() PortMappingInfo {
+	info := PortMappingInfo{
+		Enabled:  p.config.EnableNATPortMap,
+		Mappings: make(map[string]string),
+	}
+	p.natManagerLock.Lock()
+	natManager := p.natManager
+	p.natManagerLock.Unlock()
+	if natManager == nil {
+		return info
+	}
+
+	info.NATDeviceFound = natManager.HasDiscoveredNAT()
+	for _, addr := range p.host.Network().ListenAddresses() {
+		mapped := natManager.GetMapping(addr)
+		if mapped != nil {
+			info.Mappings[addr.String()] = mapped.String()
+		}
+	}
+
+	return info
+}
+
+func (p *P2p) ipv6Info() IPv6Info {
+	var info IPv6Info
+	ifaceAddrs, err := p.host.Network().InterfaceListenAddresses()
+	if err != nil {
+		p.logger.Warnf("get interface listen addresses: %v", err)
+	}
+	for _, addr := range ifaceAddrs {
+		if _, err := addr.ValueForProtocol(multiaddr.P_IP6); err == nil && manet.IsPublicAddr(addr) {
+			info.HasGlobalAddress = true
+			break
+		}
+	}
+
+	for _, peerID := range p.host.Network().Peers() {
+		for _, conn := range p.connsToPeer(peerID) {
+			addr := conn.RemoteMultiaddr()
+			if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+				continue
+			}
+			if _, err := addr.ValueForProtocol(multiaddr.P_IP6); err == nil && manet.IsPublicAddr(addr) {
+				info.ConnectedPeers++
+				break
+			}
+		}
+	}
+	info.Works = info.ConnectedPeers > 0
+
+	return info
+}
+
+func (p *P2p) pingBootstrapPeers(ctx context.Context) map[string]BootstrapPeerNetcheck {
+	ctx, cancel := context.WithTimeout(ctx, netcheckPingTimeout)
+	defer cancel()
+
+	bootstrapPeers := p.bootstrapPeers.peers()
+	result := make(map[string]BootstrapPeerNetcheck, len(bootstrapPeers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, peerAddr := range bootstrapPeers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info := BootstrapPeerNetcheck{Connected: p.IsConnected(peerAddr.ID)}
+			if info.Connected {
+				pingCtx, pingCancel := context.WithCancel(ctx)
+				res := <-ping.Ping(pingCtx, p.host, peerAddr.ID)
+				pingCancel()
+				if res.Error != nil {
+					info.Error = res.Error.Error()
+				} else {
+					info.Ping = Duration(res.RTT)
+				}
+			}
+
+			mu.Lock()
+			result[peerAddr.ID.String()] = info
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+func (p *P2p) relayReservations() []string {
+	relays := make([]string, 0)
+	for _, addr := range p.AnnouncedAs() {
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err != nil {
+			continue
+		}
+		relayID, err := addr.ValueForProtocol(multiaddr.P_P2P)
+		if err != nil || slices.Contains(relays, relayID) {
+			continue
+		}
+		relays = append(relays, relayID)
+	}
+	sort.Strings(relays)
+
+	return relays
+}
+
+func natType(report NetcheckReport) string {
+	if report.UDP.Status == ReachabilityStatusReachable || report.TCP.Status == ReachabilityStatusReachable {
+		if len(report.PortMapping.Mappings) > 0 {
+			return NATTypePortMapped
+		}
+		return NATTypeNone
+	}
+
+	// endpoint dependent mapping on any transport makes hole punching unlikely, report the worst case
+	natTypes := []string{report.UDP.NATDeviceType, report.TCP.NATDeviceType}
+	switch {
+	case slices.Contains(natTypes, network.NATDeviceTypeEndpointDependent.String()):
+		return NATTypeEndpointDependent
+	case slices.Contains(natTypes, network.NATDeviceTypeEndpointIndependent.String()):
+		return NATTypeEndpointIndependent
+	default:
+		return NATTypeUnknown
+	}
+}
+
+func (p *P2p) natDeviceType(proto network.NATTransportProtocol) network.NATDeviceType {
+	p.natDeviceTypesLock.RLock()
+	defer p.natDeviceTypesLock.RUnlock()
+	return p.natDeviceTypes[proto]
+}
+
+func (p *P2p) newNATManager(n network.Network) basichost.NATManager {
+	natManager := basichost.NewNATManager(n)
+	p.natManagerLock.Lock()
+	p.natManager = natManager
+	p.natManagerLock.Unlock()
+	return natManager
+}
+
+func (p *P2p) subscribeNATDeviceTypeChanges() error {
+	sub, err := p.host.EventBus().Subscribe(new(event.EvtNATDeviceTypeChanged))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case ev, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				evt := ev.(event.EvtNATDeviceTypeChanged)
+				p.natDeviceTypesLock.Lock()
+				p.natDeviceTypes[evt.TransportProtocol] = evt.NatDeviceType
+				p.natDeviceTypesLock.Unlock()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// holePunchTracer keeps last hole punching results for a limited number of peers.
+type holePunchTracer struct {
+	mu     sync.Mutex
+	events map[peer.ID][]HolePunchEvent
+}
+
+func newHolePunchTracer() *holePunchTracer {
+	return &holePunchTracer{
+		events: make(map[peer.ID][]HolePunchEvent),
+	}
+}
+
+func (t *holePunchTracer) Trace(evt *holepunch.Event) {
+	var hpEvent HolePunchEvent
+	switch e := evt.Evt.(type) {
+	case *holepunch.DirectDialEvt:
+		hpEvent = HolePunchEvent{Type: HolePunchEventDirectDial, Success: e.Success, Elapsed: Duration(e.EllapsedTime), Error: e.Error}
+	case *holepunch.EndHolePunchEvt:
+		hpEvent = HolePunchEvent{Type: HolePunchEventHolePunch, Success: e.Success, Elapsed: Duration(e.EllapsedTime), Error: e.Error}
+	default:
+		return
+	}
+	hpEvent.Time = time.Unix(0, evt.Timestamp)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events, exists := t.events[evt.Remote]
+	if !exists && len(t.events) >= holePunchMaxPeers {
+		t.evictOldestLocked()
+	}
+	events = append(events, hpEvent)
+	if len(events) > holePunchHistoryPerPeer {
+		events = events[len(events)-holePunchHistoryPerPeer:]
+	}
+	t.events[evt.Remote] = events
+}
+
+func (t *holePunchTracer) evictOldestLocked() {
+	var oldestPeer peer.ID
+	var oldestTime time.Time
+	for peerID, events := range t.events {
+		last := events[len(events)-1].Time
+		if oldestTime.IsZero() || last.Before(oldestTime) {
+			oldestPeer = peerID
+			oldestTime = last
+		}
+	}
+	delete(t.events, oldestPeer)
+}
+
+func (t *holePunchTracer) history() map[string][]HolePunchEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string][]HolePunchEvent, len(t.events))
+	for peerID, events := range t.events {
+		result[peerID.String()] = slices.Clone(events)
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_natType(t *testing.T) {
+	independent := network.NATDeviceTypeEndpointIndependent.String()
+	dependent := network.NATDeviceTypeEndpointDependent.String()
+	unknown := network.NATDeviceTypeUnknown.String()
+
+	tests := []struct {
+		name   string
+		report NetcheckReport
+		want   string
+	}{
+		{
+			name:   "public",
+			report: NetcheckReport{UDP: TransportReachability{Status: ReachabilityStatusReachable}},
+			want:   NATTypeNone,
+		},
+		{
+			name: "port mapped",
+			report: NetcheckReport{
+				TCP:         TransportReachability{Status: ReachabilityStatusReachable},
+				PortMapping: PortMappingInfo{Mappings: map[string]string{"/ip4/0.0.0.0/tcp/4363": "/ip4/1.2.3.4/tcp/4363"}},
+			},
+			want: NATTypePortMapped,
+		},
+		{
+			name: "endpoint independent",
+			report: NetcheckReport{
+				UDP: TransportReachability{Status: ReachabilityStatusUnreachable, NATDeviceType: independent},
+				TCP: TransportReachability{Status: ReachabilityStatusUnknown, NATDeviceType: unknown},
+			},
+			want: NATTypeEndpointIndependent,
+		},
+		{
+			name: "endpoint dependent wins",
+			report: NetcheckReport{
+				UDP: TransportReachability{Status: ReachabilityStatusUnreachable, NATDeviceType: independent},
+				TCP: TransportReachability{Status: ReachabilityStatusUnreachable, NATDeviceType: dependent},
+			},
+			want: NATTypeEndpointDependent,
+		},
+		{
+			name:   "unknown",
+			report: NetcheckReport{UDP: TransportReachability{NATDeviceType: unknown}, TCP: TransportReachability{NATDeviceType: unknown}},
+			want:   NATTypeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, natType(tt.report))
+		})
+	}
+}
+
+func Test_holePunchTracer(t *testing.T) {
+	tracer := newHolePunchTracer()
+	start := time.Now()
+
+	peerID := peer.ID("peer")
+	for i := range holePunchHistoryPerPeer + 5 {
+		tracer.Trace(&holepunch.Event{
+			Remote:    peerID,
+			Timestamp: start.Add(time.Duration(i) * time.Second).UnixNano(),
+			Evt:       &holepunch.EndHolePunchEvt{Success: i%2 == 0, EllapsedTime: time.Second},
+		})
+	}
+	// ignored event type
+	tracer.Trace(&holepunch.Event{Remote: peerID, Timestamp: start.UnixNano(), Evt: &holepunch.StartHolePunchEvt{}})
+
+	history := tracer.history()
+	require.Len(t, history[peerID.String()], holePunchHistoryPerPeer)
+	last := history[peerID.String()][holePunchHistoryPerPeer-1]
+	require.Equal(t, HolePunchEventHolePunch, last.Type)
+	require.Equal(t, start.Add(time.Duration(holePunchHistoryPerPeer+4)*time.Second).UnixNano(), last.Time.UnixNano())
+
+	for i := range holePunchMaxPeers {
+		tracer.Trace(&holepunch.Event{
+			Remote:    peer.ID(fmt.Sprintf("peer-%d", i)),
+			Timestamp: start.Add(time.Hour + time.Duration(i)*time.Second).UnixNano(),
+			Evt:       &holepunch.DirectDialEvt{Success: true},
+		})
+	}
+	history = tracer.history()
+	require.Len(t, history, holePunchMaxPeers)
+	require.NotContains(t, history, peerID.String(), "oldest peer should be evicted")
+}
@@ -3,10 +3,12 @@ package p2p
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"slices"
 	"sync"
 	"sync/atomic"
@@ -23,12 +25,18 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	basichost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	"github.com/libp2p/go-libp2p/p2p/host/relaysvc"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	libp2pquic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
@@ -43,6 +51,9 @@ const (
 
 	DHTProtocolPrefix protocol.ID = "/awl"
 
+	// lanRelayDelay is an extra dial delay of relay addresses when peer has LAN addresses.
+	lanRelayDelay = time.Second
+
 	protectedBootstrapPeerTag = "bootstrap"
 	protectedPeerTag          = "known"
 
@@ -58,10 +69,40 @@ type HostConfig struct {
 	BootstrapPeers           []peer.AddrInfo
 	AllowEmptyBootstrapPeers bool
 	EnableAutoRelay          bool
+	EnableNATPortMap         bool
+	EnableHolePunching       bool
+	// BootstrapDNSAddrs are /dnsaddr/ multiaddrs without peer ID, they are resolved to bootstrap peers in background.
+	BootstrapDNSAddrs []multiaddr.Multiaddr
+	// BootstrapList is an optional signed list of bootstrap peers fetched over HTTPS.
+	BootstrapList *BootstrapListSource
+	// EnableMDNS enables discovery of known peers in LAN, IsKnownPeer must be set.
+	EnableMDNS  bool
+	IsKnownPeer func(peer.ID) bool
+	// PrivateNetworkKey enables libp2p private network, only TCP transport is used because QUIC doesn't support it.
+	PrivateNetworkKey pnet.PSK
+	// DHTProtocolPrefix overrides default DHTProtocolPrefix.
+	DHTProtocolPrefix protocol.ID
+	// EnableRelayService is set when relay service is enabled for all known peers.
+	// DHT runs in server mode, so they can bootstrap from us.
+	EnableRelayService bool
+	// IsRelayAllowedForPeer enables circuit relay service, only allowed peers can make reservations and relay connections.
+	IsRelayAllowedForPeer func(peer.ID) bool
+	// IsRelayServiceUsed reports whether any peer may use relay service, it runs only then. See P2p.UpdateRelayService.
+	IsRelayServiceUsed    func() bool
+	RelayServiceResources relayv2.Resources
+	// TrustedRelays returns known peers which run relay service for us, they are preferred over bootstrap peers.
+	TrustedRelays func() []peer.ID
 
 	// SocketControlFunc is set when gateway mode is enabled to mark sockets
 	// (e.g., SO_MARK on Linux) so they bypass the VPN TUN interface.
 	SocketControlFunc func(network, address string, c syscall.RawConn) error
+	// UpstreamProxy is used for outbound TCP connections, QUIC is dialed directly.
+	UpstreamProxy *UpstreamProxy
+	// WebSocketTLSConfig is used for secure WebSocket listen addresses, without it only plain WebSocket can be listened.
+	WebSocketTLSConfig *tls.Config
+
+	// ResourceLimits of libp2p resource manager, nil means no limits.
+	ResourceLimits *rcmgr.ConcreteLimitConfig
 
 	Libp2pOpts  []libp2p.Option
 	ConnManager struct {
@@ -97,9 +138,26 @@ type P2p struct {
 	bandwidthCounter metrics.Reporter
 	connManager      *connmgr.BasicConnMgr
 	config           HostConfig
-	bootstrapPeers   []peer.AddrInfo
+	bootstrapPeers   *bootstrapPeerSet
 	startedAt        time.Time
 	bootstrapsInfo   atomic.Pointer[map[string]BootstrapPeerDebugInfo]
+	// relayedBandwidthCounter counts only traffic of circuit relay streams
+	relayedBandwidthCounter metrics.Reporter
+
+	natManager         basichost.NATManager
+	natManagerLock     sync.Mutex
+	natDeviceTypes     map[network.NATTransportProtocol]network.NATDeviceType
+	natDeviceTypesLock sync.RWMutex
+	holePunchTracer    *holePunchTracer
+	blockedResources   *blockedResourcesReporter
+	mdns               mdns.Service
+	relayManager       *relaysvc.RelayManager
+	relayManagerLock   sync.Mutex
+	connectionQuality  *connectionQuality
+	// connectedKnownPeers contains known peers connected at least once since start.
+	connectedKnownPeers sync.Map
+	staticAddrs         map[peer.ID][]multiaddr.Multiaddr
+	staticAddrsLock     sync.Mutex
 
 	dhtBootstrapFinishedChan chan struct{}
 }
@@ -111,6 +169,13 @@ func NewP2p(ctx context.Context) *P2p {
 		ctxCancel: ctxCancel,
 		logger:    log.Logger("awl/p2p"),
 
+		natDeviceTypes:    make(map[network.NATTransportProtocol]network.NATDeviceType),
+		holePunchTracer:   newHolePunchTracer(),
+		blockedResources:  newBlockedResourcesReporter(),
+		connectionQuality: newConnectionQuality(),
+		staticAddrs:       make(map[peer.ID][]multiaddr.Multiaddr),
+		bootstrapPeers:    newBootstrapPeerSet(nil),
+
 		dhtBootstrapFinishedChan: make(chan struct{}),
 	}
 }
@@ -130,12 +195,14 @@ func (p *P2p) InitHost(hostConfig HostConfig) (host.Host, error) {
 		}
 	}
 
-	if !hostConfig.AllowEmptyBootstrapPeers && len(hostConfig.BootstrapPeers) == 0 {
+	noBootstrapSources := len(hostConfig.BootstrapPeers) == 0 && len(hostConfig.BootstrapDNSAddrs) == 0 && hostConfig.BootstrapList == nil
+	if !hostConfig.AllowEmptyBootstrapPeers && noBootstrapSources {
 		return nil, fmt.Errorf("zero bootstrap peers provided")
 	}
 
 	p.bandwidthCounter = metrics.NewBandwidthCounter()
-	p.bootstrapPeers = hostConfig.BootstrapPeers
+	p.relayedBandwidthCounter = metrics.NewBandwidthCounter()
+	p.bootstrapPeers = newBootstrapPeerSet(hostConfig.BootstrapPeers)
 
 	p.connManager, err = connmgr.NewConnManager(
 		hostConfig.ConnManager.LowWater,
@@ -145,11 +212,24 @@ func (p *P2p) InitHost(hostConfig HostConfig) (host.Host, error) {
 	if err != nil {
 		return nil, fmt.Errorf("new conn manager: %v", err)
 	}
+	resourceManager, err := newResourceManager(hostConfig.ResourceLimits, p.blockedResources)
+	if err != nil {
+		return nil, fmt.Errorf("new resource manager: %v", err)
+	}
 
 	listenAddrs := hostConfig.ListenAddrs
 	if len(listenAddrs) == 0 {
 		listenAddrs = findListenAddrs()
 	}
+	privateNetwork := len(hostConfig.PrivateNetworkKey) > 0
+	if privateNetwork {
+		listenAddrs = slices.DeleteFunc(slices.Clone(listenAddrs), isUDPAddr)
+		hostConfig.Libp2pOpts = append(hostConfig.Libp2pOpts, libp2p.PrivateNetwork(hostConfig.PrivateNetworkKey))
+	}
+	dhtProtocolPrefix := DHTProtocolPrefix
+	if hostConfig.DHTProtocolPrefix != "" {
+		dhtProtocolPrefix = hostConfig.DHTProtocolPrefix
+	}
 
 	if hostConfig.EnableAutoRelay {
 		hostConfig.Libp2pOpts = append(hostConfig.Libp2pOpts,
@@ -161,25 +241,40 @@ func (p *P2p) InitHost(hostConfig HostConfig) (host.Host, error) {
 				autorelay.WithBootDelay(RelayBootDelay),
 			))
 	}
+	if hostConfig.EnableNATPortMap {
+		hostConfig.Libp2pOpts = append(hostConfig.Libp2pOpts, libp2p.NATManager(p.newNATManager))
+	}
+	if hostConfig.EnableHolePunching {
+		hostConfig.Libp2pOpts = append(hostConfig.Libp2pOpts, libp2p.EnableHolePunching(holepunch.WithTracer(p.holePunchTracer)))
+	}
+	if hostConfig.EnableRelayService {
+		hostConfig.DHTOpts = append(hostConfig.DHTOpts, dht.Mode(dht.ModeServer))
+	}
 
 	p.config = hostConfig
+	if hostConfig.BootstrapList != nil {
+		hostConfig.BootstrapList.client = p.newOutboundHTTPClient(hostConfig.SocketControlFunc)
+	}
+	p.loadBootstrapCache(p.ctx)
 
-	transportOpts := p.buildTransportOpts(hostConfig.SocketControlFunc)
+	transportOpts := p.buildTransportOpts(hostConfig.SocketControlFunc, !privateNetwork)
+	transportOpts = append(transportOpts, p.buildWebTransportOpts(hostConfig.SocketControlFunc, !privateNetwork)...)
 
 	p2pHost, err := libp2p.New(
 		libp2p.Peerstore(hostConfig.Peerstore),
 		libp2p.Identity(privKey),
 		libp2p.UserAgent(hostConfig.UserAgent),
-		libp2p.BandwidthReporter(p.bandwidthCounter),
+		libp2p.BandwidthReporter(relayBandwidthReporter{Reporter: p.bandwidthCounter, relayed: p.relayedBandwidthCounter}),
 		libp2p.ConnectionManager(p.connManager),
+		libp2p.ResourceManager(resourceManager),
 		libp2p.SwarmOpts(swarm.WithDialRanker(p.getDialRanker())),
 		libp2p.ListenAddrs(listenAddrs...),
 		libp2p.ChainOptions(transportOpts...),
 		libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
 			opts := []dht.Option{
 				dht.Datastore(hostConfig.DHTDatastore),
-				dht.ProtocolPrefix(DHTProtocolPrefix),
-				dht.BootstrapPeers(p.bootstrapPeers...),
+				dht.ProtocolPrefix(dhtProtocolPrefix),
+				dht.BootstrapPeersFunc(p.bootstrapPeers.peers),
 			}
 			opts = append(opts, hostConfig.DHTOpts...)
 			kademliaDHT, err := dht.New(p.ctx, h, opts...)
@@ -192,25 +287,77 @@ func (p *P2p) InitHost(hostConfig HostConfig) (host.Host, error) {
 		libp2p.ChainOptions(hostConfig.Libp2pOpts...),
 	)
 	if err != nil {
+		_ = resourceManager.Close()
 		return nil, err
 	}
 	p.host = p2pHost
 	p.startedAt = time.Now()
+	p.restoreRoutingTable(p.ctx)
+
+	err = p.subscribeNATDeviceTypeChanges()
+	if err != nil {
+		return nil, fmt.Errorf("subscribe to nat device type changes: %v", err)
+	}
+
+	if hostConfig.EnableMDNS {
+		err = p.startMDNS()
+		if err != nil {
+			p.logger.Warnf("failed to start mdns discovery: %v", err)
+		}
+	}
+	p.UpdateRelayService()
 
 	return p2pHost, nil
 }
 
-func (p *P2p) buildTransportOpts(controlFunc func(network, address string, c syscall.RawConn) error) []libp2p.Option {
-	if controlFunc == nil {
-		return []libp2p.Option{
-			libp2p.Transport(libp2pquic.NewTransport),
-			libp2p.Transport(tcp.NewTCPTransport),
+// outboundDialer returns dialer for outbound TCP connections with socket marking and upstream proxy.
+func (p *P2p) outboundDialer(controlFunc func(network, address string, c syscall.RawConn) error) tcp.ContextDialer {
+	dialer := &net.Dialer{Control: controlFunc}
+	if p.config.UpstreamProxy != nil {
+		return p.config.UpstreamProxy.Dialer(dialer)
+	}
+	return dialer
+}
+
+// newOutboundHTTPClient returns HTTP client which dials with socket marking and via upstream proxy like TCP transport.
+// Without them it's http.DefaultClient which uses proxy from environment variables.
+func (p *P2p) newOutboundHTTPClient(controlFunc func(network, address string, c syscall.RawConn) error) *http.Client {
+	if controlFunc == nil && p.config.UpstreamProxy == nil {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = p.outboundDialer(controlFunc).DialContext
+	if p.config.UpstreamProxy != nil {
+		transport.Proxy = nil
+	}
+	return &http.Client{Transport: transport}
+}
+
+func (p *P2p) buildTransportOpts(controlFunc func(network, address string, c syscall.RawConn) error, enableQUIC bool) []libp2p.Option {
+	upstreamProxy := p.config.UpstreamProxy
+	if controlFunc == nil && upstreamProxy == nil {
+		var opts []libp2p.Option
+		if enableQUIC {
+			opts = append(opts, libp2p.Transport(libp2pquic.NewTransport))
 		}
+		return append(opts, libp2p.Transport(tcp.NewTCPTransport))
 	}
 
-	dialer := &net.Dialer{Control: controlFunc}
+	contextDialer := p.outboundDialer(controlFunc)
 	tcpDialer := func(raddr multiaddr.Multiaddr) (tcp.ContextDialer, error) {
-		return dialer, nil
+		return contextDialer, nil
+	}
+	if !enableQUIC {
+		return []libp2p.Option{
+			libp2p.Transport(tcp.NewTCPTransport, tcp.WithDialerForAddr(tcpDialer)),
+		}
+	}
+
+	if controlFunc == nil {
+		return []libp2p.Option{
+			libp2p.Transport(libp2pquic.NewTransport),
+			libp2p.Transport(tcp.NewTCPTransport, tcp.WithDialerForAddr(tcpDialer)),
+		}
 	}
 
 	listenUDP := func(network string, laddr *net.UDPAddr) (net.PacketConn, error) {
@@ -237,9 +384,31 @@ func (p *P2p) buildTransportOpts(controlFunc func(network, address string, c sys
 	}
 }
 
+// IsPrivateNetwork reports whether host was started with private network key.
+func (p *P2p) IsPrivateNetwork() bool {
+	return len(p.config.PrivateNetworkKey) > 0
+}
+
+// UpstreamProxy returns proxy used for outbound TCP connections or nil.
+func (p *P2p) UpstreamProxy() *UpstreamProxy {
+	return p.config.UpstreamProxy
+}
+
+// IsRelayServiceEnabled reports whether host was started with relay service for all known peers.
+func (p *P2p) IsRelayServiceEnabled() bool {
+	return p.config.EnableRelayService
+}
+
 func (p *P2p) Close() error {
+	p.persistPeers(context.Background())
 	p.ctxCancel()
+	p.stopRelayService()
+	var mdnsErr error
+	if p.mdns != nil {
+		mdnsErr = p.mdns.Close()
+	}
 	err := errors.Join(
+		mdnsErr,
 		p.dht.Close(),
 		p.host.Close(),
 	)
@@ -267,6 +436,15 @@ func (p *P2p) ConnectPeer(ctx context.Context, peerID peer.ID) error {
 		return nil
 	}
 
+	if len(p.host.Peerstore().Addrs(peerID)) > 0 {
+		dialCtx, dialCancel := context.WithTimeout(ctx, knownAddrsDialTimeout)
+		err := p.host.Connect(dialCtx, peer.AddrInfo{ID: peerID})
+		dialCancel()
+		if err == nil {
+			return nil
+		}
+	}
+
 	// FindPeer runs until peer is found in DHT or context is cancelled, so a timeout is mandatory
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -353,14 +531,17 @@ func (p *P2p) SubscribeConnectionEvents(onConnected, onDisconnected func(network
 func (p *P2p) Bootstrap() {
 	ctx, cancel := context.WithTimeout(p.ctx, 3*time.Second)
 	var wg sync.WaitGroup
-	successfulConnectionsCh := make(chan struct{}, len(p.bootstrapPeers))
+	bootstrapPeers := p.bootstrapPeers.peers()
+	successfulConnectionsCh := make(chan struct{}, len(bootstrapPeers))
 
 	p.logger.Debug("Start bootstrapping the DHT")
-	for _, peerAddr := range p.bootstrapPeers {
+	for _, peerAddr := range bootstrapPeers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := p.host.Connect(ctx, peerAddr); err != nil && !errors.Is(err, context.Canceled) {
+			err := p.host.Connect(ctx, peerAddr)
+			p.bootstrapPeers.reportConnect(peerAddr.ID, err)
+			if err != nil && !errors.Is(err, context.Canceled) {
 				p.logger.Warnf("Failed to connect to bootstrap node %s: %v", peerAddr.ID, err)
 			} else if err == nil {
 				p.logger.Infof("Connection established with bootstrap node: %s", peerAddr.ID)
@@ -373,7 +554,7 @@ func (p *P2p) Bootstrap() {
 		defer cancel()
 
 		// wait for at least 2 bootstrap nodes
-		for range min(2, len(p.bootstrapPeers)) {
+		for range min(2, len(bootstrapPeers)) {
 			select {
 			case <-ctx.Done():
 			case <-successfulConnectionsCh:
@@ -392,10 +573,14 @@ func (p *P2p) Bootstrap() {
 	}()
 }
 
-func (p *P2p) MaintainBackgroundConnections(ctx context.Context, interval time.Duration, knownPeersIdsFunc func() []peer.ID) {
+// MaintainBackgroundConnections keeps connections to known peers. knownPeersFunc returns known peers with their static addresses.
+func (p *P2p) MaintainBackgroundConnections(ctx context.Context, interval time.Duration, knownPeersFunc func() []peer.AddrInfo) {
 	const timeout = 5 * time.Second
 	const firstRetryDelay = 5 * time.Second
 
+	p.updateStaticAddrs(knownPeersFunc())
+	p.connectToKnownPeersFromPeerstore(ctx, knownPeersFunc())
+
 	// wait for bootstrapping
 	select {
 	case <-ctx.Done():
@@ -403,7 +588,7 @@ func (p *P2p) MaintainBackgroundConnections(ctx context.Context, interval time.D
 	case <-p.dhtBootstrapFinishedChan:
 	}
 
-	p.connectToKnownPeers(ctx, timeout, knownPeersIdsFunc())
+	p.connectToKnownPeers(ctx, timeout, knownPeersFunc())
 
 	// retry once after a short delay in case of network instability
 	select {
@@ -411,7 +596,7 @@ func (p *P2p) MaintainBackgroundConnections(ctx context.Context, interval time.D
 		return
 	case <-time.After(firstRetryDelay):
 	}
-	p.connectToKnownPeers(ctx, timeout, knownPeersIdsFunc())
+	p.connectToKnownPeers(ctx, timeout, knownPeersFunc())
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -423,17 +608,21 @@ func (p *P2p) MaintainBackgroundConnections(ctx context.Context, interval time.D
 		case <-ticker.C:
 		}
 
-		p.connectToKnownPeers(ctx, timeout, knownPeersIdsFunc())
+		p.connectToKnownPeers(ctx, timeout, knownPeersFunc())
+		p.persistPeers(ctx)
 		ticker.Reset(interval)
 	}
 }
 
-func (p *P2p) connectToKnownPeers(ctx context.Context, timeout time.Duration, peerIds []peer.ID) {
+func (p *P2p) connectToKnownPeers(ctx context.Context, timeout time.Duration, knownPeers []peer.AddrInfo) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	p.updateStaticAddrs(knownPeers)
+
 	var wg sync.WaitGroup
-	for _, peerID := range peerIds {
+	for _, knownPeer := range knownPeers {
+		peerID := knownPeer.ID
 		wg.Add(1)
 		p.ProtectPeer(peerID)
 		go func(peerID peer.ID) {
@@ -442,6 +631,7 @@ func (p *P2p) connectToKnownPeers(ctx context.Context, timeout time.Duration, pe
 			if err != nil {
 				return
 			}
+			p.observeKnownPeerConnected(peerID)
 
 			p.pingPeer(ctx, peerID)
 		}(peerID)
@@ -451,7 +641,7 @@ func (p *P2p) connectToKnownPeers(ctx context.Context, timeout time.Duration, pe
 	bootstrapsInfo := make(map[string]BootstrapPeerDebugInfo)
 	var mu sync.Mutex
 
-	for _, peerAddr := range p.bootstrapPeers {
+	for _, peerAddr := range p.bootstrapPeers.peers() {
 		wg.Add(1)
 		p.host.ConnManager().Protect(peerAddr.ID, protectedBootstrapPeerTag)
 
@@ -463,10 +653,15 @@ func (p *P2p) connectToKnownPeers(ctx context.Context, timeout time.Duration, pe
 			}
 
 			err := p.host.Connect(ctx, peerAddr)
+			p.bootstrapPeers.reportConnect(peerAddr.ID, err)
 			var info BootstrapPeerDebugInfo
 			if err != nil {
 				info.Error = err.Error()
 			}
+			source, health := p.bootstrapPeers.peerInfo(peerAddr.ID)
+			info.Source = source
+			info.LastConnected = health.LastConnected
+			info.Failures = health.Failures
 			info.Connections = p.peerAddressesString(peerAddr.ID)
 
 			if err == nil {
@@ -506,6 +701,10 @@ func (p *P2p) connsToPeer(peerID peer.ID) []network.Conn {
 	return p.host.Network().ConnsToPeer(peerID)
 }
 
+func (p *P2p) ConnsToPeer(peerID peer.ID) []network.Conn {
+	return p.connsToPeer(peerID)
+}
+
 func (p *P2p) peerAddressesString(peerID peer.ID) []string {
 	conns := p.connsToPeer(peerID)
 	addrs := make([]string, 0, len(conns))
@@ -519,7 +718,7 @@ func (p *P2p) getAutoRelayPeerSource() func(_ context.Context, numPeers int) <-c
 	// TODO: discover relays from non-default bootstrap peers
 
 	getAllCandidates := func() []peer.AddrInfo {
-		bootstrapPeers := slices.Clone(p.bootstrapPeers)
+		bootstrapPeers := p.bootstrapPeers.peers()
 		if p.host == nil {
 			return bootstrapPeers
 		}
@@ -529,11 +728,26 @@ func (p *P2p) getAutoRelayPeerSource() func(_ context.Context, numPeers int) <-c
 			return !p.IsConnected(info.ID) || ps.LatencyEWMA(info.ID) == 0
 		})
 
-		slices.SortFunc(bootstrapPeers, func(a, b peer.AddrInfo) int {
+		byLatency := func(a, b peer.AddrInfo) int {
 			return int(ps.LatencyEWMA(a.ID) - ps.LatencyEWMA(b.ID))
+		}
+		slices.SortFunc(bootstrapPeers, byLatency)
+
+		// known peers with relay service go first, traffic through them doesn't depend on community relays
+		var trustedRelays []peer.AddrInfo
+		if p.config.TrustedRelays != nil {
+			for _, peerID := range p.config.TrustedRelays() {
+				if p.IsConnected(peerID) {
+					trustedRelays = append(trustedRelays, peer.AddrInfo{ID: peerID, Addrs: ps.Addrs(peerID)})
+				}
+			}
+		}
+		slices.SortFunc(trustedRelays, byLatency)
+		bootstrapPeers = slices.DeleteFunc(bootstrapPeers, func(info peer.AddrInfo) bool {
+			return slices.ContainsFunc(trustedRelays, func(relay peer.AddrInfo) bool { return relay.ID == info.ID })
 		})
 
-		return bootstrapPeers
+		return append(trustedRelays, bootstrapPeers...)
 	}
 
 	var lastAddrInfos []peer.AddrInfo
@@ -608,6 +822,18 @@ func (p *P2p) getDialRanker() network.DialRanker {
 			}
 		}
 
+		// peer is likely in the same LAN, give direct connection a chance before using relays
+		if slices.ContainsFunc(addrs, isLANAddr) {
+			for i, addrDelay := range defaultDelays {
+				if isLANAddr(addrDelay.Addr) {
+					defaultDelays[i].Delay = 0
+				} else if isRelayAddr(addrDelay.Addr) {
+					defaultDelays[i].Delay += lanRelayDelay
+				}
+			}
+		}
+		delayWebAddrs(defaultDelays)
+
 		return defaultDelays
 	}
 }
@@ -629,6 +855,12 @@ func findListenAddrs() []multiaddr.Multiaddr {
 	return DefaultListenAddrs()
 }
 
+// isUDPAddr reports whether addr is QUIC or other UDP based transport address, they don't support private networks.
+func isUDPAddr(addr multiaddr.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(multiaddr.P_UDP)
+	return err == nil
+}
+
 func UnicastListenAddrs() []multiaddr.Multiaddr {
 	return []multiaddr.Multiaddr{
 		multiaddr.StringCast("/ip4/0.0.0.0/tcp/0"),
@@ -0,0 +1,162 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/anywherelan/awl/metrics"
+)
+
+const (
+	// knownPeerAddrTTL keeps addresses of known peers in peerstore after disconnect or restart,
+	// so we can dial them directly without waiting for DHT.
+	knownPeerAddrTTL = 7 * 24 * time.Hour
+	// knownAddrsDialTimeout limits dialing addresses from peerstore before falling back to DHT lookup.
+	knownAddrsDialTimeout = 3 * time.Second
+)
+
+var routingTableKey = ds.NewKey("/awl/routing_table")
+
+// persistPeers extends TTL of connected known peers addresses and saves DHT routing table and bootstrap peers to datastore.
+func (p *P2p) persistPeers(ctx context.Context) {
+	ps := p.host.Peerstore()
+	for _, peerID := range p.host.Network().Peers() {
+		if !p.connManager.IsProtected(peerID, protectedPeerTag) {
+			continue
+		}
+		addrs := slices.DeleteFunc(ps.Addrs(peerID), p.isStaticAddr(peerID))
+		if len(addrs) > 0 {
+			ps.SetAddrs(peerID, addrs, knownPeerAddrTTL)
+		}
+	}
+
+	if p.dht == nil || p.config.DHTDatastore == nil {
+		return
+	}
+	p.persistBootstrapCache(ctx)
+	data, err := json.Marshal(p.dht.RoutingTable().ListPeers())
+	if err != nil {
+		p.logger.Errorf("marshal routing table: %v", err)
+		return
+	}
+	err = p.config.DHTDatastore.Put(ctx, routingTableKey, data)
+	if err != nil {
+		p.logger.Errorf("save routing table: %v", err)
+	}
+}
+
+// restoreRoutingTable adds peers from the previous run to DHT routing table.
+// Only peers with addresses in peerstore are added, unreachable ones are evicted by DHT later.
+func (p *P2p) restoreRoutingTable(ctx context.Context) {
+	if p.config.DHTDatastore == nil {
+		return
+	}
+	data, err := p.config.DHTDatastore.Get(ctx, routingTableKey)
+	if errors.Is(err, ds.ErrNotFound) {
+		return
+	} else if err != nil {
+		p.logger.Errorf("load routing table: %v", err)
+		return
+	}
+	var peerIDs []peer.ID
+	err = json.Unmarshal(data, &peerIDs)
+	if err != nil {
+		p.logger.Errorf("unmarshal routing table: %v", err)
+		return
+	}
+
+	restored := 0
+	for _, peerID := range peerIDs {
+		if peerID == p.host.ID() || len(p.host.Peerstore().Addrs(peerID)) == 0 {
+			continue
+		}
+		added, _ := p.dht.RoutingTable().TryAddPeer(peerID, false, true)
+		if added {
+			restored++
+		}
+	}
+	p.logger.Infof("restored %d of %d peers to DHT routing table", restored, len(peerIDs))
+}
+
+// connectToKnownPeersFromPeerstore dials known peers which have addresses in peerstore, it doesn't wait for DHT bootstrap.
+func (p *P2p) connectToKnownPeersFromPeerstore(ctx context.Context, knownPeers []peer.AddrInfo) {
+	ctx, cancel := context.WithTimeout(ctx, knownAddrsDialTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, knownPeer := range knownPeers {
+		peerID := knownPeer.ID
+		if p.IsConnected(peerID) || len(p.host.Peerstore().Addrs(peerID)) == 0 {
+			continue
+		}
+		wg.Add(1)
+		p.ProtectPeer(peerID)
+		go func() {
+			defer wg.Done()
+			err := p.host.Connect(ctx, peer.AddrInfo{ID: peerID})
+			if err == nil {
+				p.observeKnownPeerConnected(peerID)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// observeKnownPeerConnected records time to the first connection with known peer since start.
+func (p *P2p) observeKnownPeerConnected(peerID peer.ID) {
+	if _, loaded := p.connectedKnownPeers.LoadOrStore(peerID, struct{}{}); loaded {
+		return
+	}
+	elapsed := time.Since(p.startedAt)
+	metrics.P2PKnownPeerStartupConnectSeconds.Observe(elapsed.Seconds())
+	p.logger.Infof("connected to known peer %s in %s after start", peerID, elapsed.Round(time.Millisecond))
+}
+
+// updateStaticAddrs adds user provided addresses of known peers to peerstore with permanent TTL
+// and removes addresses which were deleted from settings since the previous call.
+func (p *P2p) updateStaticAddrs(knownPeers []peer.AddrInfo) {
+	ps := p.host.Peerstore()
+	p.staticAddrsLock.Lock()
+	defer p.staticAddrsLock.Unlock()
+
+	previous := p.staticAddrs
+	p.staticAddrs = make(map[peer.ID][]multiaddr.Multiaddr, len(knownPeers))
+	for _, knownPeer := range knownPeers {
+		for _, addr := range previous[knownPeer.ID] {
+			if !slices.ContainsFunc(knownPeer.Addrs, addr.Equal) {
+				ps.SetAddr(knownPeer.ID, addr, 0)
+			}
+		}
+		delete(previous, knownPeer.ID)
+		if len(knownPeer.Addrs) == 0 {
+			continue
+		}
+		ps.AddAddrs(knownPeer.ID, knownPeer.Addrs, peerstore.PermanentAddrTTL)
+		p.staticAddrs[knownPeer.ID] = knownPeer.Addrs
+	}
+	// peers removed from known
+	for peerID, addrs := range previous {
+		for _, addr := range addrs {
+			ps.SetAddr(peerID, addr, 0)
+		}
+	}
+}
+
+func (p *P2p) isStaticAddr(peerID peer.ID) func(multiaddr.Multiaddr) bool {
+	p.staticAddrsLock.Lock()
+	staticAddrs := p.staticAddrs[peerID]
+	p.staticAddrsLock.Unlock()
+
+	return func(addr multiaddr.Multiaddr) bool {
+		return slices.ContainsFunc(staticAddrs, addr.Equal)
+	}
+}
@@ -0,0 +1,43 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP2p_updateStaticAddrs(t *testing.T) {
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer h.Close()
+	p := NewP2p(context.Background())
+	p.host = h
+
+	peer1, err := peer.Decode("12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn")
+	require.NoError(t, err)
+	peer2, err := peer.Decode("12D3KooWKF1xbKxWTXXYaW6W5qCTqAHf8r5PCadAGb2bQHKqxWrv")
+	require.NoError(t, err)
+	addr1 := mustNewMultiaddr("/ip4/192.168.1.10/udp/4363/quic-v1")
+	addr2 := mustNewMultiaddr("/ip4/192.168.1.10/tcp/4363")
+	ps := h.Peerstore()
+
+	p.updateStaticAddrs([]peer.AddrInfo{
+		{ID: peer1, Addrs: []ma.Multiaddr{addr1, addr2}},
+		{ID: peer2, Addrs: []ma.Multiaddr{addr1}},
+	})
+	require.ElementsMatch(t, []ma.Multiaddr{addr1, addr2}, ps.Addrs(peer1))
+	require.ElementsMatch(t, []ma.Multiaddr{addr1}, ps.Addrs(peer2))
+	require.True(t, p.isStaticAddr(peer1)(addr2))
+
+	// addr2 removed from settings, peer2 removed from known peers
+	p.updateStaticAddrs([]peer.AddrInfo{
+		{ID: peer1, Addrs: []ma.Multiaddr{addr1}},
+	})
+	require.ElementsMatch(t, []ma.Multiaddr{addr1}, ps.Addrs(peer1))
+	require.Empty(t, ps.Addrs(peer2))
+	require.False(t, p.isStaticAddr(peer1)(addr2))
+}
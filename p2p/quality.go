@@ -0,0 +1,253 @@
+package p2p
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	"github.com/anywherelan/awl/metrics"
+	"github.com/anywherelan/awl/ringbuffer"
+)
+
+const (
+	ConnectionTypeDirect = "direct"
+	ConnectionTypeRelay  = "relay"
+
+	qualityProbesPerRound = 5
+	qualityProbeSpacing   = 200 * time.Millisecond
+	// qualityProbeTimeout - probes without reply during this time are counted as lost.
+	qualityProbeTimeout = 2 * time.Second
+
+	qualityHistorySize            = 120
+	qualityConnectionChangesLimit = 20
+)
+
+// ConnectionQualitySample is the result of one probing round.
+type ConnectionQualitySample struct {
+	Time time.Time
+	// ConnectionType is the type of connection used during probing.
+	ConnectionType string        `enums:"direct,relay"`
+	RTT            time.Duration `swaggertype:"primitive,integer"`
+	RTTMin         time.Duration `swaggertype:"primitive,integer"`
+	RTTMax         time.Duration `swaggertype:"primitive,integer"`
+	// Jitter is the mean difference between consecutive RTTs.
+	Jitter      time.Duration `swaggertype:"primitive,integer"`
+	LossPercent float64
+}
+
+type ConnectionTypeChange struct {
+	Time time.Time
+	From string `enums:"direct,relay"`
+	To   string `enums:"direct,relay"`
+}
+
+type ConnectionQuality struct {
+	// History contains the last probing rounds, oldest first.
+	History []ConnectionQualitySample
+	// ConnectionTypeChanges contains the last switches between direct and relayed connection, oldest first.
+	ConnectionTypeChanges []ConnectionTypeChange
+}
+
+type peerQuality struct {
+	history     *ringbuffer.Ring[ConnectionQualitySample]
+	typeChanges *ringbuffer.Ring[ConnectionTypeChange]
+}
+
+// connectionQuality keeps connection quality history for known peers.
+type connectionQuality struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*peerQuality
+}
+
+func newConnectionQuality() *connectionQuality {
+	return &connectionQuality{
+		peers: make(map[peer.ID]*peerQuality),
+	}
+}
+
+func (q *connectionQuality) record(peerID peer.ID, sample ConnectionQualitySample) {
+	q.mu.Lock()
+	pq, exists := q.peers[peerID]
+	if !exists {
+		pq = &peerQuality{
+			history:     ringbuffer.NewRing[ConnectionQualitySample](qualityHistorySize),
+			typeChanges: ringbuffer.NewRing[ConnectionTypeChange](qualityConnectionChangesLimit),
+		}
+		q.peers[peerID] = pq
+	}
+	q.mu.Unlock()
+
+	last, ok := pq.history.Last()
+	if ok && last.ConnectionType != sample.ConnectionType {
+		pq.typeChanges.Push(ConnectionTypeChange{Time: sample.Time, From: last.ConnectionType, To: sample.ConnectionType})
+		metrics.P2PPeerConnectionTypeChangesTotal.WithLabelValues(peerID.String(), sample.ConnectionType).Inc()
+	}
+	pq.history.Push(sample)
+}
+
+func (q *connectionQuality) get(peerID peer.ID) ConnectionQuality {
+	q.mu.Lock()
+	pq, exists := q.peers[peerID]
+	q.mu.Unlock()
+	if !exists {
+		return ConnectionQuality{
+			History:               []ConnectionQualitySample{},
+			ConnectionTypeChanges: []ConnectionTypeChange{},
+		}
+	}
+
+	return ConnectionQuality{
+		History:               pq.history.Items(),
+		ConnectionTypeChanges: pq.typeChanges.Items(),
+	}
+}
+
+// retain drops history of peers which are not known anymore.
+func (q *connectionQuality) retain(peerIDs []peer.ID) {
+	keep := make(map[peer.ID]struct{}, len(peerIDs))
+	for _, peerID := range peerIDs {
+		keep[peerID] = struct{}{}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for peerID := range q.peers {
+		if _, ok := keep[peerID]; !ok {
+			delete(q.peers, peerID)
+			deleteQualityMetrics(peerID)
+		}
+	}
+}
+
+// deleteQualityMetrics removes per-peer series, so removed and migrated peers don't stay in metrics forever.
+func deleteQualityMetrics(peerID peer.ID) {
+	id := peerID.String()
+	for _, result := range []string{"success", "lost"} {
+		metrics.P2PPeerProbesTotal.DeleteLabelValues(id, result)
+	}
+	for _, connType := range []string{ConnectionTypeDirect, ConnectionTypeRelay} {
+		metrics.P2PPeerProbeRTTSeconds.DeleteLabelValues(id, connType)
+		metrics.P2PPeerProbeJitterSeconds.DeleteLabelValues(id, connType)
+		metrics.P2PPeerConnectionTypeChangesTotal.DeleteLabelValues(id, connType)
+	}
+}
+
+// RetainConnectionQuality drops connection quality history and metrics of peers which are not known anymore.
+func (p *P2p) RetainConnectionQuality(peerIDs []peer.ID) {
+	p.connectionQuality.retain(peerIDs)
+}
+
+// PeerConnectionQuality returns connection quality history collected by MonitorConnectionQuality.
+func (p *P2p) PeerConnectionQuality(peerID peer.ID) ConnectionQuality {
+	return p.connectionQuality.get(peerID)
+}
+
+// MonitorConnectionQuality periodically probes connected known peers with ping protocol
+// and records RTT, jitter, loss and connection type. Ping results also update peerstore latency.
+func (p *P2p) MonitorConnectionQuality(ctx context.Context, interval time.Duration, knownPeersIdsFunc func() []peer.ID) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		peerIDs := knownPeersIdsFunc()
+		p.RetainConnectionQuality(peerIDs)
+
+		var wg sync.WaitGroup
+		for _, peerID := range peerIDs {
+			if !p.IsConnected(peerID) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sample, ok := p.probeConnectionQuality(ctx, peerID)
+				if ok {
+					p.connectionQuality.record(peerID, sample)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func (p *P2p) probeConnectionQuality(ctx context.Context, peerID peer.ID) (ConnectionQualitySample, bool) {
+	connType := p.peerConnectionType(peerID)
+	rtts := make([]time.Duration, 0, qualityProbesPerRound)
+	for i := range qualityProbesPerRound {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ConnectionQualitySample{}, false
+			case <-time.After(qualityProbeSpacing):
+			}
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, qualityProbeTimeout)
+		res := <-ping.Ping(probeCtx, p.host, peerID)
+		cancel()
+		if ctx.Err() != nil {
+			return ConnectionQualitySample{}, false
+		}
+		if res.Error != nil {
+			metrics.P2PPeerProbesTotal.WithLabelValues(peerID.String(), "lost").Inc()
+			continue
+		}
+		metrics.P2PPeerProbesTotal.WithLabelValues(peerID.String(), "success").Inc()
+		metrics.P2PPeerProbeRTTSeconds.WithLabelValues(peerID.String(), connType).Observe(res.RTT.Seconds())
+		rtts = append(rtts, res.RTT)
+	}
+
+	sample := newConnectionQualitySample(rtts, qualityProbesPerRound)
+	sample.Time = time.Now()
+	sample.ConnectionType = connType
+	if len(rtts) > 1 {
+		metrics.P2PPeerProbeJitterSeconds.WithLabelValues(peerID.String(), connType).Observe(sample.Jitter.Seconds())
+	}
+
+	return sample, true
+}
+
+func newConnectionQualitySample(rtts []time.Duration, sent int) ConnectionQualitySample {
+	var sample ConnectionQualitySample
+	if sent > 0 {
+		sample.LossPercent = float64(sent-len(rtts)) / float64(sent) * 100
+	}
+	if len(rtts) == 0 {
+		return sample
+	}
+
+	var sum, jitterSum time.Duration
+	sample.RTTMin = rtts[0]
+	for i, rtt := range rtts {
+		sum += rtt
+		sample.RTTMin = min(sample.RTTMin, rtt)
+		sample.RTTMax = max(sample.RTTMax, rtt)
+		if i > 0 {
+			jitterSum += (rtt - rtts[i-1]).Abs()
+		}
+	}
+	sample.RTT = sum / time.Duration(len(rtts))
+	if len(rtts) > 1 {
+		sample.Jitter = jitterSum / time.Duration(len(rtts)-1)
+	}
+
+	return sample
+}
+
+// peerConnectionType reports ConnectionTypeDirect if there is at least one direct connection to peer.
+func (p *P2p) peerConnectionType(peerID peer.ID) string {
+	if slices.ContainsFunc(p.connsToPeer(peerID), isDirectConn) {
+		return ConnectionTypeDirect
+	}
+	return ConnectionTypeRelay
+}
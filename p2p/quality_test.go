@@ -0,0 +1,58 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anywherelan/awl/metrics"
+)
+
+func Test_newConnectionQualitySample(t *testing.T) {
+	ms := time.Millisecond
+	sample := newConnectionQualitySample([]time.Duration{10 * ms, 20 * ms, 15 * ms, 15 * ms}, 5)
+	require.Equal(t, 15*ms, sample.RTT)
+	require.Equal(t, 10*ms, sample.RTTMin)
+	require.Equal(t, 20*ms, sample.RTTMax)
+	// |20-10| + |15-20| + |15-15| = 15ms over 3 pairs
+	require.Equal(t, 5*ms, sample.Jitter)
+	require.InDelta(t, 20.0, sample.LossPercent, 0.001)
+
+	sample = newConnectionQualitySample(nil, 5)
+	require.InDelta(t, 100.0, sample.LossPercent, 0.001)
+	require.Zero(t, sample.RTT)
+}
+
+func Test_connectionQuality(t *testing.T) {
+	q := newConnectionQuality()
+	peer1, peer2 := peer.ID("peer1"), peer.ID("peer2")
+	start := time.Now()
+
+	types := []string{ConnectionTypeRelay, ConnectionTypeRelay, ConnectionTypeDirect, ConnectionTypeRelay}
+	for i, connType := range types {
+		q.record(peer1, ConnectionQualitySample{Time: start.Add(time.Duration(i) * time.Second), ConnectionType: connType})
+	}
+	for range qualityHistorySize + 1 {
+		q.record(peer2, ConnectionQualitySample{ConnectionType: ConnectionTypeDirect})
+	}
+
+	quality := q.get(peer1)
+	require.Len(t, quality.History, len(types))
+	require.Equal(t, []ConnectionTypeChange{
+		{Time: start.Add(2 * time.Second), From: ConnectionTypeRelay, To: ConnectionTypeDirect},
+		{Time: start.Add(3 * time.Second), From: ConnectionTypeDirect, To: ConnectionTypeRelay},
+	}, quality.ConnectionTypeChanges)
+	require.Len(t, q.get(peer2).History, qualityHistorySize)
+	require.Empty(t, q.get(peer2).ConnectionTypeChanges)
+
+	metrics.P2PPeerProbesTotal.WithLabelValues(peer1.String(), "success").Inc()
+	metrics.P2PPeerConnectionTypeChangesTotal.WithLabelValues(peer2.String(), ConnectionTypeDirect).Inc()
+	q.retain([]peer.ID{peer2})
+	require.False(t, metrics.P2PPeerProbesTotal.DeleteLabelValues(peer1.String(), "success"))
+	require.True(t, metrics.P2PPeerConnectionTypeChangesTotal.DeleteLabelValues(peer2.String(), ConnectionTypeDirect))
+	require.NotNil(t, q.get(peer1).History)
+	require.Empty(t, q.get(peer1).History)
+	require.Len(t, q.get(peer2).History, qualityHistorySize)
+}
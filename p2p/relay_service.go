@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/relaysvc"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/anywherelan/awl/metrics"
+)
+
+// relayACL allows only known peers which are allowed by HostConfig.IsRelayAllowedForPeer to use relay service.
+// Reservations are made only by allowed peers, so destination of relayed connection is always known.
+type relayACL struct {
+	p *P2p
+}
+
+func (a relayACL) AllowReserve(peerID peer.ID, _ multiaddr.Multiaddr) bool {
+	allowed := a.isAllowed(peerID)
+	metrics.P2PRelayServiceRequestsTotal.WithLabelValues("reservation", relayResultLabel(allowed)).Inc()
+	if !allowed {
+		a.p.logger.Debugf("relay service: denied reservation for peer %s", peerID)
+	}
+	return allowed
+}
+
+func (a relayACL) AllowConnect(src peer.ID, _ multiaddr.Multiaddr, dest peer.ID) bool {
+	allowed := a.isAllowed(src) && a.isAllowed(dest)
+	metrics.P2PRelayServiceRequestsTotal.WithLabelValues("connect", relayResultLabel(allowed)).Inc()
+	if !allowed {
+		a.p.logger.Debugf("relay service: denied relayed connection from %s to %s", src, dest)
+	}
+	return allowed
+}
+
+func (a relayACL) isAllowed(peerID peer.ID) bool {
+	return a.p.config.IsRelayAllowedForPeer != nil && a.p.config.IsRelayAllowedForPeer(peerID)
+}
+
+func relayResultLabel(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func (p *P2p) relayServiceOptions(resources relayv2.Resources) []relayv2.Option {
+	return []relayv2.Option{
+		relayv2.WithResources(resources),
+		relayv2.WithACL(relayACL{p: p}),
+		relayv2.WithMetricsTracer(relayv2.NewMetricsTracer()),
+	}
+}
+
+// UpdateRelayService starts relay service when HostConfig.IsRelayServiceUsed reports that some peers may use it
+// and stops it otherwise. Running relay service serves only when host is publicly reachable.
+// It should be called when known peers or their permissions change.
+func (p *P2p) UpdateRelayService() {
+	if p.config.IsRelayAllowedForPeer == nil || p.config.IsRelayServiceUsed == nil {
+		return
+	}
+	p.relayManagerLock.Lock()
+	defer p.relayManagerLock.Unlock()
+	used := p.config.IsRelayServiceUsed()
+	if used == (p.relayManager != nil) {
+		return
+	}
+	if !used {
+		_ = p.relayManager.Close()
+		p.relayManager = nil
+		p.logger.Info("relay service is stopped: no known peers are allowed to use it")
+		return
+	}
+	p.relayManager = relaysvc.NewRelayManager(p.host, p.relayServiceOptions(p.config.RelayServiceResources)...)
+	p.logger.Info("relay service is started for allowed known peers")
+}
+
+func (p *P2p) stopRelayService() {
+	p.relayManagerLock.Lock()
+	defer p.relayManagerLock.Unlock()
+	if p.relayManager != nil {
+		_ = p.relayManager.Close()
+		p.relayManager = nil
+	}
+}
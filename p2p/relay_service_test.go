@@ -0,0 +1,64 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_relayACL(t *testing.T) {
+	allowedPeer, err := peer.Decode("12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn")
+	require.NoError(t, err)
+	otherPeer, err := peer.Decode("12D3KooWKF1xbKxWTXXYaW6W5qCTqAHf8r5PCadAGb2bQHKqxWrv")
+	require.NoError(t, err)
+
+	p := NewP2p(context.Background())
+	defer p.ctxCancel()
+	acl := relayACL{p: p}
+	require.False(t, acl.AllowReserve(allowedPeer, nil))
+
+	p.config.IsRelayAllowedForPeer = func(id peer.ID) bool {
+		return id == allowedPeer
+	}
+	require.True(t, acl.AllowReserve(allowedPeer, nil))
+	require.False(t, acl.AllowReserve(otherPeer, nil))
+	require.True(t, acl.AllowConnect(allowedPeer, nil, allowedPeer))
+	require.False(t, acl.AllowConnect(otherPeer, nil, allowedPeer))
+	require.False(t, acl.AllowConnect(allowedPeer, nil, otherPeer))
+}
+
+func TestP2p_getAutoRelayPeerSource_PrefersTrustedRelays(t *testing.T) {
+	newHost := func() host.Host {
+		h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = h.Close() })
+		return h
+	}
+	h := newHost()
+	trustedRelay := newHost()
+	bootstrapPeer := newHost()
+	for _, other := range []host.Host{trustedRelay, bootstrapPeer} {
+		err := h.Connect(context.Background(), peer.AddrInfo{ID: other.ID(), Addrs: other.Addrs()})
+		require.NoError(t, err)
+	}
+	h.Peerstore().RecordLatency(bootstrapPeer.ID(), time.Millisecond)
+
+	p := NewP2p(context.Background())
+	defer p.ctxCancel()
+	p.host = h
+	p.bootstrapPeers = newBootstrapPeerSet([]peer.AddrInfo{{ID: bootstrapPeer.ID()}, {ID: trustedRelay.ID()}})
+	p.config.TrustedRelays = func() []peer.ID {
+		return []peer.ID{trustedRelay.ID()}
+	}
+
+	var candidates []peer.ID
+	for info := range p.getAutoRelayPeerSource()(context.Background(), 3) {
+		candidates = append(candidates, info.ID)
+	}
+	require.Equal(t, []peer.ID{trustedRelay.ID(), bootstrapPeer.ID()}, candidates)
+}
@@ -0,0 +1,214 @@
+package p2p
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+
+	"github.com/anywherelan/awl/metrics"
+)
+
+const (
+	BlockedResourceStream = "stream"
+	BlockedResourceConn   = "conn"
+	BlockedResourceMemory = "memory"
+)
+
+type ResourceManagerDebugInfo struct {
+	// Enabled is false when resource limits are disabled in config.
+	Enabled   bool
+	System    ResourceScopeDebugInfo
+	Transient ResourceScopeDebugInfo
+	// Protocols are keyed by protocol ID.
+	Protocols map[string]ResourceScopeDebugInfo
+	// Blocked are numbers of blocked resource requests since start.
+	Blocked []BlockedResourcesInfo
+}
+
+type ResourceScopeDebugInfo struct {
+	Limits ResourceLimitsInfo
+	// PeerLimits are limits of the protocol with one peer.
+	PeerLimits *ResourceLimitsInfo `json:",omitempty"`
+	Usage      network.ScopeStat
+}
+
+type ResourceLimitsInfo struct {
+	Streams         int
+	StreamsInbound  int
+	StreamsOutbound int
+	Conns           int
+	ConnsInbound    int
+	ConnsOutbound   int
+	FD              int
+	Memory          int64
+}
+
+type BlockedResourcesInfo struct {
+	// Scope is a class of resource scope like system, transient, peer or protocol:/awl/tunnel/.
+	Scope    string
+	Resource string `enums:"stream,conn,memory"`
+	Count    int64
+}
+
+func newResourceManager(limits *rcmgr.ConcreteLimitConfig, reporter *blockedResourcesReporter) (network.ResourceManager, error) {
+	if limits == nil {
+		return rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(rcmgr.InfiniteLimits))
+	}
+	return rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(*limits), rcmgr.WithTraceReporter(reporter))
+}
+
+// ResourceManagerDebugInfo returns limits and usage of system, transient and protocol scopes.
+func (p *P2p) ResourceManagerDebugInfo() ResourceManagerDebugInfo {
+	limits := p.config.ResourceLimits
+	info := ResourceManagerDebugInfo{
+		Enabled:   limits != nil,
+		Protocols: make(map[string]ResourceScopeDebugInfo),
+		Blocked:   p.blockedResources.stats(),
+	}
+	if limits == nil {
+		return info
+	}
+
+	limiter := rcmgr.NewFixedLimiter(*limits)
+	resourceManager := p.host.Network().ResourceManager()
+	info.System.Limits = newResourceLimitsInfo(limiter.GetSystemLimits())
+	_ = resourceManager.ViewSystem(func(scope network.ResourceScope) error {
+		info.System.Usage = scope.Stat()
+		return nil
+	})
+	info.Transient.Limits = newResourceLimitsInfo(limiter.GetTransientLimits())
+	_ = resourceManager.ViewTransient(func(scope network.ResourceScope) error {
+		info.Transient.Usage = scope.Stat()
+		return nil
+	})
+	for proto := range limits.ToPartialLimitConfig().Protocol {
+		peerLimits := newResourceLimitsInfo(limiter.GetProtocolPeerLimits(proto))
+		scopeInfo := ResourceScopeDebugInfo{
+			Limits:     newResourceLimitsInfo(limiter.GetProtocolLimits(proto)),
+			PeerLimits: &peerLimits,
+		}
+		_ = resourceManager.ViewProtocol(proto, func(scope network.ProtocolScope) error {
+			scopeInfo.Usage = scope.Stat()
+			return nil
+		})
+		info.Protocols[string(proto)] = scopeInfo
+	}
+
+	return info
+}
+
+func newResourceLimitsInfo(limit rcmgr.Limit) ResourceLimitsInfo {
+	return ResourceLimitsInfo{
+		Streams:         limit.GetStreamTotalLimit(),
+		StreamsInbound:  limit.GetStreamLimit(network.DirInbound),
+		StreamsOutbound: limit.GetStreamLimit(network.DirOutbound),
+		Conns:           limit.GetConnTotalLimit(),
+		ConnsInbound:    limit.GetConnLimit(network.DirInbound),
+		ConnsOutbound:   limit.GetConnLimit(network.DirOutbound),
+		FD:              limit.GetFDLimit(),
+		Memory:          limit.GetMemoryLimit(),
+	}
+}
+
+// blockedResourcesReporter counts resource requests blocked by resource manager.
+type blockedResourcesReporter struct {
+	lock    sync.Mutex
+	blocked map[blockedResourcesKey]int64
+}
+
+type blockedResourcesKey struct {
+	scope    string
+	resource string
+}
+
+func newBlockedResourcesReporter() *blockedResourcesReporter {
+	return &blockedResourcesReporter{
+		blocked: make(map[blockedResourcesKey]int64),
+	}
+}
+
+func (r *blockedResourcesReporter) ConsumeEvent(evt rcmgr.TraceEvt) {
+	var resource string
+	switch evt.Type {
+	case rcmgr.TraceBlockAddStreamEvt:
+		resource = BlockedResourceStream
+	case rcmgr.TraceBlockAddConnEvt:
+		resource = BlockedResourceConn
+	case rcmgr.TraceBlockReserveMemoryEvt:
+		resource = BlockedResourceMemory
+	default:
+		return
+	}
+	key := blockedResourcesKey{scope: resourceScopeClass(evt.Name), resource: resource}
+	metrics.P2PResourceManagerBlockedTotal.WithLabelValues(key.scope, key.resource).Inc()
+
+	r.lock.Lock()
+	r.blocked[key]++
+	r.lock.Unlock()
+}
+
+func (r *blockedResourcesReporter) stats() []BlockedResourcesInfo {
+	r.lock.Lock()
+	result := make([]BlockedResourcesInfo, 0, len(r.blocked))
+	for key, count := range r.blocked {
+		result = append(result, BlockedResourcesInfo{Scope: key.scope, Resource: key.resource, Count: count})
+	}
+	r.lock.Unlock()
+
+	slices.SortFunc(result, func(a, b BlockedResourcesInfo) int {
+		return cmp.Or(cmp.Compare(a.Scope, b.Scope), cmp.Compare(a.Resource, b.Resource))
+	})
+	return result
+}
+
+// resourceScopeClass removes peer IDs and connection/stream numbers from scope name to keep metrics cardinality low.
+func resourceScopeClass(name string) string {
+	name, _, _ = strings.Cut(name, ".span-")
+	switch {
+	case strings.HasPrefix(name, "conn-"):
+		return "conn"
+	case strings.HasPrefix(name, "stream-"):
+		return "stream"
+	case strings.HasPrefix(name, "peer:"):
+		return "peer"
+	}
+	if scope, _, ok := strings.Cut(name, ".peer:"); ok {
+		return scope + ".peer"
+	}
+	return name
+}
+
+// ProtocolLimits are stream limits of the protocol with all peers and with one peer.
+type ProtocolLimits struct {
+	Streams        int
+	StreamsPerPeer int
+}
+
+// ScaledResourceLimits returns default libp2p limits with protocol limits, scaled to memory and file descriptors.
+// Zero memory and numFD mean automatic scaling.
+func ScaledResourceLimits(memory int64, numFD int, protocols map[protocol.ID]ProtocolLimits) rcmgr.ConcreteLimitConfig {
+	scalingLimits := rcmgr.DefaultLimits
+	libp2p.SetDefaultServiceLimits(&scalingLimits)
+	for proto, limits := range protocols {
+		base, inc := scalingLimits.ProtocolBaseLimit, scalingLimits.ProtocolLimitIncrease
+		base.Streams, base.StreamsInbound, base.StreamsOutbound = limits.Streams, limits.Streams, limits.Streams
+		inc.Streams, inc.StreamsInbound, inc.StreamsOutbound = 0, 0, 0
+		scalingLimits.AddProtocolLimit(proto, base, inc)
+
+		base, inc = scalingLimits.ProtocolPeerBaseLimit, scalingLimits.ProtocolPeerLimitIncrease
+		base.Streams, base.StreamsInbound, base.StreamsOutbound = limits.StreamsPerPeer, limits.StreamsPerPeer, limits.StreamsPerPeer
+		inc.Streams, inc.StreamsInbound, inc.StreamsOutbound = 0, 0, 0
+		scalingLimits.AddProtocolPeerLimit(proto, base, inc)
+	}
+
+	if memory == 0 && numFD == 0 {
+		return scalingLimits.AutoScale()
+	}
+	return scalingLimits.Scale(memory, numFD)
+}
@@ -0,0 +1,61 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resourceScopeClass(t *testing.T) {
+	const peerID = "12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn"
+	for name, expected := range map[string]string{
+		"system":                                 "system",
+		"transient":                              "transient",
+		"peer:" + peerID:                         "peer",
+		"conn-42":                                "conn",
+		"stream-7":                               "stream",
+		"conn-3.span-1":                          "conn",
+		"protocol:/awl/tunnel/":                  "protocol:/awl/tunnel/",
+		"protocol:/awl/auth/.peer:" + peerID:     "protocol:/awl/auth/.peer",
+		"service:libp2p.identify.peer:" + peerID: "service:libp2p.identify.peer",
+	} {
+		require.Equal(t, expected, resourceScopeClass(name), name)
+	}
+}
+
+func Test_blockedResourcesReporter(t *testing.T) {
+	reporter := newBlockedResourcesReporter()
+	reporter.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceBlockAddStreamEvt, Name: "protocol:/awl/auth/.peer:12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn"})
+	reporter.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceBlockAddStreamEvt, Name: "protocol:/awl/auth/.peer:12D3KooWKF1xbKxWTXXYaW6W5qCTqAHf8r5PCadAGb2bQHKqxWrv"})
+	reporter.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceBlockAddConnEvt, Name: "system"})
+	reporter.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceAddStreamEvt, Name: "system"})
+
+	require.Equal(t, []BlockedResourcesInfo{
+		{Scope: "protocol:/awl/auth/.peer", Resource: BlockedResourceStream, Count: 2},
+		{Scope: "system", Resource: BlockedResourceConn, Count: 1},
+	}, reporter.stats())
+}
+
+func TestScaledResourceLimits(t *testing.T) {
+	proto := protocol.ID("/awl/test/")
+	limits := ScaledResourceLimits(1<<30, 1024, map[protocol.ID]ProtocolLimits{
+		proto: {Streams: 100, StreamsPerPeer: 10},
+	})
+	limiter := rcmgr.NewFixedLimiter(limits)
+
+	protocolLimits := limiter.GetProtocolLimits(proto)
+	require.Equal(t, 100, protocolLimits.GetStreamTotalLimit())
+	require.Equal(t, 100, protocolLimits.GetStreamLimit(network.DirInbound))
+	require.Positive(t, protocolLimits.GetMemoryLimit())
+	peerLimits := limiter.GetProtocolPeerLimits(proto)
+	require.Equal(t, 10, peerLimits.GetStreamTotalLimit())
+	require.Positive(t, peerLimits.GetMemoryLimit())
+	require.Equal(t, 1024, limiter.GetSystemLimits().GetFDLimit())
+
+	// libp2p services have their own limits
+	_, ok := limits.ToPartialLimitConfig().Service["libp2p.identify"]
+	require.True(t, ok)
+}
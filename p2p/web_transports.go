@@ -0,0 +1,283 @@
+package p2p
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcpreuse"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	libp2pwebtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// webTransportFallbackDelay is a dial delay of WebSocket and WebTransport addresses after the last QUIC or TCP dial.
+// They are slower and are needed only in networks which allow only HTTPS-looking traffic.
+const webTransportFallbackDelay = time.Second
+
+// websocketHandshakeTimeout is the same as default timeout of libp2p WebSocket transport.
+const websocketHandshakeTimeout = 15 * time.Second
+
+// buildWebTransportOpts adds WebSocket and WebTransport transports for dialing and listening on addresses like
+// /ip4/0.0.0.0/tcp/443/tls/ws and /ip4/0.0.0.0/udp/443/quic-v1/webtransport.
+// WebSocket is dialed with socket marking and via upstream proxy like TCP, without them it uses HTTPS_PROXY environment variable.
+// WebTransport sockets are marked by QUIC transport.
+func (p *P2p) buildWebTransportOpts(controlFunc func(network, address string, c syscall.RawConn) error, enableWebTransport bool) []libp2p.Option {
+	var wsOpts []websocket.Option
+	if p.config.WebSocketTLSConfig != nil {
+		wsOpts = append(wsOpts, websocket.WithTLSConfig(p.config.WebSocketTLSConfig))
+	}
+	var opts []libp2p.Option
+	if controlFunc == nil && p.config.UpstreamProxy == nil {
+		opts = append(opts, libp2p.Transport(func(u transport.Upgrader, rcmgr network.ResourceManager, sharedTCP *tcpreuse.ConnMgr) (*websocket.WebsocketTransport, error) {
+			return websocket.New(u, rcmgr, sharedTCP, wsOpts...)
+		}))
+	} else {
+		dialer := p.outboundDialer(controlFunc)
+		opts = append(opts, libp2p.Transport(func(u transport.Upgrader, rcmgr network.ResourceManager, sharedTCP *tcpreuse.ConnMgr) (*websocketTransport, error) {
+			return newWebsocketTransport(u, rcmgr, sharedTCP, dialer, wsOpts...)
+		}))
+	}
+	// WebTransport is QUIC based and doesn't support private networks
+	if enableWebTransport {
+		opts = append(opts, libp2p.Transport(libp2pwebtransport.New))
+	}
+
+	return opts
+}
+
+// websocketTransport is libp2p WebSocket transport which dials outbound connections with the given dialer.
+type websocketTransport struct {
+	*websocket.WebsocketTransport
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+	dialer   tcp.ContextDialer
+}
+
+func newWebsocketTransport(u transport.Upgrader, rcmgr network.ResourceManager, sharedTCP *tcpreuse.ConnMgr,
+	dialer tcp.ContextDialer, opts ...websocket.Option) (*websocketTransport, error) {
+	t, err := websocket.New(u, rcmgr, sharedTCP, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if rcmgr == nil {
+		rcmgr = &network.NullResourceManager{}
+	}
+	return &websocketTransport{WebsocketTransport: t, upgrader: u, rcmgr: rcmgr, dialer: dialer}, nil
+}
+
+func (t *websocketTransport) Dial(ctx context.Context, raddr multiaddr.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	connScope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.dialWithScope(ctx, raddr, p, connScope)
+	if err != nil {
+		connScope.Done()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *websocketTransport) dialWithScope(ctx context.Context, raddr multiaddr.Multiaddr, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
+	maconn, err := t.maDial(ctx, raddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.upgrader.Upgrade(ctx, t, maconn, network.DirOutbound, p, connScope)
+	if err != nil {
+		return nil, err
+	}
+	return &websocketCapableConn{CapableConn: conn}, nil
+}
+
+func (t *websocketTransport) maDial(ctx context.Context, raddr multiaddr.Multiaddr) (manet.Conn, error) {
+	netAddr, err := websocket.ConvertWebsocketMultiaddrToNetAddr(raddr)
+	if err != nil {
+		return nil, err
+	}
+	wsurl := netAddr.(*websocket.Addr).URL
+	secure := wsurl.Scheme == "wss"
+	dialAddr := wsurl.Host
+
+	dialer := ws.Dialer{
+		HandshakeTimeout: websocketHandshakeTimeout,
+		NetDialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			// address has SNI host, dial already resolved IP address instead
+			if address == wsurl.Host {
+				address = dialAddr
+			}
+			return t.dialer.DialContext(ctx, network, address)
+		},
+	}
+	if secure {
+		dialer.TLSClientConfig = &tls.Config{}
+		if sni, err := raddr.ValueForProtocol(multiaddr.P_SNI); err == nil && sni != "" {
+			dialer.TLSClientConfig.ServerName = sni
+			// host header should be SNI
+			wsurl.Host = sni + ":" + wsurl.Port()
+		}
+	}
+
+	wsConn, _, err := dialer.DialContext(ctx, wsurl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := manet.WrapNetConn(newWebsocketConn(wsConn, secure))
+	if err != nil {
+		_ = wsConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+type websocketCapableConn struct {
+	transport.CapableConn
+}
+
+func (c *websocketCapableConn) ConnState() network.ConnectionState {
+	state := c.CapableConn.ConnState()
+	state.Transport = "websocket"
+	return state
+}
+
+// websocketConn is net.Conn over binary WebSocket messages, the same as unexported connection of libp2p WebSocket transport.
+type websocketConn struct {
+	*ws.Conn
+	secure              bool
+	reader              io.Reader
+	readLock, writeLock sync.Mutex
+	closeOnce           func() error
+}
+
+func newWebsocketConn(conn *ws.Conn, secure bool) *websocketConn {
+	c := &websocketConn{Conn: conn, secure: secure}
+	c.closeOnce = sync.OnceValue(func() error {
+		err := c.Conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, "closed"),
+			time.Now().Add(websocket.GracefulCloseTimeout))
+		return errors.Join(err, c.Conn.Close())
+	})
+	return c
+}
+
+func (c *websocketConn) Read(b []byte) (int, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	for {
+		if c.reader == nil {
+			if err := c.nextReader(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := c.reader.Read(b)
+		if err != io.EOF {
+			return n, err
+		}
+		c.reader = nil
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (c *websocketConn) nextReader() error {
+	messageType, reader, err := c.Conn.NextReader()
+	var closeErr *ws.CloseError
+	if errors.As(err, &closeErr) && (closeErr.Code == ws.CloseNormalClosure || closeErr.Code == ws.CloseNoStatusReceived) {
+		return io.EOF
+	} else if err != nil {
+		return err
+	}
+	if messageType == ws.CloseMessage {
+		return io.EOF
+	}
+	c.reader = reader
+	return nil
+}
+
+func (c *websocketConn) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if err := c.Conn.WriteMessage(ws.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *websocketConn) Close() error {
+	return c.closeOnce()
+}
+
+func (c *websocketConn) LocalAddr() net.Addr {
+	return websocket.NewAddrWithScheme(c.Conn.LocalAddr().String(), c.secure)
+}
+
+func (c *websocketConn) RemoteAddr() net.Addr {
+	return websocket.NewAddrWithScheme(c.Conn.RemoteAddr().String(), c.secure)
+}
+
+func (c *websocketConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *websocketConn) SetWriteDeadline(t time.Time) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// IsSecureWebSocketAddr reports whether addr is secure WebSocket address, TLS certificate is required to listen on it.
+func IsSecureWebSocketAddr(addr multiaddr.Multiaddr) bool {
+	if _, err := addr.ValueForProtocol(multiaddr.P_WSS); err == nil {
+		return true
+	}
+	_, tlsErr := addr.ValueForProtocol(multiaddr.P_TLS)
+	_, wsErr := addr.ValueForProtocol(multiaddr.P_WS)
+	return tlsErr == nil && wsErr == nil
+}
+
+func isWebAddr(addr multiaddr.Multiaddr) bool {
+	for _, code := range []int{multiaddr.P_WS, multiaddr.P_WSS, multiaddr.P_WEBTRANSPORT} {
+		if _, err := addr.ValueForProtocol(code); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// delayWebAddrs makes WebSocket and WebTransport addresses to be dialed after other addresses fail.
+func delayWebAddrs(delays []network.AddrDelay) {
+	if !slices.ContainsFunc(delays, func(addrDelay network.AddrDelay) bool { return !isWebAddr(addrDelay.Addr) }) {
+		return
+	}
+
+	var maxDelay time.Duration
+	for _, addrDelay := range delays {
+		if !isWebAddr(addrDelay.Addr) {
+			maxDelay = max(maxDelay, addrDelay.Delay)
+		}
+	}
+	for i, addrDelay := range delays {
+		if isWebAddr(addrDelay.Addr) {
+			delays[i].Delay = max(addrDelay.Delay, maxDelay+webTransportFallbackDelay)
+		}
+	}
+}
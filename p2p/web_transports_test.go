@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"context"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP2p_getDialRanker_DelaysWebAddrs(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	p := NewP2p(context.Background())
+	p.config.Peerstore = ps
+
+	quicAddr := mustNewMultiaddr("/ip4/1.2.3.4/udp/4363/quic-v1")
+	tcpAddr := mustNewMultiaddr("/ip4/1.2.3.4/tcp/4363")
+	wssAddr := mustNewMultiaddr("/ip4/1.2.3.4/tcp/443/tls/ws")
+	webtransportAddr := mustNewMultiaddr("/ip4/1.2.3.4/udp/443/quic-v1/webtransport")
+	relayAddr := mustNewMultiaddr("/ip4/5.6.7.8/udp/4363/quic-v1/p2p/12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn/p2p-circuit")
+
+	delays := make(map[string]time.Duration)
+	for _, addrDelay := range p.getDialRanker()([]ma.Multiaddr{wssAddr, webtransportAddr, quicAddr, tcpAddr, relayAddr}) {
+		delays[addrDelay.Addr.String()] = addrDelay.Delay
+	}
+	maxPlainDelay := max(delays[quicAddr.String()], delays[tcpAddr.String()], delays[relayAddr.String()])
+	require.GreaterOrEqual(t, delays[wssAddr.String()], maxPlainDelay+webTransportFallbackDelay)
+	require.GreaterOrEqual(t, delays[webtransportAddr.String()], maxPlainDelay+webTransportFallbackDelay)
+
+	// peer is reachable only via web transports
+	for _, addrDelay := range p.getDialRanker()([]ma.Multiaddr{wssAddr, webtransportAddr}) {
+		require.Less(t, addrDelay.Delay, webTransportFallbackDelay, addrDelay.Addr.String())
+	}
+}
+
+func TestIsSecureWebSocketAddr(t *testing.T) {
+	for addr, expected := range map[string]bool{
+		"/ip4/0.0.0.0/tcp/443/tls/ws":                      true,
+		"/ip4/0.0.0.0/tcp/443/wss":                         true,
+		"/dns4/example.com/tcp/443/tls/sni/example.com/ws": true,
+		"/ip4/0.0.0.0/tcp/80/ws":                           false,
+		"/ip4/0.0.0.0/tcp/4363":                            false,
+		"/ip4/0.0.0.0/udp/443/quic-v1/webtransport":        false,
+	} {
+		require.Equal(t, expected, IsSecureWebSocketAddr(mustNewMultiaddr(addr)), addr)
+	}
+}
+
+func TestP2p_buildWebTransportOpts_DialsWebSocketWithMarkAndProxy(t *testing.T) {
+	server, err := libp2p.New(libp2p.Transport(websocket.New), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0/ws"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+	serverInfo := peer.AddrInfo{ID: server.ID(), Addrs: server.Addrs()}
+
+	proxyAddr, connects := startHTTPProxy(t, "user", "pass")
+	upstreamProxy, err := NewUpstreamProxy("http://user:pass@"+proxyAddr, nil)
+	require.NoError(t, err)
+
+	for name, proxy := range map[string]*UpstreamProxy{"direct": nil, "proxy": upstreamProxy} {
+		t.Run(name, func(t *testing.T) {
+			var marks atomic.Int64
+			controlFunc := func(network, address string, c syscall.RawConn) error {
+				marks.Add(1)
+				return nil
+			}
+			p := NewP2p(context.Background())
+			p.config.UpstreamProxy = proxy
+			client, err := libp2p.New(libp2p.ChainOptions(p.buildWebTransportOpts(controlFunc, false)...), libp2p.NoListenAddrs)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = client.Close() })
+			connectsBefore := connects.Load()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			require.NoError(t, client.Connect(ctx, serverInfo))
+
+			conns := client.Network().ConnsToPeer(server.ID())
+			require.Len(t, conns, 1)
+			require.Equal(t, "websocket", conns[0].ConnState().Transport)
+			require.EqualValues(t, 1, marks.Load())
+			if proxy != nil {
+				require.EqualValues(t, connectsBefore+1, connects.Load())
+			} else {
+				require.EqualValues(t, connectsBefore, connects.Load())
+			}
+		})
+	}
+}
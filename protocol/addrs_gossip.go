@@ -0,0 +1,18 @@
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+const blindPeerIDPrefix = "awl-addrs-gossip:"
+
+// BlindPeerID hides peerID in addresses gossip between requesterID and responderID. Responder matches it
+// with blinded IDs of its own known peers, so it learns only peers which both of them know.
+// Blinded IDs differ for each pair of peers and can't be correlated between them.
+func BlindPeerID(requesterID, responderID, peerID string) string {
+	mac := hmac.New(sha256.New, []byte(blindPeerIDPrefix+requesterID+":"+responderID))
+	mac.Write([]byte(peerID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,18 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlindPeerID(t *testing.T) {
+	const requester, responder, peerID = "requester", "responder", "peer"
+	blinded := BlindPeerID(requester, responder, peerID)
+
+	require.Equal(t, blinded, BlindPeerID(requester, responder, peerID))
+	require.NotContains(t, blinded, peerID)
+	require.NotEqual(t, blinded, BlindPeerID(requester, responder, "other"))
+	require.NotEqual(t, blinded, BlindPeerID(responder, requester, peerID))
+	require.NotEqual(t, blinded, BlindPeerID(requester, "other", peerID))
+}
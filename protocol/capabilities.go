@@ -0,0 +1,86 @@
+package protocol
+
+const (
+	// ProtocolVersion is increased on incompatible changes of peer protocols.
+	ProtocolVersion = 1
+	// MinProtocolVersion is the oldest protocol version of remote peers we can work with.
+	MinProtocolVersion = 1
+
+	// MaxDeviceInfoLen limits free-form device info in PeerCapabilities.
+	MaxDeviceInfoLen = 256
+	// MaxDomainAliases limits domain aliases announced in PeerStatusInfo.
+	MaxDomainAliases = 8
+)
+
+// Features are optional protocol features. Peers use a feature only when both of them support it.
+const (
+	FeatureVPNGateway  = "vpn_gateway"
+	FeatureRelay       = "relay"
+	FeatureAddrsGossip = "addrs_gossip"
+	FeatureInvites     = "invites"
+	FeatureVouches     = "vouches"
+	FeatureSpeedTest   = "speedtest"
+
+	// Not implemented yet, reserved so different versions agree on names.
+	FeatureIPv6           = "ipv6"
+	FeatureTunnelV2       = "tunnel_v2"
+	FeatureCompression    = "compression"
+	FeaturePortForwarding = "port_forwarding"
+)
+
+// Services are enabled on the sender and can be used by the receiver if it's allowed to.
+const (
+	ServiceSOCKS5     = "socks5"
+	ServiceVPNGateway = "vpn_gateway"
+	ServiceRelay      = "relay"
+)
+
+// Compatibility of remote peer with us, see CheckCompatibility.
+const (
+	CompatibilityCompatible = "compatible"
+	// CompatibilityOutdated means that remote peer uses older protocol version, some features are unavailable.
+	CompatibilityOutdated = "outdated"
+	// CompatibilityIncompatible means that one of the peers should be updated.
+	CompatibilityIncompatible = "incompatible"
+)
+
+// SupportedFeatures are features implemented by this build.
+var SupportedFeatures = []string{
+	FeatureVPNGateway,
+	FeatureRelay,
+	FeatureAddrsGossip,
+	FeatureInvites,
+	FeatureVouches,
+	FeatureSpeedTest,
+}
+
+// PeerCapabilities describe the sender of PeerStatusInfo. Receivers ignore unknown features, services and fields,
+// so new ones can be added without increasing ProtocolVersion.
+type PeerCapabilities struct {
+	// Version is awl version of the sender
+	Version string
+	OS      string
+	Arch    string
+	// ProtocolVersion and MinProtocolVersion are the current and the oldest supported protocol versions of the sender
+	ProtocolVersion    int
+	MinProtocolVersion int
+	Features           []string `json:",omitempty"`
+	Services           []string `json:",omitempty"`
+	// DeviceInfo is a free-form description of the device provided by the user
+	DeviceInfo string `json:",omitempty"`
+}
+
+// CheckCompatibility compares protocol versions of remote peer with ours.
+// Peers which don't send capabilities run awl version released before them and considered outdated.
+func CheckCompatibility(remote *PeerCapabilities) string {
+	switch {
+	case remote == nil:
+		return CompatibilityOutdated
+	case remote.ProtocolVersion < MinProtocolVersion || ProtocolVersion < remote.MinProtocolVersion:
+		return CompatibilityIncompatible
+	case remote.ProtocolVersion < ProtocolVersion:
+		return CompatibilityOutdated
+	default:
+		return CompatibilityCompatible
+	}
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	require.Equal(t, CompatibilityOutdated, CheckCompatibility(nil))
+	require.Equal(t, CompatibilityCompatible, CheckCompatibility(&PeerCapabilities{
+		ProtocolVersion: ProtocolVersion, MinProtocolVersion: MinProtocolVersion,
+	}))
+	require.Equal(t, CompatibilityCompatible, CheckCompatibility(&PeerCapabilities{
+		ProtocolVersion: ProtocolVersion + 1, MinProtocolVersion: MinProtocolVersion,
+	}))
+	require.Equal(t, CompatibilityIncompatible, CheckCompatibility(&PeerCapabilities{
+		ProtocolVersion: ProtocolVersion + 2, MinProtocolVersion: ProtocolVersion + 1,
+	}))
+	require.Equal(t, CompatibilityIncompatible, CheckCompatibility(&PeerCapabilities{
+		ProtocolVersion: MinProtocolVersion - 1,
+	}))
+}
+
+func TestSendReceiveStatus_Capabilities(t *testing.T) {
+	want := PeerStatusInfo{
+		Name: "alice",
+		Capabilities: &PeerCapabilities{
+			Version:            "v0.12.0",
+			OS:                 "linux",
+			Arch:               "amd64",
+			ProtocolVersion:    ProtocolVersion,
+			MinProtocolVersion: MinProtocolVersion,
+			Features:           SupportedFeatures,
+			Services:           []string{ServiceSOCKS5},
+			DeviceInfo:         "home server",
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, SendStatus(&buf, want))
+	got, err := ReceiveStatus(&buf)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// status from newer version with unknown fields and features
+	newer := `{"Name":"bob","Future":{"a":1},"Capabilities":{"Version":"v9.0.0","ProtocolVersion":3,"MinProtocolVersion":1,"Features":["quantum"],"Unknown":true}}`
+	got, err = ReceiveStatus(strings.NewReader(newer))
+	require.NoError(t, err)
+	require.Equal(t, "bob", got.Name)
+	require.Equal(t, []string{"quantum"}, got.Capabilities.Features)
+	require.Equal(t, CompatibilityCompatible, CheckCompatibility(got.Capabilities))
+
+	// status from version without capabilities
+	got, err = ReceiveStatus(strings.NewReader(`{"Name":"carol"}`))
+	require.NoError(t, err)
+	require.Nil(t, got.Capabilities)
+}
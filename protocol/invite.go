@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const (
+	// InviteURIPrefix is a prefix of pairing links, they are also used as QR code payload.
+	InviteURIPrefix = "awl://invite/"
+
+	// inviteSignaturePrefix separates invite signatures from other signatures made by peer key.
+	inviteSignaturePrefix = "awl-invite:"
+)
+
+// InviteToken is issued and signed by the inviting peer. The holder sends it in AuthPeer,
+// and the issuer accepts the auth request automatically while the invite is valid.
+type InviteToken struct {
+	// ID identifies the invite in issuer config, it's used to count uses and to revoke the invite.
+	ID string
+	// PeerID of the issuer, its key signs the token.
+	PeerID string
+	Name   string `json:",omitempty"`
+	// Addrs are optional multiaddrs of the issuer without /p2p/ suffix, they are dialed before DHT lookup.
+	Addrs     []string `json:",omitempty"`
+	ExpiresAt time.Time
+	// MaxUses and permissions are informational for the holder, the issuer enforces them from its config.
+	MaxUses              int
+	AllowUsingAsExitNode bool `json:",omitempty"`
+	AllowUsingAsRelay    bool `json:",omitempty"`
+}
+
+func (t InviteToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// EncodeInviteToken signs the token and encodes it as base64 payload and signature separated by dot.
+func EncodeInviteToken(token InviteToken, key crypto.PrivKey) (string, error) {
+	return encodeSigned(inviteSignaturePrefix, token, key)
+}
+
+// ParseInviteToken decodes the token or awl:// URI and verifies that it's signed by the issuer peer.
+// Expiration is not checked.
+func ParseInviteToken(invite string) (InviteToken, error) {
+	invite = strings.TrimPrefix(strings.TrimSpace(invite), InviteURIPrefix)
+	var token InviteToken
+	err := decodeSigned(inviteSignaturePrefix, invite, &token, func() string { return token.PeerID })
+	if err != nil {
+		return InviteToken{}, fmt.Errorf("invite: %v", err)
+	}
+
+	return token, nil
+}
+
+func InviteURI(encodedToken string) string {
+	return InviteURIPrefix + encodedToken
+}
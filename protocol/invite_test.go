@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeParseInviteToken(t *testing.T) {
+	key, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	peerID, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+
+	token := InviteToken{
+		ID:                "invite-1",
+		PeerID:            peerID.String(),
+		Name:              "alice",
+		Addrs:             []string{"/ip4/1.2.3.4/udp/4363/quic-v1"},
+		ExpiresAt:         time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		MaxUses:           1,
+		AllowUsingAsRelay: true,
+	}
+	encoded, err := EncodeInviteToken(token, key)
+	require.NoError(t, err)
+
+	parsed, err := ParseInviteToken(encoded)
+	require.NoError(t, err)
+	require.Equal(t, token, parsed)
+	require.False(t, parsed.Expired(time.Now()))
+	require.True(t, parsed.Expired(token.ExpiresAt))
+
+	parsed, err = ParseInviteToken(InviteURI(encoded))
+	require.NoError(t, err)
+	require.Equal(t, token, parsed)
+
+	// token is signed by another key
+	otherKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	forged, err := EncodeInviteToken(token, otherKey)
+	require.NoError(t, err)
+	_, err = ParseInviteToken(forged)
+	require.ErrorContains(t, err, "invite: invalid signature")
+
+	// payload is modified after signing
+	token.MaxUses = 100
+	modified, err := EncodeInviteToken(token, otherKey)
+	require.NoError(t, err)
+	payload, _, _ := strings.Cut(modified, ".")
+	_, signature, _ := strings.Cut(encoded, ".")
+	_, err = ParseInviteToken(payload + "." + signature)
+	require.ErrorContains(t, err, "invite: invalid signature")
+
+	_, err = ParseInviteToken("awl://invite/invalid")
+	require.Error(t, err)
+}
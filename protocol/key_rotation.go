@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const keyRotationSignaturePrefix = "awl-key-rotation:"
+
+// KeyRotation is signed by the old identity key of a peer and announces its new peer ID.
+// It's sent in PeerStatusInfo, known peers move their KnownPeer entry to the new peer ID.
+type KeyRotation struct {
+	OldPeerID string
+	NewPeerID string
+	IssuedAt  time.Time
+}
+
+func EncodeKeyRotation(rotation KeyRotation, oldKey crypto.PrivKey) (string, error) {
+	return encodeSigned(keyRotationSignaturePrefix, rotation, oldKey)
+}
+
+// ParseKeyRotation decodes the rotation and verifies that it's signed by the old peer key.
+func ParseKeyRotation(encoded string) (KeyRotation, error) {
+	var rotation KeyRotation
+	err := decodeSigned(keyRotationSignaturePrefix, encoded, &rotation, func() string { return rotation.OldPeerID })
+	if err != nil {
+		return KeyRotation{}, fmt.Errorf("key rotation: %v", err)
+	}
+	if rotation.OldPeerID == rotation.NewPeerID {
+		return KeyRotation{}, errors.New("key rotation: new peer id is the same")
+	}
+
+	return rotation, nil
+}
@@ -0,0 +1,45 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeParseKeyRotation(t *testing.T) {
+	oldKey, oldPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	oldID, err := peer.IDFromPublicKey(oldPub)
+	require.NoError(t, err)
+	newKey, newPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	newID, err := peer.IDFromPublicKey(newPub)
+	require.NoError(t, err)
+
+	rotation := KeyRotation{
+		OldPeerID: oldID.String(),
+		NewPeerID: newID.String(),
+		IssuedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	encoded, err := EncodeKeyRotation(rotation, oldKey)
+	require.NoError(t, err)
+	parsed, err := ParseKeyRotation(encoded)
+	require.NoError(t, err)
+	require.Equal(t, rotation, parsed)
+
+	// only the old key can announce rotation
+	forged, err := EncodeKeyRotation(rotation, newKey)
+	require.NoError(t, err)
+	_, err = ParseKeyRotation(forged)
+	require.ErrorContains(t, err, "key rotation: invalid signature")
+
+	rotation.NewPeerID = rotation.OldPeerID
+	encoded, err = EncodeKeyRotation(rotation, oldKey)
+	require.NoError(t, err)
+	_, err = ParseKeyRotation(encoded)
+	require.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+	"strconv"
+)
+
+// MaxProofOfWorkBits limits difficulty of proof of work, senders refuse to solve harder ones.
+const MaxProofOfWorkBits = 24
+
+const proofOfWorkPrefix = "awl-auth-pow:"
+
+// SolveProofOfWork finds proof of work for auth request from senderID to receiverID: a nonce
+// which makes sha256 of them start with the given number of zero bits.
+func SolveProofOfWork(ctx context.Context, senderID, receiverID string, difficulty int) (string, error) {
+	for nonce := uint64(0); ; nonce++ {
+		if nonce%4096 == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		proof := strconv.FormatUint(nonce, 36)
+		if VerifyProofOfWork(proof, senderID, receiverID, difficulty) {
+			return proof, nil
+		}
+	}
+}
+
+// VerifyProofOfWork reports whether proof is valid for auth request from senderID to receiverID.
+func VerifyProofOfWork(proof, senderID, receiverID string, difficulty int) bool {
+	if difficulty <= 0 {
+		return true
+	}
+	if proof == "" || len(proof) > 16 || difficulty > MaxProofOfWorkBits {
+		return false
+	}
+	hash := sha256.Sum256([]byte(proofOfWorkPrefix + senderID + ":" + receiverID + ":" + proof))
+
+	return bits.LeadingZeros32(binary.BigEndian.Uint32(hash[:4])) >= difficulty
+}
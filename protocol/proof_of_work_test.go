@@ -0,0 +1,27 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofOfWork(t *testing.T) {
+	const sender, receiver = "sender", "receiver"
+	proof, err := SolveProofOfWork(context.Background(), sender, receiver, 12)
+	require.NoError(t, err)
+
+	require.True(t, VerifyProofOfWork(proof, sender, receiver, 12))
+	require.True(t, VerifyProofOfWork(proof, sender, receiver, 8))
+	require.True(t, VerifyProofOfWork("", sender, receiver, 0))
+	require.False(t, VerifyProofOfWork("", sender, receiver, 8))
+	require.False(t, VerifyProofOfWork(proof, receiver, sender, 12))
+	require.False(t, VerifyProofOfWork(proof, sender, "other", 12))
+	require.False(t, VerifyProofOfWork(proof, sender, receiver, MaxProofOfWorkBits+1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = SolveProofOfWork(ctx, sender, receiver, MaxProofOfWorkBits)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -16,6 +16,7 @@ const (
 	TunnelPacketMethod protocol.ID = basePath + "/tunnel/"
 	Socks5PacketMethod protocol.ID = basePath + "/socks5/"
 	Socks5NoAuthMethod protocol.ID = basePath + "/socks5-noauth/"
+	SpeedTestMethod    protocol.ID = basePath + "/speedtest/"
 )
 
 type (
@@ -28,6 +29,27 @@ type (
 		// and uses KnownPeer.CanUseAsVPNGateway() to decide whether the
 		// peer is a valid VPN gateway target.
 		VPNGatewayServerEnabled bool
+		// RelayServiceEnabled mirrors RelayServiceConfig.Enabled on the sender,
+		// the receiver prefers such peers as relays.
+		RelayServiceEnabled bool
+		// AllowUsingAsRelay mirrors KnownPeer.WeAllowUsingAsRelay on the sender.
+		AllowUsingAsRelay bool
+		// PeersAddrsRequest is sent by the initiator of status exchange, it contains its known peers
+		// which it wants to learn addresses of, blinded by BlindPeerID.
+		PeersAddrsRequest []string `json:",omitempty"`
+		// PeersAddrs is the answer to PeersAddrsRequest: recently working multiaddrs of requested peers
+		// which are also known to the sender, keyed by blinded peer ID.
+		PeersAddrs map[string][]string `json:",omitempty"`
+		// Vouch is an encoded Vouch signed by the sender for the receiver.
+		Vouch string `json:",omitempty"`
+		// Capabilities are nil for peers running awl version released before them.
+		Capabilities *PeerCapabilities `json:",omitempty"`
+		// KeyRotations are encoded KeyRotation announcements of the sender's previous and next peer IDs.
+		KeyRotations []string `json:",omitempty"`
+		// DomainName and DomainAliases are names without zone suffix the sender wants to be resolved by,
+		// receivers use them instead of names generated from alias unless overridden by user.
+		DomainName    string   `json:",omitempty"`
+		DomainAliases []string `json:",omitempty"`
 	}
 )
 
@@ -44,11 +66,19 @@ func SendStatus(stream io.Writer, statusInfo PeerStatusInfo) error {
 
 type AuthPeer struct {
 	Name string
+	// Invite is an encoded InviteToken issued by the remote peer, such requests are accepted without confirmation.
+	Invite string `json:",omitempty"`
+	// Vouches are encoded Vouch for the sender signed by its known peers.
+	Vouches []string `json:",omitempty"`
+	// ProofOfWork is sent when receiver requires it from strangers, see SolveProofOfWork.
+	ProofOfWork string `json:",omitempty"`
 }
 
 type AuthPeerResponse struct {
 	Confirmed bool
 	Declined  bool
+	// ProofOfWorkBits is difficulty of proof of work required to resend the request.
+	ProofOfWorkBits int `json:",omitempty"`
 }
 
 func ReceiveAuth(stream io.Reader) (AuthPeer, error) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -106,3 +107,42 @@ func TestReadPacketHeader_TruncatedStream(t *testing.T) {
 	_, _, err := ReadPacketHeader(bytes.NewReader([]byte{1, 2, 3}))
 	require.Error(t, err)
 }
+
+func TestSpeedTestMessages_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	request := SpeedTestRequest{Mode: SpeedTestModeUpload, Duration: 5 * time.Second}
+	response := SpeedTestResponse{Accepted: true, Duration: 3 * time.Second, MaxRate: 1 << 20}
+	report := SpeedTestReport{Bytes: 12345, Elapsed: 3 * time.Second}
+	require.NoError(t, SendSpeedTestRequest(&buf, request))
+	require.NoError(t, SendSpeedTestResponse(&buf, response))
+	require.NoError(t, SendSpeedTestReport(&buf, report))
+	// raw test data must stay untouched after control messages
+	buf.WriteString("payload")
+
+	gotRequest, err := ReceiveSpeedTestRequest(&buf)
+	require.NoError(t, err)
+	require.Equal(t, request, gotRequest)
+	gotResponse, err := ReceiveSpeedTestResponse(&buf)
+	require.NoError(t, err)
+	require.Equal(t, response, gotResponse)
+	gotReport, err := ReceiveSpeedTestReport(&buf)
+	require.NoError(t, err)
+	require.Equal(t, report, gotReport)
+	require.Equal(t, "payload", buf.String())
+}
+
+func TestReceiveSpeedTestRequest_RejectsHugeFrame(t *testing.T) {
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], 1<<30)
+	_, err := ReceiveSpeedTestRequest(bytes.NewReader(hdr[:]))
+	require.Error(t, err)
+}
+
+func TestSpeedTestProbe_RoundTrip(t *testing.T) {
+	buf := make([]byte, SpeedTestProbeSize)
+	sentAt := time.Unix(0, time.Now().UnixNano())
+	PutSpeedTestProbe(buf, 42, sentAt)
+	seq, gotSentAt := ParseSpeedTestProbe(buf)
+	require.Equal(t, uint64(42), seq)
+	require.True(t, sentAt.Equal(gotSentAt))
+}
@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// encodeSigned encodes v as base64 json payload and its signature separated by dot.
+// Prefix separates signatures of different payload types made by the same peer key.
+func encodeSigned(prefix string, v any, key crypto.PrivKey) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	signature, err := key.Sign(append([]byte(prefix), data...))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// decodeSigned decodes payload made by encodeSigned into v and verifies it with the key of signer peer.
+// signer is called after v is decoded, so the signer peer ID may be a field of the payload.
+func decodeSigned(prefix, encoded string, v any, signer func() string) error {
+	dataStr, signatureStr, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return errors.New("invalid format")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(dataStr)
+	if err != nil {
+		return fmt.Errorf("decode: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signatureStr)
+	if err != nil {
+		return fmt.Errorf("decode signature: %v", err)
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("decode: %v", err)
+	}
+
+	signerID, err := peer.Decode(signer())
+	if err != nil {
+		return fmt.Errorf("invalid signer peer id: %v", err)
+	}
+	signerKey, err := signerID.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("extract signer public key: %v", err)
+	}
+	valid, err := signerKey.Verify(append([]byte(prefix), data...), signature)
+	if err != nil || !valid {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
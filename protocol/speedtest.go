@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Speed test modes. Every stream runs exactly one mode, bidirectional test is
+// an upload and a download stream running simultaneously.
+const (
+	// SpeedTestModeUpload - initiator sends data, receiver counts it and replies with SpeedTestReport.
+	SpeedTestModeUpload = "upload"
+	// SpeedTestModeDownload - receiver sends data until the test duration is over and closes the stream.
+	SpeedTestModeDownload = "download"
+	// SpeedTestModeLatency - initiator sends SpeedTestProbeSize probes, receiver echoes them back.
+	SpeedTestModeLatency = "latency"
+
+	// SpeedTestProbeSize is the size of latency probe: 8 bytes sequence number and 8 bytes send time.
+	SpeedTestProbeSize = 16
+
+	speedTestMaxFrameSize = 4 << 10
+)
+
+type SpeedTestRequest struct {
+	Mode     string
+	Duration time.Duration
+}
+
+type SpeedTestResponse struct {
+	Accepted bool
+	// Reason is set when the test was refused.
+	Reason string
+	// Duration may be shorter than requested when receiver limits tests.
+	Duration time.Duration
+	// MaxRate is the receiver throughput limit in bytes per second, 0 means unlimited.
+	MaxRate int64
+}
+
+// SpeedTestReport is sent by receiver after upload is finished.
+type SpeedTestReport struct {
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// Speed test control messages are length-prefixed because they share the stream with raw test data.
+
+func ReceiveSpeedTestRequest(stream io.Reader) (SpeedTestRequest, error) {
+	request := SpeedTestRequest{}
+	err := readJSONFrame(stream, &request)
+	return request, err
+}
+
+func SendSpeedTestRequest(stream io.Writer, request SpeedTestRequest) error {
+	return writeJSONFrame(stream, request)
+}
+
+func ReceiveSpeedTestResponse(stream io.Reader) (SpeedTestResponse, error) {
+	response := SpeedTestResponse{}
+	err := readJSONFrame(stream, &response)
+	return response, err
+}
+
+func SendSpeedTestResponse(stream io.Writer, response SpeedTestResponse) error {
+	return writeJSONFrame(stream, response)
+}
+
+func ReceiveSpeedTestReport(stream io.Reader) (SpeedTestReport, error) {
+	report := SpeedTestReport{}
+	err := readJSONFrame(stream, &report)
+	return report, err
+}
+
+func SendSpeedTestReport(stream io.Writer, report SpeedTestReport) error {
+	return writeJSONFrame(stream, report)
+}
+
+// PutSpeedTestProbe encodes latency probe into buf, which must be at least SpeedTestProbeSize long.
+func PutSpeedTestProbe(buf []byte, seq uint64, sentAt time.Time) {
+	binary.BigEndian.PutUint64(buf[0:8], seq)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(sentAt.UnixNano()))
+}
+
+func ParseSpeedTestProbe(buf []byte) (seq uint64, sentAt time.Time) {
+	seq = binary.BigEndian.Uint64(buf[0:8])
+	sentAt = time.Unix(0, int64(binary.BigEndian.Uint64(buf[8:16])))
+	return seq, sentAt
+}
+
+func writeJSONFrame(stream io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	buf = append(buf, data...)
+	_, err = stream.Write(buf)
+	return err
+}
+
+func readJSONFrame(stream io.Reader, v any) error {
+	var header [4]byte
+	if _, err := io.ReadFull(stream, header[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > speedTestMaxFrameSize {
+		return fmt.Errorf("invalid frame: size %d exceeds max %d", size, speedTestMaxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(stream, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
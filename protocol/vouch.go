@@ -0,0 +1,42 @@
+package protocol
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const (
+	// VouchTTL is a lifetime of vouches, they are renewed on every status exchange.
+	VouchTTL = 7 * 24 * time.Hour
+
+	vouchSignaturePrefix = "awl-vouch:"
+)
+
+// Vouch is signed by the voucher for its known peer PeerID and is sent to the peer in PeerStatusInfo.
+// The peer attaches vouches to its auth requests, so peers which also know the voucher can accept it automatically.
+type Vouch struct {
+	VoucherID string
+	PeerID    string
+	ExpiresAt time.Time
+}
+
+func (v Vouch) Expired(now time.Time) bool {
+	return !now.Before(v.ExpiresAt)
+}
+
+func EncodeVouch(vouch Vouch, key crypto.PrivKey) (string, error) {
+	return encodeSigned(vouchSignaturePrefix, vouch, key)
+}
+
+// ParseVouch decodes the vouch and verifies that it's signed by the voucher. Expiration is not checked.
+func ParseVouch(encoded string) (Vouch, error) {
+	var vouch Vouch
+	err := decodeSigned(vouchSignaturePrefix, encoded, &vouch, func() string { return vouch.VoucherID })
+	if err != nil {
+		return Vouch{}, fmt.Errorf("vouch: %v", err)
+	}
+
+	return vouch, nil
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeParseVouch(t *testing.T) {
+	key, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	voucherID, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+
+	vouch := Vouch{
+		VoucherID: voucherID.String(),
+		PeerID:    "12D3KooWJF6Ux8fAwZj1c2cuhnHTRbGa7pjAntrJDupXMDdW5jGn",
+		ExpiresAt: time.Now().Add(VouchTTL).UTC().Truncate(time.Second),
+	}
+	encoded, err := EncodeVouch(vouch, key)
+	require.NoError(t, err)
+	parsed, err := ParseVouch(encoded)
+	require.NoError(t, err)
+	require.Equal(t, vouch, parsed)
+	require.False(t, parsed.Expired(time.Now()))
+
+	// vouch on behalf of another peer
+	otherKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	forged, err := EncodeVouch(vouch, otherKey)
+	require.NoError(t, err)
+	_, err = ParseVouch(forged)
+	require.ErrorContains(t, err, "vouch: invalid signature")
+}
@@ -0,0 +1,69 @@
+package ringbuffer
+
+import (
+	"sync"
+)
+
+// Ring is a fixed size circular buffer of values. When full, new values overwrite the oldest ones.
+type Ring[T any] struct {
+	items []T
+	start int
+	count int
+	mu    sync.Mutex
+}
+
+// NewRing returns a new Ring which keeps up to size last values.
+func NewRing[T any](size int) *Ring[T] {
+	return &Ring[T]{
+		items: make([]T, size),
+	}
+}
+
+// Push appends value, overwriting the oldest one when the ring is full.
+func (r *Ring[T]) Push(value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.items) == 0 {
+		return
+	}
+	if r.count < len(r.items) {
+		r.items[(r.start+r.count)%len(r.items)] = value
+		r.count++
+		return
+	}
+	r.items[r.start] = value
+	r.start = (r.start + 1) % len(r.items)
+}
+
+// Items returns a copy of stored values, oldest first.
+func (r *Ring[T]) Items() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]T, r.count)
+	for i := range r.count {
+		result[i] = r.items[(r.start+i)%len(r.items)]
+	}
+	return result
+}
+
+// Last returns the most recently pushed value.
+func (r *Ring[T]) Last() (T, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.count == 0 {
+		var empty T
+		return empty, false
+	}
+	return r.items[(r.start+r.count-1)%len(r.items)], true
+}
+
+// Len returns the number of stored values.
+func (r *Ring[T]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.count
+}
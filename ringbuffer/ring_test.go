@@ -0,0 +1,35 @@
+package ringbuffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing_Push(t *testing.T) {
+	r := NewRing[int](3)
+	assert.Equal(t, []int{}, r.Items())
+	_, ok := r.Last()
+	assert.False(t, ok)
+
+	r.Push(1)
+	r.Push(2)
+	assert.Equal(t, []int{1, 2}, r.Items())
+	assert.Equal(t, 2, r.Len())
+
+	for i := 3; i <= 7; i++ {
+		r.Push(i)
+	}
+	assert.Equal(t, []int{5, 6, 7}, r.Items())
+	assert.Equal(t, 3, r.Len())
+	last, ok := r.Last()
+	assert.True(t, ok)
+	assert.Equal(t, 7, last)
+}
+
+func TestRing_ZeroSize(t *testing.T) {
+	r := NewRing[string](0)
+	r.Push("a")
+	assert.Equal(t, []string{}, r.Items())
+	assert.Equal(t, 0, r.Len())
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/metrics"
+	"github.com/anywherelan/awl/protocol"
+)
+
+// Reasons of dropped auth requests, they are used as metrics labels.
+const (
+	authDropPeerRateLimit   = "peer_rate_limit"
+	authDropGlobalRateLimit = "global_rate_limit"
+	authDropInviteRequired  = "invite_required"
+	authDropProofOfWork     = "proof_of_work"
+	authDropEvicted         = "evicted"
+)
+
+const (
+	// maxAuthRequestSize limits auth request from remote peer.
+	maxAuthRequestSize = 64 * 1024
+	// maxAuthLimiterPeers limits number of tracked unknown peers, idle ones are pruned first.
+	maxAuthLimiterPeers = 10000
+)
+
+type ingoingAuth struct {
+	protocol.AuthPeer
+	receivedAt time.Time
+}
+
+// authRequestLimiter limits rate of auth requests from unknown peers.
+type authRequestLimiter struct {
+	lock sync.Mutex
+	// limits which limiters are created with, they are recreated when config changes
+	limits config.AuthRequestLimitsConfig
+	global *rate.Limiter
+	peers  map[peer.ID]*rate.Limiter
+}
+
+// allow returns the reason if the request should be dropped.
+func (l *authRequestLimiter) allow(peerID peer.ID, limits config.AuthRequestLimitsConfig, now time.Time) (string, bool) {
+	if limits.Disabled {
+		return "", true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.peers == nil || l.limits.PeerRequestsPerHour != limits.PeerRequestsPerHour || l.limits.RequestsPerMinute != limits.RequestsPerMinute {
+		l.limits = limits
+		l.global = newAuthRateLimiter(limits.RequestsPerMinute, time.Minute)
+		l.peers = make(map[peer.ID]*rate.Limiter)
+	}
+
+	peerLimiter, exists := l.peers[peerID]
+	if !exists {
+		if len(l.peers) >= maxAuthLimiterPeers {
+			l.pruneUnlocked(now)
+		}
+		peerLimiter = newAuthRateLimiter(limits.PeerRequestsPerHour, time.Hour)
+		l.peers[peerID] = peerLimiter
+	}
+	if !peerLimiter.AllowN(now, 1) {
+		return authDropPeerRateLimit, false
+	}
+	if !l.global.AllowN(now, 1) {
+		return authDropGlobalRateLimit, false
+	}
+	return "", true
+}
+
+// pruneUnlocked removes limiters of peers which haven't sent requests recently.
+func (l *authRequestLimiter) pruneUnlocked(now time.Time) {
+	maps.DeleteFunc(l.peers, func(_ peer.ID, limiter *rate.Limiter) bool {
+		return limiter.TokensAt(now) >= float64(limiter.Burst())
+	})
+	if len(l.peers) >= maxAuthLimiterPeers {
+		// flood from many peers, the global limit still works
+		clear(l.peers)
+	}
+}
+
+func newAuthRateLimiter(requests int, interval time.Duration) *rate.Limiter {
+	if requests <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Every(interval/time.Duration(requests)), requests)
+}
+
+// strangerDropReason checks requirements for auth requests from strangers: peers without valid invite or vouch.
+func (s *AuthStatus) strangerDropReason(peerID peer.ID, authPeer protocol.AuthPeer, decision entity.AuthDecision,
+	invited bool, limits config.AuthRequestLimitsConfig) string {
+	if invited || len(decision.VouchedBy) > 0 || decision.Action == config.AuthRuleActionReject {
+		return ""
+	}
+	if limits.RequireInvite {
+		return authDropInviteRequired
+	}
+	if !protocol.VerifyProofOfWork(authPeer.ProofOfWork, peerID.String(), s.conf.P2pNode.PeerID, limits.ProofOfWorkBits) {
+		return authDropProofOfWork
+	}
+	return ""
+}
+
+// dropAuthRequest responds to dropped auth request as if it's pending, or doesn't respond in silent mode.
+func (s *AuthStatus) dropAuthRequest(stream network.Stream, reason string, limits config.AuthRequestLimitsConfig) {
+	metrics.PeersAuthRequestsDroppedTotal.WithLabelValues(reason).Inc()
+	s.logger.Debugf("dropped auth request from %s: %s", stream.Conn().RemotePeer(), reason)
+
+	response := protocol.AuthPeerResponse{}
+	if reason == authDropProofOfWork {
+		response.ProofOfWorkBits = limits.ProofOfWorkBits
+	} else if limits.SilentDrop {
+		_ = stream.Reset()
+		return
+	}
+	_ = protocol.SendAuthResponse(stream, response)
+}
+
+// queueAuthRequest adds pending auth request, the oldest one is evicted when there are too many.
+// It returns false if the peer has already sent pending request.
+func (s *AuthStatus) queueAuthRequest(peerID peer.ID, authPeer protocol.AuthPeer, limits config.AuthRequestLimitsConfig) bool {
+	s.authsLock.Lock()
+	defer s.authsLock.Unlock()
+
+	prev, exists := s.ingoingAuths[peerID]
+	receivedAt := time.Now()
+	if exists {
+		receivedAt = prev.receivedAt
+	}
+	s.ingoingAuths[peerID] = ingoingAuth{AuthPeer: authPeer, receivedAt: receivedAt}
+
+	for !limits.Disabled && len(s.ingoingAuths) > limits.MaxPending {
+		var oldestID peer.ID
+		var oldest time.Time
+		for id, auth := range s.ingoingAuths {
+			if id != peerID && (oldestID == "" || auth.receivedAt.Before(oldest)) {
+				oldestID, oldest = id, auth.receivedAt
+			}
+		}
+		if oldestID == "" {
+			break
+		}
+		delete(s.ingoingAuths, oldestID)
+		metrics.PeersAuthRequestsDroppedTotal.WithLabelValues(authDropEvicted).Inc()
+	}
+
+	return !exists
+}
+
+// PurgeIngoingAuthRequests removes pending auth requests of peerIDs or all of them if peerIDs is empty.
+// Senders aren't notified and can send requests again, use BlockPeer to decline them.
+func (s *AuthStatus) PurgeIngoingAuthRequests(peerIDs []string) map[peer.ID]protocol.AuthPeer {
+	s.authsLock.Lock()
+	defer s.authsLock.Unlock()
+
+	purged := make(map[peer.ID]protocol.AuthPeer)
+	if len(peerIDs) == 0 {
+		for peerID, auth := range s.ingoingAuths {
+			purged[peerID] = auth.AuthPeer
+		}
+		clear(s.ingoingAuths)
+		return purged
+	}
+	for _, peerIDStr := range peerIDs {
+		peerID, err := peer.Decode(peerIDStr)
+		if err != nil {
+			continue
+		}
+		if auth, exists := s.ingoingAuths[peerID]; exists {
+			purged[peerID] = auth.AuthPeer
+			delete(s.ingoingAuths, peerID)
+		}
+	}
+	return purged
+}
+
+// solveAuthProofOfWork adds proof of work required by remote peer to auth request.
+func (s *AuthStatus) solveAuthProofOfWork(ctx context.Context, peerID peer.ID, req protocol.AuthPeer, difficulty int) (protocol.AuthPeer, error) {
+	if difficulty > protocol.MaxProofOfWorkBits {
+		return req, fmt.Errorf("peer %s requires too hard proof of work: %d bits", peerID, difficulty)
+	}
+	started := time.Now()
+	proof, err := protocol.SolveProofOfWork(ctx, s.conf.P2pNode.PeerID, peerID.String(), difficulty)
+	if err != nil {
+		return req, fmt.Errorf("solve proof of work: %v", err)
+	}
+	s.logger.Infof("solved proof of work of %d bits for auth request to %s in %s", difficulty, peerID, time.Since(started))
+	req.ProofOfWork = proof
+
+	return req, nil
+}
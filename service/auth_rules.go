@@ -0,0 +1,204 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/metrics"
+	"github.com/anywherelan/awl/protocol"
+)
+
+const (
+	AuthRuleInvite                 = "invite"
+	AuthRuleAutoAcceptAuthRequests = "autoAcceptAuthRequests"
+	AuthRuleDefault                = "default"
+
+	maxAuthDecisions = 100
+	// maxAuthVouches limits vouches sent with auth request and verified on receiving.
+	maxAuthVouches = 16
+)
+
+// authRequestConditions are facts about auth request which rules match on.
+type authRequestConditions struct {
+	Name    string
+	Invite  bool
+	Vouched bool
+	Time    time.Time
+}
+
+// matchAuthRule returns the first valid rule which matches all its conditions.
+func matchAuthRule(rules []config.AuthRule, req authRequestConditions) (config.AuthRule, bool) {
+	for _, rule := range rules {
+		if config.ValidateAuthRule(rule) != nil {
+			continue
+		}
+		if rule.Invite && !req.Invite || rule.Vouched && !req.Vouched {
+			continue
+		}
+		if !rule.MatchName(req.Name) || !rule.Active(req.Time) {
+			continue
+		}
+		return rule, true
+	}
+	return config.AuthRule{}, false
+}
+
+// decideAuthRequest applies auth rules to the request from unknown peer. Invite isn't used here,
+// the caller uses it if the request is accepted.
+func (s *AuthStatus) decideAuthRequest(peerID peer.ID, authPeer protocol.AuthPeer) (entity.AuthDecision, config.Invite) {
+	now := time.Now()
+	decision := entity.AuthDecision{
+		PeerID:    peerID.String(),
+		Name:      authPeer.Name,
+		Time:      now,
+		VouchedBy: s.verifyVouches(peerID, authPeer.Vouches, now),
+	}
+
+	var invite config.Invite
+	hasInvite := false
+	if authPeer.Invite != "" {
+		var err error
+		invite, err = s.validInvite(authPeer.Invite)
+		if err != nil {
+			metrics.PeersAuthInvitesTotal.WithLabelValues("rejected").Inc()
+			s.logger.Warnf("peer %s sent invalid invite: %v", peerID, err)
+		}
+		hasInvite = err == nil
+	}
+
+	s.conf.RLock()
+	rules := s.conf.P2pNode.AuthRules
+	autoAccept := s.conf.P2pNode.AutoAcceptAuthRequests
+	s.conf.RUnlock()
+
+	rule, matched := matchAuthRule(rules, authRequestConditions{
+		Name:    authPeer.Name,
+		Invite:  hasInvite,
+		Vouched: len(decision.VouchedBy) > 0,
+		Time:    now,
+	})
+	switch {
+	case matched:
+		decision.Rule = rule.Name
+		decision.Action = rule.Action
+		decision.AllowUsingAsExitNode = rule.AllowUsingAsExitNode
+		decision.AllowUsingAsRelay = rule.AllowUsingAsRelay
+	case hasInvite:
+		decision.Rule = AuthRuleInvite
+		decision.Action = config.AuthRuleActionAccept
+	case autoAccept:
+		decision.Rule = AuthRuleAutoAcceptAuthRequests
+		decision.Action = config.AuthRuleActionAccept
+	default:
+		decision.Rule = AuthRuleDefault
+		decision.Action = config.AuthRuleActionQueue
+	}
+
+	if decision.Action == config.AuthRuleActionAccept && hasInvite {
+		decision.InviteID = invite.ID
+		decision.AllowUsingAsExitNode = decision.AllowUsingAsExitNode || invite.AllowUsingAsExitNode
+		decision.AllowUsingAsRelay = decision.AllowUsingAsRelay || invite.AllowUsingAsRelay
+	}
+
+	return decision, invite
+}
+
+func (s *AuthStatus) recordAuthDecision(decision entity.AuthDecision) {
+	metrics.PeersAuthDecisionsTotal.WithLabelValues(decision.Action).Inc()
+	s.logger.Infof("auth request from %s (%s): %s by rule %q, vouched by %v", decision.Name, decision.PeerID,
+		decision.Action, decision.Rule, decision.VouchedBy)
+
+	s.decisionsLock.Lock()
+	s.decisions = append(s.decisions, decision)
+	if len(s.decisions) > maxAuthDecisions {
+		s.decisions = slices.Delete(s.decisions, 0, len(s.decisions)-maxAuthDecisions)
+	}
+	s.decisionsLock.Unlock()
+}
+
+// AuthDecisions returns recent decisions on auth requests, the newest first.
+func (s *AuthStatus) AuthDecisions() []entity.AuthDecision {
+	s.decisionsLock.RLock()
+	decisions := slices.Clone(s.decisions)
+	s.decisionsLock.RUnlock()
+	slices.Reverse(decisions)
+
+	return decisions
+}
+
+// verifyVouches returns our confirmed known peers which vouched for peerID.
+func (s *AuthStatus) verifyVouches(peerID peer.ID, vouches []string, now time.Time) []string {
+	var vouchedBy []string
+	for _, encoded := range vouches[:min(len(vouches), maxAuthVouches)] {
+		vouch, err := protocol.ParseVouch(encoded)
+		if err != nil || vouch.PeerID != peerID.String() || vouch.Expired(now) || slices.Contains(vouchedBy, vouch.VoucherID) {
+			continue
+		}
+		voucher, known := s.conf.GetPeer(vouch.VoucherID)
+		if !known || !voucher.Confirmed || voucher.Declined {
+			continue
+		}
+		vouchedBy = append(vouchedBy, vouch.VoucherID)
+	}
+	return vouchedBy
+}
+
+// ourVouches returns vouches for us received from known peers, they are sent with our auth requests.
+func (s *AuthStatus) ourVouches() []string {
+	s.conf.RLock()
+	defer s.conf.RUnlock()
+
+	var vouches []string
+	for _, knownPeer := range s.conf.KnownPeers {
+		if knownPeer.Vouch != "" && len(vouches) < maxAuthVouches {
+			vouches = append(vouches, knownPeer.Vouch)
+		}
+	}
+	return vouches
+}
+
+// createVouch signs vouch for the known peer, it's sent to the peer with status info.
+// We vouch only for peers which confirmed our invitation and have access now, otherwise it returns empty string.
+func (s *AuthStatus) createVouch(knownPeer config.KnownPeer, perms config.PeerPermissions) string {
+	if knownPeer.PeerID == "" || !knownPeer.Confirmed || knownPeer.Declined || perms.Suspended {
+		return ""
+	}
+	peerID := knownPeer.PeerID
+	privKey, err := crypto.UnmarshalEd25519PrivateKey(s.conf.PrivKey())
+	if err != nil {
+		s.logger.Errorf("load identity: %v", err)
+		return ""
+	}
+	vouch, err := protocol.EncodeVouch(protocol.Vouch{
+		VoucherID: s.conf.P2pNode.PeerID,
+		PeerID:    peerID,
+		ExpiresAt: time.Now().Add(protocol.VouchTTL).UTC().Truncate(time.Second),
+	}, privKey)
+	if err != nil {
+		s.logger.Errorf("sign vouch for %s: %v", peerID, err)
+		return ""
+	}
+	return vouch
+}
+
+// processVouch returns the vouch if it's signed by peerID for us, otherwise empty string.
+func (s *AuthStatus) processVouch(peerID, encoded string) string {
+	if encoded == "" {
+		return ""
+	}
+	vouch, err := protocol.ParseVouch(encoded)
+	if err != nil {
+		s.logger.Warnf("peer %s sent invalid vouch: %v", peerID, err)
+		return ""
+	}
+	if vouch.VoucherID != peerID || vouch.PeerID != s.conf.P2pNode.PeerID {
+		s.logger.Warnf("peer %s sent vouch of %s for %s", peerID, vouch.VoucherID, vouch.PeerID)
+		return ""
+	}
+	return encoded
+}
@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
 	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -12,10 +14,12 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	libp2pProtocol "github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
 
 	"github.com/anywherelan/awl/awldns"
 	"github.com/anywherelan/awl/awlevent"
 	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
 	"github.com/anywherelan/awl/metrics"
 	"github.com/anywherelan/awl/protocol"
 )
@@ -23,6 +27,8 @@ import (
 const (
 	backgroundExchangeStatusInfoInterval = 5 * time.Minute
 	backgroundRetryAuthRequests          = 5 * time.Minute
+	// maxUnknownStatusInfoSize limits status info from unknown peers, they are accepted only with valid key rotation.
+	maxUnknownStatusInfoSize = 64 * 1024
 )
 
 type P2p interface {
@@ -32,17 +38,29 @@ type P2p interface {
 	NewStreamMulti(ctx context.Context, id peer.ID, protos ...libp2pProtocol.ID) (network.Stream, error)
 	NewStreamWithDedicatedConn(ctx context.Context, id peer.ID, proto libp2pProtocol.ID) (network.Stream, error)
 	SubscribeConnectionEvents(onConnected, onDisconnected func(network.Network, network.Conn))
+	ConnsToPeer(peerID peer.ID) []network.Conn
 	RecordPeerLatency(id peer.ID, rtt time.Duration)
+	WorkingAddrs(peerID peer.ID) []multiaddr.Multiaddr
+	AddGossipedAddrs(peerID peer.ID, addrs []multiaddr.Multiaddr)
+	UnprotectPeer(id peer.ID)
 }
 
 type AuthStatus struct {
-	ingoingAuths  map[peer.ID]protocol.AuthPeer
+	ingoingAuths  map[peer.ID]ingoingAuth
 	outgoingAuths map[peer.ID]protocol.AuthPeer
 	authsLock     sync.RWMutex
 	logger        *log.ZapEventLogger
 	p2p           P2p
 	conf          *config.Config
 	authsEmitter  awlevent.Emitter
+	accessEmitter awlevent.Emitter
+
+	decisions     []entity.AuthDecision
+	decisionsLock sync.RWMutex
+
+	rotationLock sync.Mutex
+
+	authLimiter authRequestLimiter
 }
 
 func NewAuthStatus(p2pService P2p, conf *config.Config, eventbus awlevent.Bus) *AuthStatus {
@@ -50,14 +68,19 @@ func NewAuthStatus(p2pService P2p, conf *config.Config, eventbus awlevent.Bus) *
 	if err != nil {
 		panic(err)
 	}
+	accessEmitter, err := eventbus.Emitter(new(awlevent.PeerAccessChanged))
+	if err != nil {
+		panic(err)
+	}
 
 	auth := &AuthStatus{
-		ingoingAuths:  make(map[peer.ID]protocol.AuthPeer),
+		ingoingAuths:  make(map[peer.ID]ingoingAuth),
 		outgoingAuths: make(map[peer.ID]protocol.AuthPeer),
 		logger:        log.Logger("awl/service/status"),
 		p2p:           p2pService,
 		conf:          conf,
 		authsEmitter:  emitter,
+		accessEmitter: accessEmitter,
 	}
 	auth.restoreOutgoingAuths()
 	p2pService.SubscribeConnectionEvents(auth.onPeerConnected, auth.onPeerDisconnected)
@@ -74,23 +97,38 @@ func (s *AuthStatus) StatusStreamHandler(stream network.Stream) {
 	peerID := remotePeer.String()
 	knownPeer, known := s.conf.GetPeer(peerID)
 	_, isBlocked := s.conf.GetBlockedPeer(peerID)
+	var reader io.Reader = stream
 	if !known && !isBlocked {
-		s.logger.Infof("Unknown peer %s tried to exchange status info", peerID)
-		return
+		reader = io.LimitReader(stream, maxUnknownStatusInfoSize)
 	}
 
 	// Receiving info
-	oppositePeerInfo, err := protocol.ReceiveStatus(stream)
+	oppositePeerInfo, err := protocol.ReceiveStatus(reader)
 	if err != nil {
 		s.logger.Errorf("receiving status info from %s: %v", peerID, err)
 		return
 	}
+	if !known && !isBlocked {
+		// unknown peer could be our known peer with rotated identity key
+		knownPeer, known = s.processKeyRotations(peerID, oppositePeerInfo.KeyRotations)
+		if !known {
+			s.logger.Infof("Unknown peer %s tried to exchange status info", peerID)
+			return
+		}
+	}
 	s.authsLock.Lock()
 	delete(s.outgoingAuths, remotePeer)
 	s.authsLock.Unlock()
 
+	// received status info confirms or declines our invitation, it's saved after the answer
+	knownPeer.Declined = oppositePeerInfo.Declined
+	knownPeer.Confirmed = knownPeer.Confirmed || !oppositePeerInfo.Declined
+
 	// Sending info
 	myPeerInfo := s.createPeerInfo(knownPeer, s.conf.NodeName(), isBlocked)
+	if !isBlocked {
+		myPeerInfo.PeersAddrs = s.gossipPeersAddrs(remotePeer, oppositePeerInfo.PeersAddrsRequest)
+	}
 	err = protocol.SendStatus(stream, myPeerInfo)
 	if err != nil {
 		s.logger.Errorf("sending status info to %s as an answer: %v", peerID, err)
@@ -126,6 +164,11 @@ func (s *AuthStatus) ExchangeNewStatusInfo(ctx context.Context, remotePeerID pee
 
 	_, isBlocked := s.conf.GetBlockedPeer(remotePeerID.String())
 	myPeerInfo := s.createPeerInfo(knownPeer, s.conf.NodeName(), isBlocked)
+	var addrsRequest map[string]peer.ID
+	if !isBlocked {
+		addrsRequest = s.peersAddrsRequest(remotePeerID)
+		myPeerInfo.PeersAddrsRequest = slices.Collect(maps.Keys(addrsRequest))
+	}
 	timeStarted := time.Now()
 	err = protocol.SendStatus(stream, myPeerInfo)
 	if err != nil {
@@ -144,6 +187,7 @@ func (s *AuthStatus) ExchangeNewStatusInfo(ctx context.Context, remotePeerID pee
 	}
 
 	s.processPeerStatusInfo(remotePeerID.String(), oppositePeerInfo)
+	s.processGossipedAddrs(remotePeerID, addrsRequest, oppositePeerInfo.PeersAddrs)
 
 	return nil
 }
@@ -163,13 +207,26 @@ func (s *AuthStatus) createPeerInfo(peer config.KnownPeer, myPeerName string, de
 	}
 	s.conf.RLock()
 	vpnGatewayServerEnabled := s.conf.VPNGateway.ServerEnabled
+	relayServiceEnabled := s.conf.RelayService.Enabled
+	perms := s.conf.PeerPermissionsUnlocked(peer)
+	capabilities := s.capabilitiesUnlocked()
+	keyRotations := slices.Clone(s.conf.P2pNode.KeyRotations)
+	domainName := s.conf.P2pNode.DomainName
+	domainAliases := slices.Clone(s.conf.P2pNode.DomainAliases)
 	s.conf.RUnlock()
 
 	myPeerInfo := protocol.PeerStatusInfo{
 		Name:                    myPeerName,
-		AllowUsingAsExitNode:    peer.WeAllowUsingAsExitNode,
+		AllowUsingAsExitNode:    perms.AllowUsingAsExitNode,
 		VPNGatewayServerEnabled: vpnGatewayServerEnabled,
+		RelayServiceEnabled:     relayServiceEnabled,
+		AllowUsingAsRelay:       perms.AllowUsingAsRelay,
+		Capabilities:            &capabilities,
+		KeyRotations:            keyRotations,
+		DomainName:              domainName,
+		DomainAliases:           domainAliases,
 	}
+	myPeerInfo.Vouch = s.createVouch(peer, perms)
 
 	return myPeerInfo
 }
@@ -194,12 +251,17 @@ func (s *AuthStatus) processPeerStatusInfo(peerID string, peerInfo protocol.Peer
 		return
 	}
 
+	vouch := s.processVouch(peerID, peerInfo.Vouch)
+	announcedDomainName, announcedDomainAliases := sanitizeAnnouncedDomainNames(peerInfo.DomainName, peerInfo.DomainAliases)
 	var allowedUsingAsExitNode bool
 	s.conf.UpdatePeerFields(peerID, func(peer *config.KnownPeer) {
 		peer.LastSeen = time.Now()
 		peer.Name = peerInfo.Name
 		peer.Confirmed = true
 		peer.Declined = false
+		peer.Invite = ""
+		// empty vouch revokes the previous one
+		peer.Vouch = vouch
 		if peer.DomainName == "" {
 			peer.DomainName = awldns.TrimDomainName(peer.DisplayName())
 		}
@@ -208,6 +270,11 @@ func (s *AuthStatus) processPeerStatusInfo(peerID string, peerInfo protocol.Peer
 		}
 		peer.AllowedUsingAsExitNode = peerInfo.AllowUsingAsExitNode
 		peer.RemoteVPNGatewayServerEnabled = peerInfo.VPNGatewayServerEnabled
+		peer.RemoteRelayServiceEnabled = peerInfo.RelayServiceEnabled
+		peer.AllowedUsingAsRelay = peerInfo.AllowUsingAsRelay
+		peer.Capabilities = sanitizeCapabilities(peerInfo.Capabilities)
+		peer.AnnouncedDomainName = announcedDomainName
+		peer.AnnouncedDomainAliases = announcedDomainAliases
 		allowedUsingAsExitNode = peer.AllowedUsingAsExitNode
 	})
 
@@ -223,6 +290,82 @@ func (s *AuthStatus) processPeerStatusInfo(peerID string, peerInfo protocol.Peer
 	}
 }
 
+// peersAddrsRequest returns blinded IDs of known peers except remotePeerID, their addresses are requested from remotePeerID.
+// remotePeerID matches them with its own known peers, so it doesn't learn peers which it doesn't know.
+func (s *AuthStatus) peersAddrsRequest(remotePeerID peer.ID) map[string]peer.ID {
+	s.conf.RLock()
+	defer s.conf.RUnlock()
+	if !s.conf.P2pNode.EnableAddrsGossip {
+		return nil
+	}
+
+	request := make(map[string]peer.ID, len(s.conf.KnownPeers))
+	for peerID, knownPeer := range s.conf.KnownPeers {
+		if peerID != remotePeerID.String() {
+			request[protocol.BlindPeerID(s.conf.P2pNode.PeerID, remotePeerID.String(), peerID)] = knownPeer.PeerId()
+		}
+	}
+	return request
+}
+
+// gossipPeersAddrs returns working addresses of requested peers. Only addresses of our known peers are shared,
+// so the requester learns nothing about peers it doesn't know.
+func (s *AuthStatus) gossipPeersAddrs(remotePeerID peer.ID, request []string) map[string][]string {
+	if len(request) == 0 {
+		return nil
+	}
+	s.conf.RLock()
+	if !s.conf.P2pNode.EnableAddrsGossip {
+		s.conf.RUnlock()
+		return nil
+	}
+	knownPeers := make(map[string]peer.ID, len(s.conf.KnownPeers))
+	for peerID, knownPeer := range s.conf.KnownPeers {
+		if peerID != remotePeerID.String() {
+			knownPeers[protocol.BlindPeerID(remotePeerID.String(), s.conf.P2pNode.PeerID, peerID)] = knownPeer.PeerId()
+		}
+	}
+	s.conf.RUnlock()
+
+	peersAddrs := make(map[string][]string)
+	for _, blindedID := range request {
+		peerID, known := knownPeers[blindedID]
+		if !known {
+			continue
+		}
+		addrs := s.p2p.WorkingAddrs(peerID)
+		if len(addrs) == 0 {
+			continue
+		}
+		addrsStr := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			addrsStr = append(addrsStr, addr.String())
+		}
+		peersAddrs[blindedID] = addrsStr
+	}
+	return peersAddrs
+}
+
+// processGossipedAddrs adds addresses received from remotePeerID for peers which we requested.
+func (s *AuthStatus) processGossipedAddrs(remotePeerID peer.ID, request map[string]peer.ID, peersAddrs map[string][]string) {
+	for blindedID, addrsStr := range peersAddrs {
+		peerID, requested := request[blindedID]
+		if !requested {
+			s.logger.Warnf("peer %s sent addresses of not requested peer", remotePeerID)
+			continue
+		}
+		addrs := make([]multiaddr.Multiaddr, 0, len(addrsStr))
+		for _, addrStr := range addrsStr {
+			addr, err := multiaddr.NewMultiaddr(addrStr)
+			if err != nil {
+				continue
+			}
+			addrs = append(addrs, addr)
+		}
+		s.p2p.AddGossipedAddrs(peerID, addrs)
+	}
+}
+
 func (s *AuthStatus) AuthStreamHandler(stream network.Stream) {
 	metrics.PeersAuthRequestsReceivedTotal.Inc()
 	defer func() {
@@ -231,34 +374,70 @@ func (s *AuthStatus) AuthStreamHandler(stream network.Stream) {
 
 	remotePeer := stream.Conn().RemotePeer()
 	peerID := remotePeer.String()
-	authPeer, err := protocol.ReceiveAuth(stream)
+	_, isBlocked := s.conf.GetBlockedPeer(peerID)
+	_, confirmed := s.conf.GetPeer(peerID)
+	declined := isBlocked
+	stranger := !confirmed && !isBlocked
+	limits := s.conf.GetAuthRequestLimits()
+
+	if stranger {
+		if reason, allowed := s.authLimiter.allow(remotePeer, limits, time.Now()); !allowed {
+			s.dropAuthRequest(stream, reason, limits)
+			return
+		}
+	}
+
+	authPeer, err := protocol.ReceiveAuth(io.LimitReader(stream, maxAuthRequestSize))
 	if err != nil {
 		s.logger.Errorf("receiving auth from %s: %v", peerID, err)
 		return
 	}
 
-	_, isBlocked := s.conf.GetBlockedPeer(peerID)
-	_, confirmed := s.conf.GetPeer(peerID)
-	s.conf.RLock()
-	autoAccept := s.conf.P2pNode.AutoAcceptAuthRequests
-	s.conf.RUnlock()
-
-	if !confirmed && !isBlocked && !autoAccept {
-		s.authsLock.Lock()
-		s.ingoingAuths[remotePeer] = authPeer
-		s.authsLock.Unlock()
-		_ = s.authsEmitter.Emit(awlevent.ReceivedAuthRequest{
-			AuthPeer: authPeer,
-			PeerID:   peerID,
-		})
-	}
-	if !confirmed && !isBlocked && autoAccept {
-		defer func() {
-			_ = s.AddPeer(context.Background(), remotePeer, authPeer.Name, s.conf.GenUniqPeerAlias(authPeer.Name, ""), true, "")
-		}()
+	if stranger {
+		decision, invite := s.decideAuthRequest(remotePeer, authPeer)
+		if reason := s.strangerDropReason(remotePeer, authPeer, decision, invite.ID != "", limits); reason != "" {
+			s.dropAuthRequest(stream, reason, limits)
+			return
+		}
+		if decision.InviteID != "" {
+			_, err := s.conf.UseInvite(invite.ID)
+			if err != nil {
+				// invite is exhausted by concurrent request
+				decision.Rule = AuthRuleDefault
+				decision.Action = config.AuthRuleActionQueue
+				decision.InviteID = ""
+			} else {
+				metrics.PeersAuthInvitesTotal.WithLabelValues("accepted").Inc()
+			}
+		}
+		s.recordAuthDecision(decision)
+
+		switch decision.Action {
+		case config.AuthRuleActionAccept:
+			confirmed = true
+			defer func() {
+				_ = s.addPeer(context.Background(), config.KnownPeer{
+					PeerID:                 peerID,
+					Name:                   authPeer.Name,
+					Alias:                  s.conf.GenUniqPeerAlias(authPeer.Name, ""),
+					Confirmed:              true,
+					WeAllowUsingAsExitNode: decision.AllowUsingAsExitNode,
+					WeAllowUsingAsRelay:    decision.AllowUsingAsRelay,
+				})
+			}()
+		case config.AuthRuleActionReject:
+			declined = true
+		default:
+			if s.queueAuthRequest(remotePeer, authPeer, limits) {
+				_ = s.authsEmitter.Emit(awlevent.ReceivedAuthRequest{
+					AuthPeer: authPeer,
+					PeerID:   peerID,
+				})
+			}
+		}
 	}
 
-	authResponse := protocol.AuthPeerResponse{Confirmed: confirmed, Declined: isBlocked}
+	authResponse := protocol.AuthPeerResponse{Confirmed: confirmed, Declined: declined}
 	err = protocol.SendAuthResponse(stream, authResponse)
 	if err != nil {
 		s.logger.Errorf("sending auth response to %s as an answer: %v", peerID, err)
@@ -299,6 +478,15 @@ func (s *AuthStatus) SendAuthRequest(ctx context.Context, peerID peer.ID, req pr
 	}
 	s.p2p.RecordPeerLatency(peerID, time.Since(timeStarted))
 
+	if authResponse.ProofOfWorkBits > 0 && !authResponse.Confirmed && !authResponse.Declined &&
+		!protocol.VerifyProofOfWork(req.ProofOfWork, s.conf.P2pNode.PeerID, peerID.String(), authResponse.ProofOfWorkBits) {
+		req, err = s.solveAuthProofOfWork(ctx, peerID, req, authResponse.ProofOfWorkBits)
+		if err != nil {
+			return err
+		}
+		return s.SendAuthRequest(ctx, peerID, req)
+	}
+
 	if authResponse.Confirmed || authResponse.Declined {
 		s.authsLock.Lock()
 		delete(s.outgoingAuths, peerID)
@@ -317,8 +505,20 @@ func (s *AuthStatus) SendAuthRequest(ctx context.Context, peerID peer.ID, req pr
 }
 
 func (s *AuthStatus) AddPeer(ctx context.Context, peerID peer.ID, name, alias string, confirmed bool, ipAddr string) error {
-	peerIDStr := peerID.String()
-	alias = strings.TrimSpace(alias)
+	return s.addPeer(ctx, config.KnownPeer{
+		PeerID:    peerID.String(),
+		Name:      name,
+		Alias:     alias,
+		IPAddr:    ipAddr,
+		Confirmed: confirmed,
+	})
+}
+
+// addPeer saves newPeerConfig and sends auth request if the peer isn't confirmed yet. Empty IPAddr is generated.
+func (s *AuthStatus) addPeer(ctx context.Context, newPeerConfig config.KnownPeer) error {
+	peerIDStr := newPeerConfig.PeerID
+	peerID := newPeerConfig.PeerId()
+	newPeerConfig.Alias = strings.TrimSpace(newPeerConfig.Alias)
 
 	s.conf.RemoveBlockedPeer(peerIDStr)
 
@@ -327,27 +527,20 @@ func (s *AuthStatus) AddPeer(ctx context.Context, peerID peer.ID, name, alias st
 		s.conf.Unlock()
 		return fmt.Errorf("peer has already been added")
 	}
-	if !s.conf.IsUniqPeerAliasUnlocked("", alias) {
+	if !s.conf.IsUniqPeerAliasUnlocked("", newPeerConfig.Alias) {
 		s.conf.Unlock()
 		return fmt.Errorf("peer name is not unique")
 	}
-	if ipAddr != "" {
-		if err := s.conf.CheckIPUnique(ipAddr, peerIDStr); err != nil {
+	if newPeerConfig.IPAddr != "" {
+		if err := s.conf.CheckIPUnique(newPeerConfig.IPAddr, peerIDStr); err != nil {
 			s.conf.Unlock()
 			return err
 		}
 	} else {
-		ipAddr = s.conf.GenerateNextIpAddr()
+		newPeerConfig.IPAddr = s.conf.GenerateNextIpAddr()
 	}
 
-	newPeerConfig := config.KnownPeer{
-		PeerID:    peerIDStr,
-		Name:      name,
-		Alias:     alias,
-		IPAddr:    ipAddr,
-		Confirmed: confirmed,
-		CreatedAt: time.Now(),
-	}
+	newPeerConfig.CreatedAt = time.Now()
 	newPeerConfig.DomainName = awldns.TrimDomainName(newPeerConfig.DisplayName())
 	s.conf.UpsertPeerUnlocked(newPeerConfig)
 	s.conf.Unlock()
@@ -362,9 +555,11 @@ func (s *AuthStatus) AddPeer(ctx context.Context, peerID peer.ID, name, alias st
 	go func() {
 		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
-		if !confirmed {
+		if !newPeerConfig.Confirmed {
 			authPeer := protocol.AuthPeer{
-				Name: s.conf.NodeName(),
+				Name:    s.conf.NodeName(),
+				Invite:  newPeerConfig.Invite,
+				Vouches: s.ourVouches(),
 			}
 			_ = s.SendAuthRequest(ctx, peerID, authPeer)
 		}
@@ -439,12 +634,13 @@ func (s *AuthStatus) GetIngoingAuthRequests() map[string]protocol.AuthPeer {
 
 	result := make(map[string]protocol.AuthPeer, len(s.ingoingAuths))
 	for peerID, auth := range s.ingoingAuths {
-		result[peerID.String()] = auth
+		result[peerID.String()] = auth.AuthPeer
 	}
 	return result
 }
 
 func (s *AuthStatus) restoreOutgoingAuths() {
+	vouches := s.ourVouches()
 	s.conf.RLock()
 	defer s.conf.RUnlock()
 
@@ -453,7 +649,9 @@ func (s *AuthStatus) restoreOutgoingAuths() {
 	for _, knownPeer := range s.conf.KnownPeers {
 		if !knownPeer.Confirmed && !knownPeer.Declined {
 			outgoingAuths[knownPeer.PeerId()] = protocol.AuthPeer{
-				Name: peerName,
+				Name:    peerName,
+				Invite:  knownPeer.Invite,
+				Vouches: vouches,
 			}
 		}
 	}
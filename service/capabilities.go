@@ -0,0 +1,76 @@
+package service
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/protocol"
+)
+
+// maxCapabilitiesItems limits stored features and services of remote peer.
+const maxCapabilitiesItems = 64
+
+// Capabilities returns our capabilities sent to known peers with status info.
+func (s *AuthStatus) Capabilities() protocol.PeerCapabilities {
+	s.conf.RLock()
+	defer s.conf.RUnlock()
+	return s.capabilitiesUnlocked()
+}
+
+// capabilitiesUnlocked returns our capabilities sent with status info; the caller must hold the config lock.
+func (s *AuthStatus) capabilitiesUnlocked() protocol.PeerCapabilities {
+	var services []string
+	if s.conf.SOCKS5.ProxyingEnabled {
+		services = append(services, protocol.ServiceSOCKS5)
+	}
+	if s.conf.VPNGateway.ServerEnabled {
+		services = append(services, protocol.ServiceVPNGateway)
+	}
+	if s.conf.RelayService.Enabled {
+		services = append(services, protocol.ServiceRelay)
+	}
+
+	return protocol.PeerCapabilities{
+		Version:            config.Version,
+		OS:                 runtime.GOOS,
+		Arch:               runtime.GOARCH,
+		ProtocolVersion:    protocol.ProtocolVersion,
+		MinProtocolVersion: protocol.MinProtocolVersion,
+		Features:           protocol.SupportedFeatures,
+		Services:           services,
+		DeviceInfo:         s.conf.P2pNode.DeviceInfo,
+	}
+}
+
+// sanitizeCapabilities limits capabilities received from remote peer before storing them in config.
+func sanitizeCapabilities(capabilities *protocol.PeerCapabilities) *protocol.PeerCapabilities {
+	if capabilities == nil {
+		return nil
+	}
+	result := *capabilities
+	result.Features = slices.Clone(result.Features[:min(len(result.Features), maxCapabilitiesItems)])
+	result.Services = slices.Clone(result.Services[:min(len(result.Services), maxCapabilitiesItems)])
+	if len(result.DeviceInfo) > protocol.MaxDeviceInfoLen {
+		result.DeviceInfo = strings.ToValidUTF8(result.DeviceInfo[:protocol.MaxDeviceInfoLen], "")
+	}
+	return &result
+}
+
+// sanitizeAnnouncedDomainNames drops invalid and duplicate domain names received from remote peer.
+func sanitizeAnnouncedDomainNames(name string, aliases []string) (string, []string) {
+	if !config.IsAnnounceableDomainName(name) {
+		return "", nil
+	}
+	var result []string
+	for _, alias := range aliases {
+		if len(result) == protocol.MaxDomainAliases {
+			break
+		}
+		if alias != name && config.IsAnnounceableDomainName(alias) && !slices.Contains(result, alias) {
+			result = append(result, alias)
+		}
+	}
+	return name, result
+}
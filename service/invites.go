@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/protocol"
+)
+
+const (
+	DefaultInviteTTL = 24 * time.Hour
+	MaxInviteTTL     = 30 * 24 * time.Hour
+
+	// maxInviteAddrs keeps invite token short enough for QR code.
+	maxInviteAddrs = 8
+)
+
+type InviteOptions struct {
+	TTL     time.Duration
+	MaxUses int
+	// Addrs of our node are included in the token, so the holder can dial us before DHT lookup.
+	Addrs                []multiaddr.Multiaddr
+	AllowUsingAsExitNode bool
+	AllowUsingAsRelay    bool
+}
+
+// CreateInvite issues signed invite token and saves it to config. Peers which send it with auth request
+// are accepted automatically with preset permissions until the invite expires, is exhausted or revoked.
+func (s *AuthStatus) CreateInvite(opts InviteOptions) (config.Invite, error) {
+	if opts.TTL <= 0 || opts.TTL > MaxInviteTTL {
+		return config.Invite{}, fmt.Errorf("invite ttl must be positive and not greater than %s", MaxInviteTTL)
+	}
+	if opts.MaxUses <= 0 {
+		return config.Invite{}, errors.New("invite max uses must be positive")
+	}
+	privKey, err := crypto.UnmarshalEd25519PrivateKey(s.conf.PrivKey())
+	if err != nil {
+		return config.Invite{}, fmt.Errorf("load identity: %v", err)
+	}
+	idBytes := make([]byte, 8)
+	_, err = rand.Read(idBytes)
+	if err != nil {
+		return config.Invite{}, err
+	}
+
+	now := time.Now()
+	token := protocol.InviteToken{
+		ID:                   hex.EncodeToString(idBytes),
+		PeerID:               s.conf.P2pNode.PeerID,
+		Name:                 s.conf.NodeName(),
+		ExpiresAt:            now.Add(opts.TTL).UTC().Truncate(time.Second),
+		MaxUses:              opts.MaxUses,
+		AllowUsingAsExitNode: opts.AllowUsingAsExitNode,
+		AllowUsingAsRelay:    opts.AllowUsingAsRelay,
+	}
+	for _, addr := range opts.Addrs[:min(len(opts.Addrs), maxInviteAddrs)] {
+		token.Addrs = append(token.Addrs, addr.String())
+	}
+	encoded, err := protocol.EncodeInviteToken(token, privKey)
+	if err != nil {
+		return config.Invite{}, fmt.Errorf("sign invite: %v", err)
+	}
+
+	invite := config.Invite{
+		ID:                   token.ID,
+		Token:                encoded,
+		CreatedAt:            now,
+		ExpiresAt:            token.ExpiresAt,
+		MaxUses:              token.MaxUses,
+		AllowUsingAsExitNode: token.AllowUsingAsExitNode,
+		AllowUsingAsRelay:    token.AllowUsingAsRelay,
+	}
+	s.conf.AddInvite(invite)
+
+	return invite, nil
+}
+
+// JoinInvite adds the inviting peer and sends auth request with the invite, the peer accepts it automatically.
+// Empty alias is replaced with the peer name after status exchange.
+func (s *AuthStatus) JoinInvite(ctx context.Context, invite, alias, ipAddr string) (peer.ID, error) {
+	token, err := protocol.ParseInviteToken(invite)
+	if err != nil {
+		return "", err
+	}
+	if token.Expired(time.Now()) {
+		return "", errors.New("invite expired")
+	}
+	if token.PeerID == s.conf.P2pNode.PeerID {
+		return "", errors.New("you can't join your own invite")
+	}
+	peerID, err := peer.Decode(token.PeerID)
+	if err != nil {
+		return "", err
+	}
+	if alias == "" {
+		alias = s.conf.GenUniqPeerAlias(token.Name, "")
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0, len(token.Addrs))
+	for _, addrStr := range token.Addrs {
+		addr, err := config.ParseStaticAddr(addrStr, peerID)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	s.p2p.AddGossipedAddrs(peerID, addrs)
+
+	err = s.addPeer(ctx, config.KnownPeer{
+		PeerID: peerID.String(),
+		Name:   token.Name,
+		Alias:  alias,
+		IPAddr: ipAddr,
+		Invite: invite,
+	})
+	if err != nil {
+		return "", err
+	}
+	return peerID, nil
+}
+
+// validInvite verifies that the invite is issued by us and it's not expired, exhausted or revoked.
+func (s *AuthStatus) validInvite(invite string) (config.Invite, error) {
+	token, err := protocol.ParseInviteToken(invite)
+	if err != nil {
+		return config.Invite{}, err
+	}
+	if token.PeerID != s.conf.P2pNode.PeerID {
+		return config.Invite{}, errors.New("invite is issued by another peer")
+	}
+	configInvite, ok := s.conf.GetInvite(token.ID)
+	if !ok {
+		return config.Invite{}, errors.New("invite not found, expired or revoked")
+	}
+	return configInvite, nil
+}
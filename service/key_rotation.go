@@ -0,0 +1,114 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/metrics"
+	"github.com/anywherelan/awl/protocol"
+)
+
+// maxKeyRotationsReceived limits rotations verified from one status info.
+const maxKeyRotationsReceived = 16
+
+// RotateIdentity generates a new identity key which is used after restart. The rotation is signed by the latest key
+// and sent to known peers with status info, they migrate us to the new peer ID when we connect to them after restart.
+func (s *AuthStatus) RotateIdentity() (peer.ID, error) {
+	s.rotationLock.Lock()
+	defer s.rotationLock.Unlock()
+
+	oldKey, err := crypto.UnmarshalEd25519PrivateKey(s.conf.LatestPrivKey())
+	if err != nil {
+		return "", fmt.Errorf("load identity: %v", err)
+	}
+	oldPeerID, err := peer.IDFromPrivateKey(oldKey)
+	if err != nil {
+		return "", err
+	}
+	newKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("generate identity: %v", err)
+	}
+	newPeerID, err := peer.IDFromPrivateKey(newKey)
+	if err != nil {
+		return "", err
+	}
+
+	rotation, err := protocol.EncodeKeyRotation(protocol.KeyRotation{
+		OldPeerID: oldPeerID.String(),
+		NewPeerID: newPeerID.String(),
+		IssuedAt:  time.Now().UTC().Truncate(time.Second),
+	}, oldKey)
+	if err != nil {
+		return "", fmt.Errorf("sign key rotation: %v", err)
+	}
+	err = s.conf.AddKeyRotation(newKey, rotation)
+	if err != nil {
+		return "", fmt.Errorf("save identity: %v", err)
+	}
+	s.logger.Infof("identity key rotated from %s to %s, it will be used after restart", oldPeerID, newPeerID)
+
+	return newPeerID, nil
+}
+
+// processKeyRotations migrates the known peer to the sender if the sender is its new peer ID.
+// Peers are migrated only when they connect with the new peer ID, so announcements of the next peer ID
+// are ignored and we keep connectivity with the old peer ID until restart.
+// It returns the known peer with the sender's peer ID.
+func (s *AuthStatus) processKeyRotations(senderID string, encoded []string) (config.KnownPeer, bool) {
+	if len(encoded) == 0 {
+		return config.KnownPeer{}, false
+	}
+	rotations := make(map[string]string)
+	for _, rotationStr := range encoded[:min(len(encoded), maxKeyRotationsReceived)] {
+		rotation, err := protocol.ParseKeyRotation(rotationStr)
+		if err != nil {
+			s.logger.Warnf("peer %s sent invalid key rotation: %v", senderID, err)
+			continue
+		}
+		rotations[rotation.OldPeerID] = rotation.NewPeerID
+	}
+	// the sender could have pending rotations which are applied after its next restart
+	rotatedTo := func(peerID, newPeerID string) bool {
+		for range len(rotations) {
+			nextPeerID, exists := rotations[peerID]
+			if !exists {
+				return false
+			}
+			if nextPeerID == newPeerID {
+				return true
+			}
+			peerID = nextPeerID
+		}
+		return false
+	}
+
+	for oldPeerID := range rotations {
+		if _, known := s.conf.GetPeer(oldPeerID); !known || !rotatedTo(oldPeerID, senderID) {
+			continue
+		}
+		return s.migratePeer(oldPeerID, senderID)
+	}
+	return config.KnownPeer{}, false
+}
+
+func (s *AuthStatus) migratePeer(oldPeerID, newPeerID string) (config.KnownPeer, bool) {
+	knownPeer, err := s.conf.MigratePeer(oldPeerID, newPeerID)
+	if err != nil {
+		metrics.PeersKeyRotationsTotal.WithLabelValues("rejected").Inc()
+		s.logger.Warnf("migrate peer %s to %s: %v", oldPeerID, newPeerID, err)
+		return config.KnownPeer{}, false
+	}
+	metrics.PeersKeyRotationsTotal.WithLabelValues("migrated").Inc()
+	s.logger.Infof("peer %s (%s) rotated its key, migrated to %s", knownPeer.DisplayName(), oldPeerID, newPeerID)
+
+	if oldID, err := peer.Decode(oldPeerID); err == nil {
+		s.p2p.UnprotectPeer(oldID)
+	}
+	return knownPeer, true
+}
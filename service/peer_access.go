@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/anywherelan/awl/awlevent"
+	"github.com/anywherelan/awl/config"
+)
+
+const backgroundEnforcePeerAccessInterval = 10 * time.Second
+
+type peerAccessState struct {
+	suspended         bool
+	exitNodeSuspended bool
+}
+
+// BackgroundEnforcePeerAccess tracks expiry and schedules of known peers access. Permissions are checked on every
+// request anyway, this task emits awlevent.PeerAccessChanged on changes, so tunnel and DNS are refreshed,
+// notifies peers about changed permissions and removes or blocks peers with expired access.
+func (s *AuthStatus) BackgroundEnforcePeerAccess(ctx context.Context) {
+	ticker := time.NewTicker(backgroundEnforcePeerAccessInterval)
+	defer ticker.Stop()
+
+	states := s.enforcePeerAccess(ctx, nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			states = s.enforcePeerAccess(ctx, states)
+		}
+	}
+}
+
+// enforcePeerAccess emits events for peers with state changed since prevStates, changes aren't emitted on the first run.
+func (s *AuthStatus) enforcePeerAccess(ctx context.Context, prevStates map[string]peerAccessState) map[string]peerAccessState {
+	now := time.Now()
+	states := make(map[string]peerAccessState)
+	var expired []config.KnownPeer
+
+	s.conf.RLock()
+	for peerID, knownPeer := range s.conf.KnownPeers {
+		onExpire := knownPeer.Access.OnExpire
+		if knownPeer.Access.Expired(now) && (onExpire == config.AccessOnExpireRemove || onExpire == config.AccessOnExpireBlock) {
+			expired = append(expired, knownPeer)
+			continue
+		}
+		states[peerID] = peerAccessState{
+			suspended:         !knownPeer.Access.Allowed(now),
+			exitNodeSuspended: !knownPeer.ExitNodeAccess.Allowed(now),
+		}
+	}
+	s.conf.RUnlock()
+
+	for _, knownPeer := range expired {
+		s.removeExpiredPeer(knownPeer)
+	}
+	if prevStates == nil {
+		return states
+	}
+
+	for peerID, state := range states {
+		prevState, exists := prevStates[peerID]
+		if !exists || prevState == state {
+			continue
+		}
+		s.logger.Infof("access of peer %s changed: suspended %t, exit node suspended %t", peerID, state.suspended, state.exitNodeSuspended)
+		s.emitPeerAccessChanged(awlevent.PeerAccessChanged{
+			PeerID:            peerID,
+			Suspended:         state.suspended,
+			ExitNodeSuspended: state.exitNodeSuspended,
+		})
+
+		knownPeer, known := s.conf.GetPeer(peerID)
+		if !known {
+			continue
+		}
+		go func() {
+			_ = s.ExchangeNewStatusInfo(ctx, knownPeer.PeerId(), knownPeer)
+		}()
+	}
+
+	return states
+}
+
+func (s *AuthStatus) removeExpiredPeer(knownPeer config.KnownPeer) {
+	removedPeer, exists := s.conf.RemovePeer(knownPeer.PeerID)
+	if !exists {
+		return
+	}
+	peerID, err := peer.Decode(removedPeer.PeerID)
+	if err != nil {
+		s.logger.Errorf("decode expired peer id %s: %v", removedPeer.PeerID, err)
+		return
+	}
+	s.logger.Infof("access of peer %s (%s) expired, action: %s", removedPeer.DisplayName(), removedPeer.PeerID, removedPeer.Access.OnExpire)
+
+	s.p2p.UnprotectPeer(peerID)
+	if removedPeer.Access.OnExpire == config.AccessOnExpireBlock {
+		s.BlockPeer(peerID, removedPeer.DisplayName())
+	}
+	s.emitPeerAccessChanged(awlevent.PeerAccessChanged{
+		PeerID:            removedPeer.PeerID,
+		Suspended:         true,
+		ExitNodeSuspended: true,
+		Removed:           true,
+	})
+}
+
+func (s *AuthStatus) emitPeerAccessChanged(event awlevent.PeerAccessChanged) {
+	err := s.accessEmitter.Emit(event)
+	if err != nil {
+		s.logger.Errorf("emit peer access changed: %v", err)
+	}
+}
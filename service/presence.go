@@ -0,0 +1,443 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/anywherelan/awl/awlevent"
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+)
+
+const (
+	PresenceConnectionDirect = "direct"
+	PresenceConnectionRelay  = "relay"
+
+	backgroundSavePresenceInterval = time.Minute
+	presenceWebhookTimeout         = 10 * time.Second
+)
+
+// presenceHistory is stored in config.PresenceHistoryFilename.
+type presenceHistory struct {
+	// Since is the time when history was started
+	Since time.Time                         `json:"since"`
+	Peers map[string][]entity.PresenceEvent `json:"peers"`
+}
+
+type peerPresenceState struct {
+	online        bool
+	connection    string
+	addressFamily string
+	// notifiedOnline is the state of the last notification, notifyGen invalidates scheduled notifications
+	notifiedOnline bool
+	notifyGen      int
+}
+
+// presenceWebhook is a body of requests to config.PresenceConfig WebhookURLs.
+type presenceWebhook struct {
+	Event         string    `json:"event"`
+	PeerID        string    `json:"peerId"`
+	Name          string    `json:"name"`
+	Time          time.Time `json:"time"`
+	Connection    string    `json:"connection,omitempty"`
+	AddressFamily string    `json:"addressFamily,omitempty"`
+}
+
+// Presence keeps history of known peers going online and offline and notifies about it
+// peers with enabled config.PresenceNotify.
+type Presence struct {
+	logger  *log.ZapEventLogger
+	p2p     P2p
+	conf    *config.Config
+	emitter awlevent.Emitter
+	client  *http.Client
+
+	lock    sync.Mutex
+	history presenceHistory
+	states  map[string]*peerPresenceState
+	dirty   bool
+	closed  bool
+}
+
+func NewPresence(p2pService P2p, conf *config.Config, eventbus awlevent.Bus) *Presence {
+	emitter, err := eventbus.Emitter(new(awlevent.PeerPresenceChanged))
+	if err != nil {
+		panic(err)
+	}
+
+	presence := &Presence{
+		logger:  log.Logger("awl/service/presence"),
+		p2p:     p2pService,
+		conf:    conf,
+		emitter: emitter,
+		client:  &http.Client{Timeout: presenceWebhookTimeout},
+		states:  make(map[string]*peerPresenceState),
+	}
+	presence.loadHistory()
+	p2pService.SubscribeConnectionEvents(presence.onConnectionChanged, presence.onConnectionChanged)
+	return presence
+}
+
+// BackgroundSaveHistory periodically prunes old events and saves history to file.
+func (p *Presence) BackgroundSaveHistory(ctx context.Context) {
+	ticker := time.NewTicker(backgroundSavePresenceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.lock.Lock()
+			p.pruneUnlocked(time.Now())
+			if p.dirty && !p.closed {
+				p.saveUnlocked()
+			}
+			p.lock.Unlock()
+		}
+	}
+}
+
+// Close records online peers as offline and saves history.
+func (p *Presence) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+
+	now := time.Now()
+	for peerID, state := range p.states {
+		state.notifyGen++
+		if state.online {
+			p.addEventUnlocked(peerID, entity.PresenceEvent{Time: now})
+		}
+	}
+	p.saveUnlocked()
+}
+
+// PeerPresence returns history of the known peer.
+func (p *Presence) PeerPresence(peerID string) entity.PeerPresence {
+	now := time.Now()
+	p.lock.Lock()
+	events := slices.Clone(p.history.Peers[peerID])
+	since := p.history.Since
+	state, exists := p.states[peerID]
+	online := exists && state.online
+	p.lock.Unlock()
+
+	retention := time.Duration(p.conf.GetPresenceConfig().RetentionDays) * 24 * time.Hour
+	if since.Before(now.Add(-retention)) {
+		since = now.Add(-retention)
+	}
+	events = slices.DeleteFunc(events, func(event entity.PresenceEvent) bool {
+		return event.Time.Before(since)
+	})
+	if events == nil {
+		events = make([]entity.PresenceEvent, 0)
+	}
+
+	return entity.PeerPresence{
+		PeerID: peerID,
+		Online: online,
+		Since:  since,
+		Uptime: p.PeerUptime(peerID),
+		Events: events,
+	}
+}
+
+// PeerUptime returns percentages of time the peer was online.
+func (p *Presence) PeerUptime(peerID string) entity.PresenceUptime {
+	now := time.Now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	events := p.history.Peers[peerID]
+	return entity.PresenceUptime{
+		Day:   presenceUptime(events, p.history.Since, now, 24*time.Hour),
+		Week:  presenceUptime(events, p.history.Since, now, 7*24*time.Hour),
+		Month: presenceUptime(events, p.history.Since, now, 30*24*time.Hour),
+	}
+}
+
+// SendWebhooks delivers notification to config.PresenceConfig WebhookURLs.
+func (p *Presence) SendWebhooks(ctx context.Context, event awlevent.PeerPresenceChanged) {
+	webhook := presenceWebhook{
+		Event:         "offline",
+		PeerID:        event.PeerID,
+		Name:          event.DisplayName,
+		Time:          event.Time,
+		Connection:    event.Connection,
+		AddressFamily: event.AddressFamily,
+	}
+	if event.Online {
+		webhook.Event = "online"
+	}
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		p.logger.Errorf("marshal presence webhook: %v", err)
+		return
+	}
+
+	for _, webhookURL := range p.conf.GetPresenceConfig().WebhookURLs {
+		err := p.sendWebhook(ctx, webhookURL, body)
+		if err != nil {
+			p.logger.Warnf("send presence webhook of peer %s: %v", event.PeerID, err)
+		}
+	}
+}
+
+func (p *Presence) sendWebhook(ctx context.Context, webhookURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s responded with status %s", webhookURL, resp.Status)
+	}
+	return nil
+}
+
+// RefreshKnownPeers updates presence of known peers, it's needed for new peers which were connected
+// before they became known.
+func (p *Presence) RefreshKnownPeers() {
+	for _, peerID := range p.conf.KnownPeersIds() {
+		p.refreshPeer(peerID)
+	}
+}
+
+func (p *Presence) onConnectionChanged(_ network.Network, conn network.Conn) {
+	remotePeer := conn.RemotePeer()
+	if _, known := p.conf.GetPeer(remotePeer.String()); !known {
+		return
+	}
+	p.refreshPeer(remotePeer)
+}
+
+// refreshPeer updates presence of known peer: it's online while it has at least one connection,
+// direct connections are preferred.
+func (p *Presence) refreshPeer(remotePeer peer.ID) {
+	peerID := remotePeer.String()
+	notifyDelay := time.Duration(p.conf.GetPresenceConfig().NotifyDelaySec) * time.Second
+
+	// connections are read under the lock, so concurrent updates can't apply outdated state
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	online, connection, addressFamily := presenceConnection(p.p2p.ConnsToPeer(remotePeer))
+	now := time.Now()
+	state, exists := p.states[peerID]
+	if !exists {
+		state = &peerPresenceState{}
+		p.states[peerID] = state
+	}
+	if state.online == online && state.connection == connection && state.addressFamily == addressFamily {
+		return
+	}
+	onlineChanged := state.online != online
+	state.online, state.connection, state.addressFamily = online, connection, addressFamily
+	p.addEventUnlocked(peerID, entity.PresenceEvent{
+		Time:          now,
+		Online:        online,
+		Connection:    connection,
+		AddressFamily: addressFamily,
+	})
+
+	if onlineChanged {
+		state.notifyGen++
+		if state.online != state.notifiedOnline {
+			gen := state.notifyGen
+			time.AfterFunc(notifyDelay, func() {
+				p.notify(remotePeer, gen)
+			})
+		}
+	}
+}
+
+// notify emits awlevent.PeerPresenceChanged if the peer is still in the same state and notifications are enabled.
+func (p *Presence) notify(peerID peer.ID, gen int) {
+	p.lock.Lock()
+	state := p.states[peerID.String()]
+	if p.closed || state == nil || state.notifyGen != gen || state.online == state.notifiedOnline {
+		p.lock.Unlock()
+		return
+	}
+	state.notifiedOnline = state.online
+	event := awlevent.PeerPresenceChanged{
+		PeerID:        peerID.String(),
+		Online:        state.online,
+		Connection:    state.connection,
+		AddressFamily: state.addressFamily,
+		Time:          time.Now(),
+	}
+	p.lock.Unlock()
+
+	knownPeer, known := p.conf.GetPeer(peerID.String())
+	if !known || event.Online && !knownPeer.PresenceNotify.Online || !event.Online && !knownPeer.PresenceNotify.Offline {
+		return
+	}
+	event.DisplayName = knownPeer.DisplayName()
+	p.logger.Infof("notify that peer '%s' is online: %t", event.DisplayName, event.Online)
+	err := p.emitter.Emit(event)
+	if err != nil {
+		p.logger.Errorf("emit peer presence changed: %v", err)
+	}
+}
+
+func (p *Presence) addEventUnlocked(peerID string, event entity.PresenceEvent) {
+	p.history.Peers[peerID] = append(p.history.Peers[peerID], event)
+	p.dirty = true
+}
+
+// pruneUnlocked removes history of unknown peers and events older than retention.
+// The last older event is kept, so the state of the peer at the beginning of retention is known.
+func (p *Presence) pruneUnlocked(now time.Time) {
+	retention := time.Duration(p.conf.GetPresenceConfig().RetentionDays) * 24 * time.Hour
+	cutoff := now.Add(-retention)
+	for peerID, events := range p.history.Peers {
+		if _, known := p.conf.GetPeer(peerID); !known {
+			delete(p.history.Peers, peerID)
+			delete(p.states, peerID)
+			p.dirty = true
+			continue
+		}
+		old := 0
+		for old < len(events) && events[old].Time.Before(cutoff) {
+			old++
+		}
+		if old > 1 {
+			p.history.Peers[peerID] = slices.Delete(events, 0, old-1)
+			p.dirty = true
+		}
+	}
+}
+
+func (p *Presence) loadHistory() {
+	p.history = presenceHistory{Since: time.Now(), Peers: make(map[string][]entity.PresenceEvent)}
+	path := filepath.Join(p.conf.DataDir(), config.PresenceHistoryFilename)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		p.logger.Errorf("read presence history: %v", err)
+		return
+	}
+	history := presenceHistory{}
+	err = json.Unmarshal(data, &history)
+	if err != nil {
+		p.logger.Errorf("invalid presence history file: %v", err)
+		return
+	}
+	if history.Peers == nil {
+		history.Peers = make(map[string][]entity.PresenceEvent)
+	}
+
+	// the node wasn't closed properly, peers are offline since the last save
+	stat, err := os.Stat(path)
+	if err == nil {
+		for peerID, events := range history.Peers {
+			if len(events) > 0 && events[len(events)-1].Online {
+				history.Peers[peerID] = append(events, entity.PresenceEvent{Time: stat.ModTime()})
+			}
+		}
+	}
+	p.history = history
+}
+
+func (p *Presence) saveUnlocked() {
+	data, err := json.Marshal(p.history)
+	if err != nil {
+		p.logger.Errorf("marshal presence history: %v", err)
+		return
+	}
+	path := filepath.Join(p.conf.DataDir(), config.PresenceHistoryFilename)
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		p.logger.Errorf("save presence history: %v", err)
+		return
+	}
+	config.ChownFileIfNeeded(path)
+	p.dirty = false
+}
+
+// presenceConnection returns the best connection to the peer: direct one if it exists.
+func presenceConnection(conns []network.Conn) (online bool, connection, addressFamily string) {
+	for _, conn := range conns {
+		relayed := isRelayedConn(conn)
+		if online && relayed {
+			continue
+		}
+		online = true
+		connection = PresenceConnectionDirect
+		if relayed {
+			connection = PresenceConnectionRelay
+		}
+		addressFamily = ""
+		if _, err := conn.RemoteMultiaddr().ValueForProtocol(multiaddr.P_IP4); err == nil {
+			addressFamily = "ip4"
+		} else if _, err := conn.RemoteMultiaddr().ValueForProtocol(multiaddr.P_IP6); err == nil {
+			addressFamily = "ip6"
+		}
+		if !relayed {
+			break
+		}
+	}
+	return online, connection, addressFamily
+}
+
+// presenceUptime returns percentage of time the peer was online during period before now.
+// Time before history started isn't counted.
+func presenceUptime(events []entity.PresenceEvent, since, now time.Time, period time.Duration) float64 {
+	from := now.Add(-period)
+	if from.Before(since) {
+		from = since
+	}
+	if !now.After(from) {
+		return 0
+	}
+
+	var onlineTime time.Duration
+	online := false
+	onlineFrom := from
+	for _, event := range events {
+		eventTime := event.Time
+		if eventTime.Before(from) {
+			eventTime = from
+		}
+		if online && !event.Online {
+			onlineTime += eventTime.Sub(onlineFrom)
+		} else if !online && event.Online {
+			onlineFrom = eventTime
+		}
+		online = event.Online
+	}
+	if online {
+		onlineTime += now.Sub(onlineFrom)
+	}
+
+	return min(100, float64(onlineTime)/float64(now.Sub(from))*100)
+}
@@ -126,13 +126,13 @@ func (s *SOCKS5) ProxyStreamHandler(stream network.Stream) {
 
 	remotePeer := stream.Conn().RemotePeer()
 	peerID := remotePeer.String()
-	knownPeer, known := s.conf.GetPeer(peerID)
+	perms, known := s.conf.PeerPermissions(peerID)
 	if !known {
 		metrics.SOCKS5ErrorsTotal.WithLabelValues("server", "denied").Inc()
 		s.logger.Infof("Unknown peer %s tried to socks5 proxy", peerID)
 		return
 	}
-	if !knownPeer.WeAllowUsingAsExitNode {
+	if !perms.AllowUsingAsExitNode {
 		metrics.SOCKS5ErrorsTotal.WithLabelValues("server", "denied").Inc()
 		s.logger.Infof("Peer %s without rights tried to socks5 proxy", peerID)
 		return
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/anywherelan/awl/config"
+	"github.com/anywherelan/awl/entity"
+	"github.com/anywherelan/awl/protocol"
+)
+
+const (
+	SpeedTestDirectionUpload        = "upload"
+	SpeedTestDirectionDownload      = "download"
+	SpeedTestDirectionBidirectional = "bidirectional"
+
+	speedTestChunkSize     = 32 << 10
+	speedTestProbeInterval = 100 * time.Millisecond
+	// speedTestProbeTimeout - probes echoed later than this are counted as lost.
+	speedTestProbeTimeout = 2 * time.Second
+	// speedTestGracePeriod is added to stream deadlines to let the other side finish sending.
+	speedTestGracePeriod    = 5 * time.Second
+	speedTestHandshakeLimit = 10 * time.Second
+	// bidirectional test from a single peer uses 3 streams.
+	speedTestMaxIncomingStreams = 6
+)
+
+type SpeedTest struct {
+	logger *log.ZapEventLogger
+	p2p    P2p
+	conf   *config.Config
+
+	incomingStreams atomic.Int32
+}
+
+func NewSpeedTest(p2pService P2p, conf *config.Config) *SpeedTest {
+	return &SpeedTest{
+		logger: log.Logger("awl/service/speedtest"),
+		p2p:    p2pService,
+		conf:   conf,
+	}
+}
+
+// StreamHandler serves speed tests requested by known peers, applying limits from config.SpeedTestConfig.
+func (s *SpeedTest) StreamHandler(stream network.Stream) {
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	peerID := stream.Conn().RemotePeer()
+	_ = stream.SetDeadline(time.Now().Add(speedTestHandshakeLimit))
+	request, err := protocol.ReceiveSpeedTestRequest(stream)
+	if err != nil {
+		s.logger.Warnf("read speed test request from %s: %v", peerID, err)
+		return
+	}
+
+	response := s.checkIncomingRequest(peerID, request)
+	if response.Accepted {
+		defer s.incomingStreams.Add(-1)
+		if s.incomingStreams.Add(1) > speedTestMaxIncomingStreams {
+			response = protocol.SpeedTestResponse{Reason: "too many speed tests in progress"}
+		}
+	}
+	err = protocol.SendSpeedTestResponse(stream, response)
+	if err != nil {
+		s.logger.Warnf("send speed test response to %s: %v", peerID, err)
+		return
+	}
+	if !response.Accepted {
+		s.logger.Infof("refused %s speed test from %s: %s", request.Mode, peerID, response.Reason)
+		return
+	}
+
+	_ = stream.SetDeadline(time.Now().Add(response.Duration + speedTestProbeTimeout + speedTestGracePeriod))
+	switch request.Mode {
+	case protocol.SpeedTestModeUpload:
+		var report protocol.SpeedTestReport
+		report.Bytes, report.Elapsed, err = readSpeedTestData(stream, response.MaxRate)
+		if err == nil {
+			err = protocol.SendSpeedTestReport(stream, report)
+		}
+	case protocol.SpeedTestModeDownload:
+		_, err = writeSpeedTestData(stream, response.Duration, response.MaxRate)
+	case protocol.SpeedTestModeLatency:
+		err = echoSpeedTestProbes(stream)
+	}
+	if err != nil {
+		s.logger.Warnf("%s speed test with %s: %v", request.Mode, peerID, err)
+		_ = stream.Reset()
+	}
+}
+
+func (s *SpeedTest) checkIncomingRequest(peerID peer.ID, request protocol.SpeedTestRequest) protocol.SpeedTestResponse {
+	s.conf.RLock()
+	defer s.conf.RUnlock()
+
+	if _, known := s.conf.GetPeerUnlocked(peerID.String()); !known {
+		return protocol.SpeedTestResponse{Reason: "unknown peer"}
+	}
+	if s.conf.SpeedTest.DisableIncoming {
+		return protocol.SpeedTestResponse{Reason: "speed tests are disabled"}
+	}
+	switch request.Mode {
+	case protocol.SpeedTestModeUpload, protocol.SpeedTestModeDownload, protocol.SpeedTestModeLatency:
+	default:
+		return protocol.SpeedTestResponse{Reason: fmt.Sprintf("unsupported mode %q", request.Mode)}
+	}
+
+	maxDuration := time.Duration(s.conf.SpeedTest.MaxDurationSec) * time.Second
+	duration := request.Duration
+	if duration <= 0 || duration > maxDuration {
+		duration = maxDuration
+	}
+
+	return protocol.SpeedTestResponse{
+		Accepted: true,
+		Duration: duration,
+		MaxRate:  int64(s.conf.SpeedTest.MaxRateMbps) * 1_000_000 / 8,
+	}
+}
+
+// Run measures throughput in given direction together with latency under load.
+// It uses the same stream setup as the tunnel, so the result reflects the actual VPN traffic path.
+func (s *SpeedTest) Run(ctx context.Context, peerID peer.ID, direction string, duration time.Duration) (entity.SpeedTestResult, error) {
+	var modes []string
+	switch direction {
+	case SpeedTestDirectionUpload:
+		modes = []string{protocol.SpeedTestModeUpload}
+	case SpeedTestDirectionDownload:
+		modes = []string{protocol.SpeedTestModeDownload}
+	case SpeedTestDirectionBidirectional:
+		modes = []string{protocol.SpeedTestModeUpload, protocol.SpeedTestModeDownload}
+	default:
+		return entity.SpeedTestResult{}, fmt.Errorf("unknown direction %q", direction)
+	}
+	modes = append(modes, protocol.SpeedTestModeLatency)
+
+	result := entity.SpeedTestResult{
+		PeerID:    peerID.String(),
+		Direction: direction,
+		Duration:  duration,
+	}
+	streams := make(map[string]network.Stream, len(modes))
+	defer func() {
+		for _, stream := range streams {
+			_ = stream.Close()
+		}
+	}()
+	for _, mode := range modes {
+		stream, response, err := s.openStream(ctx, peerID, mode, duration)
+		if err != nil {
+			return result, err
+		}
+		streams[mode] = stream
+		result.Duration = min(result.Duration, response.Duration)
+		result.ThroughRelay = result.ThroughRelay || isRelayedConn(stream.Conn())
+	}
+
+	stopResetting := context.AfterFunc(ctx, func() {
+		for _, stream := range streams {
+			_ = stream.Reset()
+		}
+	})
+	defer stopResetting()
+
+	var wg sync.WaitGroup
+	var errsLock sync.Mutex
+	var errs []error
+	run := func(mode string, f func(stream network.Stream) error) {
+		stream, ok := streams[mode]
+		if !ok {
+			return
+		}
+		_ = stream.SetDeadline(time.Now().Add(result.Duration + speedTestProbeTimeout + speedTestGracePeriod))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := f(stream)
+			if err != nil {
+				errsLock.Lock()
+				errs = append(errs, fmt.Errorf("%s: %v", mode, err))
+				errsLock.Unlock()
+			}
+		}()
+	}
+
+	run(protocol.SpeedTestModeUpload, func(stream network.Stream) error {
+		_, err := writeSpeedTestData(stream, result.Duration, 0)
+		if err != nil {
+			return err
+		}
+		report, err := protocol.ReceiveSpeedTestReport(stream)
+		if err != nil {
+			return err
+		}
+		result.Upload = newSpeedTestThroughput(report.Bytes, report.Elapsed)
+		return nil
+	})
+	run(protocol.SpeedTestModeDownload, func(stream network.Stream) error {
+		bytes, elapsed, err := readSpeedTestData(stream, 0)
+		if err != nil {
+			return err
+		}
+		result.Download = newSpeedTestThroughput(bytes, elapsed)
+		return nil
+	})
+	run(protocol.SpeedTestModeLatency, func(stream network.Stream) error {
+		latency, err := measureSpeedTestLatency(stream, result.Duration)
+		result.Latency = latency
+		return err
+	})
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if len(errs) > 0 {
+		return result, errors.Join(errs...)
+	}
+	if result.Latency.RTTAvg > 0 {
+		s.p2p.RecordPeerLatency(peerID, result.Latency.RTTAvg)
+	}
+
+	return result, nil
+}
+
+func (s *SpeedTest) openStream(ctx context.Context, peerID peer.ID, mode string, duration time.Duration) (network.Stream, protocol.SpeedTestResponse, error) {
+	newStreamFunc := s.p2p.NewStream
+	if s.conf.P2pNode.UseDedicatedConnForEachStream {
+		newStreamFunc = s.p2p.NewStreamWithDedicatedConn
+	}
+	stream, err := newStreamFunc(ctx, peerID, protocol.SpeedTestMethod)
+	if err != nil {
+		return nil, protocol.SpeedTestResponse{}, fmt.Errorf("open stream: %v", err)
+	}
+
+	_ = stream.SetDeadline(time.Now().Add(speedTestHandshakeLimit))
+	err = protocol.SendSpeedTestRequest(stream, protocol.SpeedTestRequest{Mode: mode, Duration: duration})
+	if err != nil {
+		_ = stream.Reset()
+		return nil, protocol.SpeedTestResponse{}, fmt.Errorf("send request: %v", err)
+	}
+	response, err := protocol.ReceiveSpeedTestResponse(stream)
+	if err != nil {
+		_ = stream.Reset()
+		return nil, protocol.SpeedTestResponse{}, fmt.Errorf("receive response: %v", err)
+	}
+	if !response.Accepted {
+		_ = stream.Close()
+		return nil, response, fmt.Errorf("peer refused speed test: %s", response.Reason)
+	}
+
+	return stream, response, nil
+}
+
+func writeSpeedTestData(stream network.Stream, duration time.Duration, rate int64) (int64, error) {
+	buf := make([]byte, speedTestChunkSize)
+	_, _ = rand.Read(buf)
+
+	var total int64
+	start := time.Now()
+	for time.Since(start) < duration {
+		n, err := stream.Write(buf)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		throttleSpeedTest(start, total, rate)
+	}
+
+	return total, stream.CloseWrite()
+}
+
+func readSpeedTestData(stream io.Reader, rate int64) (int64, time.Duration, error) {
+	buf := make([]byte, speedTestChunkSize)
+
+	var total int64
+	start := time.Now()
+	for {
+		n, err := stream.Read(buf)
+		total += int64(n)
+		if errors.Is(err, io.EOF) {
+			return total, time.Since(start), nil
+		} else if err != nil {
+			return total, time.Since(start), err
+		}
+		throttleSpeedTest(start, total, rate)
+	}
+}
+
+// throttleSpeedTest sleeps until the average rate since start drops to rate bytes per second.
+func throttleSpeedTest(start time.Time, total, rate int64) {
+	if rate <= 0 {
+		return
+	}
+	expected := time.Duration(float64(total) / float64(rate) * float64(time.Second))
+	if wait := expected - time.Since(start); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+func echoSpeedTestProbes(stream network.Stream) error {
+	probe := make([]byte, protocol.SpeedTestProbeSize)
+	for {
+		_, err := io.ReadFull(stream, probe)
+		if errors.Is(err, io.EOF) {
+			return stream.CloseWrite()
+		} else if err != nil {
+			return err
+		}
+		_, err = stream.Write(probe)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func measureSpeedTestLatency(stream network.Stream, duration time.Duration) (entity.SpeedTestLatency, error) {
+	var sent atomic.Int64
+	writeErrCh := make(chan error, 1)
+	go func() {
+		probe := make([]byte, protocol.SpeedTestProbeSize)
+		ticker := time.NewTicker(speedTestProbeInterval)
+		defer ticker.Stop()
+		deadline := time.Now().Add(duration)
+		for seq := uint64(0); time.Now().Before(deadline); seq++ {
+			protocol.PutSpeedTestProbe(probe, seq, time.Now())
+			_, err := stream.Write(probe)
+			if err != nil {
+				writeErrCh <- err
+				return
+			}
+			sent.Add(1)
+			<-ticker.C
+		}
+		writeErrCh <- stream.CloseWrite()
+	}()
+
+	var rtts []time.Duration
+	probe := make([]byte, protocol.SpeedTestProbeSize)
+	var readErr error
+	for {
+		_, err := io.ReadFull(stream, probe)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		_, sentAt := protocol.ParseSpeedTestProbe(probe)
+		rtt := time.Since(sentAt)
+		if rtt <= speedTestProbeTimeout {
+			rtts = append(rtts, rtt)
+		}
+	}
+	writeErr := <-writeErrCh
+
+	latency := newSpeedTestLatency(int(sent.Load()), rtts)
+	return latency, errors.Join(writeErr, readErr)
+}
+
+func newSpeedTestLatency(sent int, rtts []time.Duration) entity.SpeedTestLatency {
+	latency := entity.SpeedTestLatency{
+		ProbesSent: sent,
+		ProbesLost: max(sent-len(rtts), 0),
+	}
+	if sent > 0 {
+		latency.LossPercent = float64(latency.ProbesLost) / float64(sent) * 100
+	}
+	if len(rtts) == 0 {
+		return latency
+	}
+
+	var sum, jitterSum time.Duration
+	latency.RTTMin = rtts[0]
+	for i, rtt := range rtts {
+		sum += rtt
+		latency.RTTMin = min(latency.RTTMin, rtt)
+		latency.RTTMax = max(latency.RTTMax, rtt)
+		if i > 0 {
+			jitterSum += (rtt - rtts[i-1]).Abs()
+		}
+	}
+	latency.RTTAvg = sum / time.Duration(len(rtts))
+	if len(rtts) > 1 {
+		latency.Jitter = jitterSum / time.Duration(len(rtts)-1)
+	}
+
+	return latency
+}
+
+func newSpeedTestThroughput(bytes int64, elapsed time.Duration) *entity.SpeedTestThroughput {
+	throughput := &entity.SpeedTestThroughput{Bytes: bytes}
+	if elapsed > 0 {
+		throughput.BitsPerSecond = float64(bytes*8) / elapsed.Seconds()
+	}
+	return throughput
+}
+
+func isRelayedConn(conn network.Conn) bool {
+	_, err := conn.RemoteMultiaddr().ValueForProtocol(multiaddr.P_CIRCUIT)
+	return err == nil
+}
@@ -133,6 +133,9 @@ func (t *Tunnel) RefreshPeersList() {
 	t.conf.RLock()
 	defer t.conf.RUnlock()
 	for _, knownPeer := range t.conf.KnownPeers {
+		if t.conf.PeerPermissionsUnlocked(knownPeer).Suspended {
+			continue
+		}
 		peerID := knownPeer.PeerId()
 		newLocalIP := net.ParseIP(knownPeer.IPAddr).To4()
 		if newLocalIP == nil {
@@ -168,23 +171,30 @@ func (t *Tunnel) RefreshPeersList() {
 		vpnPeer.Start(t)
 	}
 
-	// delete unknown peers
+	// delete unknown and suspended peers
 	for _, vpnPeer := range t.peerIDToPeer {
-		_, exists := t.conf.KnownPeers[vpnPeer.peerID.String()]
-		if exists {
+		knownPeer, exists := t.conf.KnownPeers[vpnPeer.peerID.String()]
+		if exists && !t.conf.PeerPermissionsUnlocked(knownPeer).Suspended {
 			continue
 		}
 		localIP := *vpnPeer.localIP.Load()
 		vpnPeer.Close(t)
 		delete(t.peerIDToPeer, vpnPeer.peerID)
-		delete(t.netIPToPeer, string(localIP))
+		// IP could be already taken by a new peer, e.g. by the same peer migrated to a new peer ID
+		if t.netIPToPeer[string(localIP)] == vpnPeer {
+			delete(t.netIPToPeer, string(localIP))
+		}
 	}
 
 	// Rebind gateway pointer to the (possibly new) VpnPeer for the configured gateway peer.
 	if t.vpnGatewayClientEnabled {
+		// gateway peer moves to a new peer ID after its key rotation, see Config.MigratePeer
+		if gatewayPeerID, err := peer.Decode(t.conf.VPNGateway.GatewayPeerID); err == nil {
+			t.vpnGatewayPeerID = gatewayPeerID
+		}
 		gwPeer, ok := t.peerIDToPeer[t.vpnGatewayPeerID]
 		if !ok {
-			t.logger.Warnf("VPN gateway peer %s no longer in KnownPeers, disabling gateway client mode", t.vpnGatewayPeerID)
+			t.logger.Warnf("VPN gateway peer %s no longer in KnownPeers or suspended, disabling gateway client mode", t.vpnGatewayPeerID)
 			t.vpnGatewayClientEnabled = false
 			t.vpnGatewayPeerID = ""
 			t.vpnGatewayPeer = nil
@@ -194,13 +204,13 @@ func (t *Tunnel) RefreshPeersList() {
 	}
 
 	// Recompute isGatewayClient for every peer. WeAllowUsingAsExitNode may
-	// have changed for any peer (peer settings update path)
+	// have changed for any peer (peer settings or peer groups update path)
 	for _, kp := range t.conf.KnownPeers {
 		vp, ok := t.peerIDToPeer[kp.PeerId()]
 		if !ok {
 			continue
 		}
-		vp.weAllowUsingAsExitNode.Store(kp.WeAllowUsingAsExitNode)
+		vp.weAllowUsingAsExitNode.Store(t.conf.PeerPermissionsUnlocked(kp).AllowUsingAsExitNode)
 	}
 }
 
@@ -667,8 +677,8 @@ func (t *Tunnel) writeInboundBatch(packets []*vpn.Packet, bufs [][]byte, senderI
 		}
 		// TODO: store this info in atomic in VpnPeer and update in RefreshPeersList
 		//  this will eliminate lock usage on every packets batch
-		kp, ok := t.conf.GetPeer(remotePeerID.String())
-		allowGateway = ok && kp.WeAllowUsingAsExitNode
+		perms, ok := t.conf.PeerPermissions(remotePeerID.String())
+		allowGateway = ok && perms.AllowUsingAsExitNode
 		permResolved = true
 		return allowGateway
 	}
@@ -23,7 +23,6 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
-	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	"github.com/libp2p/go-libp2p/x/simlibp2p"
 	"github.com/marcopolo/simnet"
 	"github.com/multiformats/go-multiaddr"
@@ -207,7 +206,8 @@ func (ts *TestSuite) buildTestPeer(disableLogging bool, listenAddrs []multiaddr.
 	app.SockMarker = noopSockMarker{}
 	app.ExtraLibp2pOpts = extraLibp2pOpts
 
-	app.SetupLoggerAndConfig(config.AppTypeAwl)
+	_, err := app.SetupLoggerAndConfig(config.AppTypeAwl)
+	ts.NoError(err)
 	if disableLogging {
 		log.SetupLogging(zapcore.NewNopCore(), func(string) zapcore.Level {
 			return zapcore.FatalLevel
@@ -268,9 +268,6 @@ func (ts *TestSuite) buildTestPeer(disableLogging bool, listenAddrs []multiaddr.
 func (ts *TestSuite) initBootstrapNode() {
 	peerstore, err := pstoremem.NewPeerstore()
 	ts.NoError(err)
-	resourceLimitsConfig := rcmgr.InfiniteLimits
-	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(resourceLimitsConfig))
-	ts.NoError(err)
 
 	hostConfig := p2p.HostConfig{
 		PrivKeyBytes: nil,
@@ -284,7 +281,6 @@ func (ts *TestSuite) initBootstrapNode() {
 		Libp2pOpts: []libp2p.Option{
 			libp2p.DisableRelay(),
 			libp2p.ForceReachabilityPublic(),
-			libp2p.ResourceManager(mgr),
 		},
 		Peerstore:    peerstore,
 		DHTDatastore: dssync.MutexWrap(ds.NewMapDatastore()),
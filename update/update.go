@@ -47,6 +47,9 @@ func NewUpdateService(c *config.Config, logger *log.ZapEventLogger, appType conf
 	if config.IsDevVersion() {
 		return UpdateService{}, errors.New("updates are unsupported for dev version")
 	}
+	if c.OfflineMode {
+		return UpdateService{}, errors.New("updates are disabled in offline mode")
+	}
 
 	channels := make([]updaterini.Channel, 1)
 	channels[0] = updaterini.NewReleaseChannel(true)